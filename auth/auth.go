@@ -147,3 +147,13 @@ func GetUserByID(userID uint) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+// IsAdmin reports whether the user may call admin RPCs
+func IsAdmin(userID uint) bool {
+	for _, id := range config.AppConfig.Auth.AdminUserIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/Aloys-y/chat-go/config"
+)
+
+const defaultDir = "data/blobs"
+
+// ErrNotFound is returned when no blob is stored under a key
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps binary objects such as recordings under slash-separated keys
+type Store interface {
+	// Create starts writing a blob; it becomes readable once the writer is closed
+	Create(key string) (io.WriteCloser, error)
+	Open(key string) (io.ReadSeekCloser, error)
+	Delete(key string) error
+}
+
+// Default is the store configured by Init
+var Default Store
+
+// Init sets up the blob store from the storage config
+func Init() error {
+	cfg := config.AppConfig.Storage
+
+	switch cfg.Backend {
+	case "", "local":
+		dir := cfg.Dir
+		if dir == "" {
+			dir = defaultDir
+		}
+		store, err := NewLocalStore(dir)
+		if err != nil {
+			return err
+		}
+		Default = store
+	default:
+		return fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+
+	log.Printf("Blob store ready (%s)", cfg.Backend)
+	return nil
+}
+
+// LocalStore stores blobs as files below a directory
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore creates a store rooted at dir, creating the directory if needed
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// path maps a key to a file below the store directory
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+// Create implements Store. The blob is written to a temporary file that is
+// renamed into place on Close so readers never see partial blobs.
+func (s *LocalStore) Create(key string) (io.WriteCloser, error) {
+	name := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.part")
+	if err != nil {
+		return nil, err
+	}
+	return &localWriter{File: f, name: name}, nil
+}
+
+// Open implements Store
+func (s *LocalStore) Open(key string) (io.ReadSeekCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete implements Store
+func (s *LocalStore) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// localWriter moves the temporary file to its key when closed
+type localWriter struct {
+	*os.File
+	name string
+}
+
+func (w *localWriter) Close() error {
+	if err := w.File.Close(); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	return os.Rename(w.File.Name(), w.name)
+}
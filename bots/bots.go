@@ -0,0 +1,380 @@
+// Package bots runs server-side participants that play audio into rooms.
+// A bot connects to the signaling server like any client and streams an
+// Ogg/Opus file or a generated tone to the other participants, e.g. for hold
+// music, announcements or end-to-end tests. Bots live on the node that
+// started them.
+package bots
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Aloys-y/chat-go/auth"
+	"github.com/Aloys-y/chat-go/config"
+	"github.com/Aloys-y/chat-go/db"
+	"github.com/Aloys-y/chat-go/membership"
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/Aloys-y/chat-go/sfu"
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxNameLength bounds the names of bots
+	maxNameLength = 32
+	// defaultMaxBots is used when bots.max_bots is not configured
+	defaultMaxBots = 10
+)
+
+var (
+	ErrBotNotFound  = errors.New("bot not found")
+	ErrBotRunning   = errors.New("bot is already running")
+	ErrTooManyBots  = errors.New("too many bots are running")
+	ErrInvalidName  = fmt.Errorf("bot names are 1 to %d letters, digits, '-' or '_'", maxNameLength)
+	ErrNameTaken    = errors.New("the name belongs to a user who is not a bot")
+	ErrNoSource     = errors.New("a bot plays either a file or a tone")
+	ErrInvalidTone  = fmt.Errorf("tones must be between %d and %d Hz", sfu.MinToneFrequency, sfu.MaxToneFrequency)
+	ErrFileNotFound = errors.New("media file not found")
+	ErrEncrypted    = errors.New("bots can't join rooms that require end-to-end encryption")
+
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+	bots    = make(map[uint]*Bot)
+	botsMux sync.Mutex
+)
+
+// Options describe a bot to start. Exactly one of File, a name in the media
+// directory, and ToneHz is set. A file stops the bot when it ends unless Loop
+// is set; a tone plays until the bot is stopped. Duration, when set, stops
+// the bot after that long.
+type Options struct {
+	RoomID      uint
+	Name        string
+	DisplayName string
+	File        string
+	ToneHz      int
+	Loop        bool
+	Duration    time.Duration
+}
+
+// Info is a snapshot of a running bot. Peers counts its media connections;
+// the packet counters let tests check that media flows both ways.
+type Info struct {
+	UserID          uint
+	Name            string
+	RoomID          uint
+	Source          string
+	Loop            bool
+	StartedAt       time.Time
+	Muted           bool
+	Peers           int
+	PacketsSent     uint64
+	PacketsReceived uint64
+}
+
+// Bot is a running bot participant
+type Bot struct {
+	UserID    uint
+	Name      string
+	Loop      bool
+	StartedAt time.Time
+
+	// token authenticates the bot's signaling connection
+	token    string
+	source   source
+	duration time.Duration
+	api      *webrtc.API
+	track    *webrtc.TrackLocalStaticSample
+	conn     atomic.Pointer[websocket.Conn]
+
+	// writeMux serializes writes to the signaling connection
+	writeMux sync.Mutex
+
+	// mux guards the room the bot is in and its peer connections
+	mux         sync.Mutex
+	roomID      uint
+	mediaMode   string
+	codecPolicy models.CodecPolicy
+	iceServers  []webrtc.ICEServer
+	peerRoles   map[uint]string
+	peers       map[uint]*webrtc.PeerConnection
+	publisher   *webrtc.PeerConnection
+	subscriber  *webrtc.PeerConnection
+	muted       bool
+
+	joined   chan error
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	packetsSent     atomic.Uint64
+	packetsReceived atomic.Uint64
+}
+
+// maxBots returns how many bots may run on this node
+func maxBots() int {
+	if n := config.AppConfig.Bots.MaxBots; n > 0 {
+		return n
+	}
+	return defaultMaxBots
+}
+
+// Start connects a bot to the room and starts playing. It returns once the
+// bot is in the room or waiting in its lobby. Admins may put bots into
+// private rooms; rooms requiring end-to-end encryption can't have bots.
+func Start(opts Options) (Info, error) {
+	if len(opts.Name) > maxNameLength || !namePattern.MatchString(opts.Name) {
+		return Info{}, ErrInvalidName
+	}
+
+	room, err := membership.GetRoom(opts.RoomID)
+	if err != nil {
+		return Info{}, err
+	}
+	if room.E2EERequired {
+		return Info{}, ErrEncrypted
+	}
+
+	src, err := openSource(opts)
+	if err != nil {
+		return Info{}, err
+	}
+	b, err := register(opts, src)
+	if err != nil {
+		src.Close()
+		return Info{}, err
+	}
+
+	if !room.IsPublic {
+		err = addMember(room, b.UserID)
+	}
+	if err == nil {
+		err = b.connect(room.ID)
+	}
+	if err != nil {
+		b.Stop()
+		src.Close()
+		return Info{}, err
+	}
+
+	go b.play()
+	log.Printf("Bot %d (%s) started in room %d playing %s", b.UserID, b.Name, room.ID, src)
+	return b.Info(), nil
+}
+
+// Stop stops the bot of the user, which leaves its room
+func Stop(userID uint) (Info, error) {
+	botsMux.Lock()
+	b, ok := bots[userID]
+	botsMux.Unlock()
+	if !ok {
+		return Info{}, ErrBotNotFound
+	}
+
+	info := b.Info()
+	b.Stop()
+	return info, nil
+}
+
+// List returns the bots running on this node, those in the room only when
+// roomID is set
+func List(roomID uint) []Info {
+	botsMux.Lock()
+	running := make([]*Bot, 0, len(bots))
+	for _, b := range bots {
+		running = append(running, b)
+	}
+	botsMux.Unlock()
+
+	infos := make([]Info, 0, len(running))
+	for _, b := range running {
+		info := b.Info()
+		if roomID == 0 || info.RoomID == roomID {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// StopAll stops every bot on this node, for shutdown
+func StopAll() {
+	botsMux.Lock()
+	running := make([]*Bot, 0, len(bots))
+	for _, b := range bots {
+		running = append(running, b)
+	}
+	botsMux.Unlock()
+
+	for _, b := range running {
+		b.Stop()
+	}
+}
+
+// openSource opens what the bot plays
+func openSource(opts Options) (source, error) {
+	switch {
+	case opts.File != "" && opts.ToneHz == 0:
+		return newFileSource(opts.File, opts.Loop)
+	case opts.File == "" && opts.ToneHz != 0:
+		return newToneSource(opts.ToneHz)
+	default:
+		return nil, ErrNoSource
+	}
+}
+
+// register creates the bot under its user account, refusing a second bot
+// of the same account
+func register(opts Options, src source) (*Bot, error) {
+	user, err := botUser(opts.Name, opts.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+	token, err := auth.GenerateToken(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bot token: %w", err)
+	}
+	api, err := sfu.API()
+	if err != nil {
+		return nil, err
+	}
+	track, err := webrtc.NewTrackLocalStaticSample(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+		"audio", fmt.Sprintf("bot-%d", user.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audio track: %w", err)
+	}
+
+	botsMux.Lock()
+	defer botsMux.Unlock()
+	if _, ok := bots[user.ID]; ok {
+		return nil, ErrBotRunning
+	}
+	if len(bots) >= maxBots() {
+		return nil, ErrTooManyBots
+	}
+
+	b := &Bot{
+		UserID:    user.ID,
+		Name:      opts.Name,
+		Loop:      opts.Loop,
+		StartedAt: time.Now(),
+		token:     token,
+		source:    src,
+		duration:  opts.Duration,
+		api:       api,
+		track:     track,
+		peerRoles: make(map[uint]string),
+		peers:     make(map[uint]*webrtc.PeerConnection),
+		joined:    make(chan error, 1),
+		stop:      make(chan struct{}),
+	}
+	bots[user.ID] = b
+	return b, nil
+}
+
+// botUser returns the account of the named bot, creating it on first use.
+// Bot accounts have no password, so nobody can log in as a bot.
+func botUser(name, displayName string) (*models.User, error) {
+	if displayName == "" {
+		displayName = name
+	}
+
+	var user models.User
+	err := db.DB.Where("username = ?", "bot_"+name).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user = models.User{
+			Username:     "bot_" + name,
+			Email:        name + "@bots.invalid",
+			PasswordHash: "!",
+			DisplayName:  displayName,
+			LastLogin:    time.Now(),
+			IsBot:        true,
+		}
+		if err := db.DB.Create(&user).Error; err != nil {
+			return nil, fmt.Errorf("failed to create bot user: %w", err)
+		}
+		return &user, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsBot {
+		return nil, ErrNameTaken
+	}
+
+	if user.DisplayName != displayName {
+		if err := db.DB.Model(&user).Update("display_name", displayName).Error; err != nil {
+			return nil, fmt.Errorf("failed to rename bot: %w", err)
+		}
+	}
+	return &user, nil
+}
+
+// addMember records the bot as a member of a private room unless it is one
+func addMember(room *models.Room, userID uint) error {
+	member, err := membership.IsMember(room.ID, userID)
+	if err != nil || member {
+		return err
+	}
+	return membership.Add(room, userID)
+}
+
+// Info returns a snapshot of the bot
+func (b *Bot) Info() Info {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	peers := len(b.peers)
+	if b.publisher != nil {
+		peers++
+	}
+	if b.subscriber != nil {
+		peers++
+	}
+	return Info{
+		UserID:          b.UserID,
+		Name:            b.Name,
+		RoomID:          b.roomID,
+		Source:          b.source.String(),
+		Loop:            b.Loop,
+		StartedAt:       b.StartedAt,
+		Muted:           b.muted,
+		Peers:           peers,
+		PacketsSent:     b.packetsSent.Load(),
+		PacketsReceived: b.packetsReceived.Load(),
+	}
+}
+
+// Stop disconnects the bot, which leaves its room. It is safe to call more
+// than once.
+func (b *Bot) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+
+		botsMux.Lock()
+		if bots[b.UserID] == b {
+			delete(bots, b.UserID)
+		}
+		botsMux.Unlock()
+
+		// A normal close tells the server the bot hung up
+		if conn := b.conn.Load(); conn != nil {
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(writeWait))
+			conn.Close()
+		}
+
+		b.mux.Lock()
+		b.closePeersLocked()
+		b.mux.Unlock()
+
+		log.Printf("Bot %d (%s) stopped", b.UserID, b.Name)
+	})
+}
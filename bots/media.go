@@ -0,0 +1,425 @@
+package bots
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/Aloys-y/chat-go/sfu"
+	"github.com/Aloys-y/chat-go/signaling"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// opusRtpmap finds the payload type of Opus in an SDP
+var opusRtpmap = regexp.MustCompile(`(?mi)^a=rtpmap:(\d+) opus/48000`)
+
+// play writes the source to the bot's track in real time until the source
+// ends, the bot's duration is up or the bot is stopped. Nothing is sent
+// while the bot is muted, but the source keeps its pace.
+func (b *Bot) play() {
+	defer b.source.Close()
+
+	var deadline <-chan time.Time
+	if b.duration > 0 {
+		timer := time.NewTimer(b.duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	wait := time.NewTimer(0)
+	defer wait.Stop()
+	<-wait.C
+
+	next := time.Now()
+	for {
+		packet, duration, err := b.source.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("Bot %d failed to read %s: %v", b.UserID, b.source, err)
+			}
+			b.Stop()
+			return
+		}
+
+		if !b.isMuted() {
+			if err := b.track.WriteSample(media.Sample{Data: packet, Duration: duration}); err != nil {
+				log.Printf("Bot %d failed to send audio: %v", b.UserID, err)
+			} else {
+				b.packetsSent.Add(1)
+			}
+		}
+
+		next = next.Add(duration)
+		wait.Reset(time.Until(next))
+		select {
+		case <-wait.C:
+		case <-deadline:
+			b.Stop()
+			return
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// isMuted reports whether a moderator asked the bot to mute
+func (b *Bot) isMuted() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.muted
+}
+
+// newPeerConnection creates a connection sending the bot's track and
+// counting the packets it receives
+func (b *Bot) newPeerConnection(cfg webrtc.Configuration, onCandidate func(*webrtc.ICECandidate)) (*webrtc.PeerConnection, error) {
+	pc, err := b.api.NewPeerConnection(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create peer connection: %w", err)
+	}
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c != nil {
+			onCandidate(c)
+		}
+	})
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := track.Read(buf); err != nil {
+				return
+			}
+			b.packetsReceived.Add(1)
+		}
+	})
+	return pc, nil
+}
+
+// addTrack adds the bot's track to a connection, reading the RTCP sent back
+// so the interceptors see it
+func (b *Bot) addTrack(pc *webrtc.PeerConnection) error {
+	sender, err := pc.AddTrack(b.track)
+	if err != nil {
+		return fmt.Errorf("failed to add audio track: %w", err)
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := sender.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// peerLocked returns the mesh connection to a participant, creating it if
+// needed. The caller must hold b.mux.
+func (b *Bot) peerLocked(peerID uint) (*webrtc.PeerConnection, error) {
+	if pc, ok := b.peers[peerID]; ok {
+		return pc, nil
+	}
+
+	pc, err := b.newPeerConnection(webrtc.Configuration{ICEServers: b.iceServers}, func(c *webrtc.ICECandidate) {
+		b.sendTo("ice_candidate", peerID, c.ToJSON())
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := b.addTrack(pc); err != nil {
+		pc.Close()
+		return nil, err
+	}
+	b.peers[peerID] = pc
+	return pc, nil
+}
+
+// offerLocked sends an offer on a mesh connection. The caller must hold
+// b.mux.
+func (b *Bot) offerLocked(peerID uint, pc *webrtc.PeerConnection, iceRestart bool) error {
+	offer, err := pc.CreateOffer(&webrtc.OfferOptions{ICERestart: iceRestart})
+	if err != nil {
+		return err
+	}
+	if err := pc.SetLocalDescription(offer); err != nil {
+		return err
+	}
+	b.sendTo("sdp_offer", peerID, b.withCodecPolicyLocked(pc.LocalDescription()))
+	return nil
+}
+
+// renegotiate offers again on a mesh connection when the server asks the
+// bot to, as the impolite peer of the pair
+func (b *Bot) renegotiate(peerID uint, iceRestart bool) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	pc, ok := b.peers[peerID]
+	if !ok {
+		return nil
+	}
+	return b.offerLocked(peerID, pc, iceRestart)
+}
+
+// handleOffer answers a participant's offer. On a collision with the bot's
+// own offer the impolite side keeps its offer and the polite side rolls its
+// own back.
+func (b *Bot) handleOffer(msg signaling.Message) error {
+	var offer webrtc.SessionDescription
+	if err := unmarshalPayload(msg.Payload, &offer); err != nil {
+		return err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	pc, err := b.peerLocked(msg.UserID)
+	if err != nil {
+		return err
+	}
+	if pc.SignalingState() != webrtc.SignalingStateStable {
+		if b.peerRoles[msg.UserID] != signaling.NegotiationPolite {
+			return nil
+		}
+		if err := pc.SetLocalDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeRollback}); err != nil {
+			return err
+		}
+	}
+
+	if err := pc.SetRemoteDescription(offer); err != nil {
+		return err
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return err
+	}
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return err
+	}
+	b.sendTo("sdp_answer", msg.UserID, b.withCodecPolicyLocked(pc.LocalDescription()))
+	return nil
+}
+
+// handleAnswer applies a participant's answer to the bot's offer
+func (b *Bot) handleAnswer(msg signaling.Message) error {
+	var answer webrtc.SessionDescription
+	if err := unmarshalPayload(msg.Payload, &answer); err != nil {
+		return err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	pc, ok := b.peers[msg.UserID]
+	if !ok {
+		return nil
+	}
+	return pc.SetRemoteDescription(answer)
+}
+
+// handleCandidate adds a participant's ICE candidate
+func (b *Bot) handleCandidate(msg signaling.Message) error {
+	var candidate webrtc.ICECandidateInit
+	if err := unmarshalPayload(msg.Payload, &candidate); err != nil {
+		return err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	pc, ok := b.peers[msg.UserID]
+	if !ok {
+		return nil
+	}
+	return pc.AddICECandidate(candidate)
+}
+
+// publishLocked offers the bot's track to the SFU. The caller must hold
+// b.mux.
+func (b *Bot) publishLocked() error {
+	pc, err := b.newPeerConnection(webrtc.Configuration{}, func(c *webrtc.ICECandidate) {
+		b.sendTo(sfu.MsgCandidate, 0, sfu.Candidate{Target: sfu.TargetPublisher, Candidate: c.ToJSON()})
+	})
+	if err != nil {
+		return err
+	}
+	if err := b.addTrack(pc); err != nil {
+		pc.Close()
+		return err
+	}
+	b.publisher = pc
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	if err := pc.SetLocalDescription(offer); err != nil {
+		return err
+	}
+	b.sendTo("sfu_publish", 0, b.withCodecPolicyLocked(pc.LocalDescription()))
+	return nil
+}
+
+// handlePublishAnswer applies the SFU's answer to the bot's publish offer
+func (b *Bot) handlePublishAnswer(msg signaling.Message) error {
+	var answer webrtc.SessionDescription
+	if err := json.Unmarshal(msg.Payload, &answer); err != nil {
+		return err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.publisher == nil {
+		return nil
+	}
+	return b.publisher.SetRemoteDescription(answer)
+}
+
+// handleSubscribeOffer answers the SFU's offer of the other participants'
+// tracks, which the bot only counts
+func (b *Bot) handleSubscribeOffer(msg signaling.Message) error {
+	var offer webrtc.SessionDescription
+	if err := json.Unmarshal(msg.Payload, &offer); err != nil {
+		return err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.subscriber == nil {
+		pc, err := b.newPeerConnection(webrtc.Configuration{}, func(c *webrtc.ICECandidate) {
+			b.sendTo(sfu.MsgCandidate, 0, sfu.Candidate{Target: sfu.TargetSubscriber, Candidate: c.ToJSON()})
+		})
+		if err != nil {
+			return err
+		}
+		b.subscriber = pc
+	}
+
+	if err := b.subscriber.SetRemoteDescription(offer); err != nil {
+		return err
+	}
+	answer, err := b.subscriber.CreateAnswer(nil)
+	if err != nil {
+		return err
+	}
+	if err := b.subscriber.SetLocalDescription(answer); err != nil {
+		return err
+	}
+	b.sendTo("sfu_subscribe_answer", 0, b.subscriber.LocalDescription())
+	return nil
+}
+
+// handleSFUCandidate adds an ICE candidate of the SFU
+func (b *Bot) handleSFUCandidate(msg signaling.Message) error {
+	var candidate sfu.Candidate
+	if err := json.Unmarshal(msg.Payload, &candidate); err != nil {
+		return err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	pc := b.subscriber
+	if candidate.Target == sfu.TargetPublisher {
+		pc = b.publisher
+	}
+	if pc == nil {
+		return nil
+	}
+	return pc.AddICECandidate(candidate.Candidate)
+}
+
+// closePeer closes the mesh connection to a participant
+func (b *Bot) closePeer(peerID uint) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if pc, ok := b.peers[peerID]; ok {
+		pc.Close()
+		delete(b.peers, peerID)
+	}
+	delete(b.peerRoles, peerID)
+}
+
+// closePeersLocked closes all the bot's connections. The caller must hold
+// b.mux.
+func (b *Bot) closePeersLocked() {
+	for peerID, pc := range b.peers {
+		pc.Close()
+		delete(b.peers, peerID)
+	}
+	for _, pc := range []*webrtc.PeerConnection{b.publisher, b.subscriber} {
+		if pc != nil {
+			pc.Close()
+		}
+	}
+	b.publisher = nil
+	b.subscriber = nil
+}
+
+// unmarshalPayload decodes a payload relayed from a client, which may have
+// sent it as a JSON string
+func unmarshalPayload(payload json.RawMessage, v interface{}) error {
+	var s string
+	if json.Unmarshal(payload, &s) == nil {
+		payload = json.RawMessage(s)
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// withCodecPolicyLocked returns a copy of a local description carrying the
+// Opus parameters the room's codec policy requires. pion refuses munged
+// local descriptions, so only the description sent is changed. The caller
+// must hold b.mux.
+func (b *Bot) withCodecPolicyLocked(desc *webrtc.SessionDescription) webrtc.SessionDescription {
+	munged := *desc
+	munged.SDP = withCodecPolicy(desc.SDP, b.codecPolicy)
+	return munged
+}
+
+// withCodecPolicy sets the Opus parameters a codec policy requires on an
+// SDP, as clients do
+func withCodecPolicy(sdp string, policy models.CodecPolicy) string {
+	match := opusRtpmap.FindStringSubmatch(sdp)
+	if match == nil {
+		return sdp
+	}
+
+	prefix := "a=fmtp:" + match[1] + " "
+	lines := strings.Split(sdp, "\r\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+
+		params := strings.Split(strings.TrimPrefix(line, prefix), ";")
+		set := func(key, value string) {
+			for j, p := range params {
+				if strings.HasPrefix(p, key+"=") {
+					params[j] = key + "=" + value
+					return
+				}
+			}
+			params = append(params, key+"="+value)
+		}
+		if policy.OpusDTX {
+			set("usedtx", "1")
+		}
+		if policy.OpusFEC {
+			set("useinbandfec", "1")
+		}
+		if policy.MaxAudioBitrateKbps > 0 {
+			set("maxaveragebitrate", strconv.Itoa(policy.MaxAudioBitrateKbps*1000))
+		}
+		lines[i] = prefix + strings.Join(params, ";")
+	}
+	return strings.Join(lines, "\r\n")
+}
@@ -0,0 +1,304 @@
+package bots
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Aloys-y/chat-go/config"
+	"github.com/Aloys-y/chat-go/ice"
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/Aloys-y/chat-go/sfu"
+	"github.com/Aloys-y/chat-go/signaling"
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	// joinTimeout is how long a bot waits for the server to answer its
+	// join_room
+	joinTimeout = 10 * time.Second
+	// joinRequestID is the request ID of the bot's join_room
+	joinRequestID = "bot-join"
+	// writeWait bounds writes to the signaling connection
+	writeWait = 10 * time.Second
+)
+
+var errStopped = errors.New("bot stopped")
+
+// JoinError is the error frame the server answered a bot's join_room with
+type JoinError struct {
+	Code    string
+	Message string
+}
+
+func (e *JoinError) Error() string {
+	return fmt.Sprintf("failed to join room: %s: %s", e.Code, e.Message)
+}
+
+// connect opens the bot's signaling session on this node and joins the room
+func (b *Bot) connect(roomID uint) error {
+	url := fmt.Sprintf("ws://127.0.0.1:%d/ws?token=%s&protocol_version=%d",
+		config.AppConfig.Server.WSPort, b.token, signaling.ProtocolVersion)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the signaling server: %w", err)
+	}
+
+	// Stop closes the connection once it is stored
+	b.conn.Store(conn)
+	select {
+	case <-b.stop:
+		conn.Close()
+		return errStopped
+	default:
+	}
+	go b.readPump(conn)
+
+	join := signaling.Message{Type: "join_room", RequestID: joinRequestID}
+	join.Payload, _ = json.Marshal(map[string]interface{}{
+		"room_id": roomID,
+	})
+	if err := b.send(join); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-b.joined:
+		return err
+	case <-time.After(joinTimeout):
+		return fmt.Errorf("room %d did not answer the bot in %s", roomID, joinTimeout)
+	case <-b.stop:
+		return errStopped
+	}
+}
+
+// send writes a message to the signaling server
+func (b *Bot) send(msg signaling.Message) error {
+	conn := b.conn.Load()
+	if conn == nil {
+		return errStopped
+	}
+
+	b.writeMux.Lock()
+	defer b.writeMux.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return conn.WriteJSON(msg)
+}
+
+// sendTo sends a message with a payload to a peer, or to the server when
+// targetID is 0
+func (b *Bot) sendTo(msgType string, targetID uint, payload interface{}) {
+	msg := signaling.Message{Type: msgType, TargetID: targetID}
+	msg.Payload, _ = json.Marshal(payload)
+	if err := b.send(msg); err != nil {
+		log.Printf("Bot %d failed to send %s: %v", b.UserID, msgType, err)
+	}
+}
+
+// finishJoin hands the outcome of join_room to connect
+func (b *Bot) finishJoin(err error) {
+	select {
+	case b.joined <- err:
+	default:
+	}
+}
+
+// readPump handles the frames of the signaling server until the connection
+// closes, which stops the bot. The server may batch several messages into a
+// frame, separated by newlines.
+func (b *Bot) readPump(conn *websocket.Conn) {
+	defer b.Stop()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-b.stop:
+			default:
+				log.Printf("Bot %d lost its signaling connection: %v", b.UserID, err)
+			}
+			return
+		}
+
+		for _, frame := range bytes.Split(data, []byte{'\n'}) {
+			if len(bytes.TrimSpace(frame)) == 0 {
+				continue
+			}
+			var msg signaling.Message
+			if err := json.Unmarshal(frame, &msg); err != nil {
+				log.Printf("Bot %d received an invalid message: %v", b.UserID, err)
+				continue
+			}
+			if !b.handleMessage(msg) {
+				return
+			}
+		}
+	}
+}
+
+// handleMessage reacts to a message of the signaling server like a client
+// would. It returns false once the bot was removed from its room.
+func (b *Bot) handleMessage(msg signaling.Message) bool {
+	var err error
+	switch msg.Type {
+	case "ice_config":
+		err = b.handleICEConfig(msg)
+	case "room_state":
+		err = b.handleRoomState(msg)
+		b.finishJoin(err)
+	case "lobby_position":
+		b.finishJoin(nil)
+	case "codec_policy":
+		b.mux.Lock()
+		err = json.Unmarshal(msg.Payload, &b.codecPolicy)
+		b.mux.Unlock()
+	case "user_joined":
+		err = b.handleUserJoined(msg)
+	case "user_left":
+		var left struct {
+			UserID uint `json:"user_id"`
+		}
+		if err = json.Unmarshal(msg.Payload, &left); err == nil {
+			b.closePeer(left.UserID)
+		}
+	case "sdp_offer":
+		err = b.handleOffer(msg)
+	case "sdp_answer":
+		err = b.handleAnswer(msg)
+	case "ice_candidate":
+		err = b.handleCandidate(msg)
+	case "renegotiate", "ice_restart":
+		var req struct {
+			PeerID     uint `json:"peer_id"`
+			ICERestart bool `json:"ice_restart"`
+		}
+		if err = json.Unmarshal(msg.Payload, &req); err == nil {
+			err = b.renegotiate(req.PeerID, msg.Type == "ice_restart" || req.ICERestart)
+		}
+	case "negotiation_failed":
+		var failed struct {
+			PeerID uint `json:"peer_id"`
+		}
+		if err = json.Unmarshal(msg.Payload, &failed); err == nil {
+			b.closePeer(failed.PeerID)
+		}
+	case "sfu_publish_answer":
+		err = b.handlePublishAnswer(msg)
+	case sfu.MsgSubscribeOffer:
+		err = b.handleSubscribeOffer(msg)
+	case sfu.MsgCandidate:
+		err = b.handleSFUCandidate(msg)
+	case "mute_requested":
+		b.mux.Lock()
+		b.muted = true
+		b.mux.Unlock()
+		b.sendTo("mute_state", 0, map[string]interface{}{"muted": true})
+	case "removed_from_room":
+		log.Printf("Bot %d was removed from room %d", b.UserID, msg.RoomID)
+		return false
+	case "error":
+		var frame struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		}
+		json.Unmarshal(msg.Payload, &frame)
+		if msg.RequestID == joinRequestID {
+			b.finishJoin(&JoinError{Code: frame.Code, Message: frame.Message})
+			return false
+		}
+		log.Printf("Bot %d %s failed: %s: %s", b.UserID, frame.Type, frame.Code, frame.Message)
+	}
+
+	if err != nil {
+		log.Printf("Bot %d failed to handle %s: %v", b.UserID, msg.Type, err)
+	}
+	return true
+}
+
+// handleICEConfig keeps the ICE servers for the bot's mesh connections
+func (b *Bot) handleICEConfig(msg signaling.Message) error {
+	var cfg ice.Config
+	if err := json.Unmarshal(msg.Payload, &cfg); err != nil {
+		return err
+	}
+
+	servers := make([]webrtc.ICEServer, 0, len(cfg.ICEServers))
+	for _, s := range cfg.ICEServers {
+		servers = append(servers, webrtc.ICEServer{
+			URLs:       s.URLs,
+			Username:   s.Username,
+			Credential: s.Credential,
+		})
+	}
+
+	b.mux.Lock()
+	b.iceServers = servers
+	b.mux.Unlock()
+	return nil
+}
+
+// handleRoomState starts over in the room the bot just entered. In SFU
+// rooms the bot publishes its track; in mesh rooms the participants already
+// there offer connections to the bot.
+func (b *Bot) handleRoomState(msg signaling.Message) error {
+	var state struct {
+		MediaMode   string             `json:"media_mode"`
+		CodecPolicy models.CodecPolicy `json:"codec_policy"`
+		PeerRoles   map[uint]string    `json:"peer_roles"`
+	}
+	if err := json.Unmarshal(msg.Payload, &state); err != nil {
+		return err
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.closePeersLocked()
+	b.roomID = msg.RoomID
+	b.mediaMode = state.MediaMode
+	b.codecPolicy = state.CodecPolicy
+	b.peerRoles = state.PeerRoles
+	if b.peerRoles == nil {
+		b.peerRoles = make(map[uint]string)
+	}
+
+	if b.mediaMode == models.MediaModeSFU {
+		return b.publishLocked()
+	}
+	return nil
+}
+
+// handleUserJoined offers a mesh connection to a new participant, as the
+// participants already in a room do
+func (b *Bot) handleUserJoined(msg signaling.Message) error {
+	var joined struct {
+		UserID    uint            `json:"user_id"`
+		PeerRoles map[uint]string `json:"peer_roles"`
+	}
+	if err := json.Unmarshal(msg.Payload, &joined); err != nil {
+		return err
+	}
+	if joined.UserID == b.UserID {
+		return nil
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if b.mediaMode == models.MediaModeSFU {
+		return nil
+	}
+	if role, ok := joined.PeerRoles[b.UserID]; ok {
+		b.peerRoles[joined.UserID] = role
+	}
+	pc, err := b.peerLocked(joined.UserID)
+	if err != nil {
+		return err
+	}
+	return b.offerLocked(joined.UserID, pc, false)
+}
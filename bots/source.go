@@ -0,0 +1,247 @@
+package bots
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Aloys-y/chat-go/config"
+	"github.com/Aloys-y/chat-go/sfu"
+)
+
+// defaultMediaDir is used when bots.media_dir is not configured
+const defaultMediaDir = "data/media"
+
+var (
+	errNotOggOpus = errors.New("not an Ogg/Opus file")
+	errBadPage    = errors.New("malformed Ogg page")
+)
+
+// source produces the Opus packets a bot plays, each with its duration. It
+// returns io.EOF once it has nothing more to play.
+type source interface {
+	Next() ([]byte, time.Duration, error)
+	Close() error
+	String() string
+}
+
+// mediaDir returns the directory bots play files from
+func mediaDir() string {
+	if dir := config.AppConfig.Bots.MediaDir; dir != "" {
+		return dir
+	}
+	return defaultMediaDir
+}
+
+// toneSource loops one second of a generated sine tone
+type toneSource struct {
+	frequency int
+	frames    [][]byte
+	next      int
+}
+
+func newToneSource(frequency int) (*toneSource, error) {
+	if frequency < sfu.MinToneFrequency || frequency > sfu.MaxToneFrequency {
+		return nil, ErrInvalidTone
+	}
+	frames, err := sfu.ToneFrames(frequency)
+	if err != nil {
+		return nil, err
+	}
+	return &toneSource{frequency: frequency, frames: frames}, nil
+}
+
+func (s *toneSource) Next() ([]byte, time.Duration, error) {
+	frame := s.frames[s.next]
+	s.next = (s.next + 1) % len(s.frames)
+	return frame, 20 * time.Millisecond, nil
+}
+
+func (s *toneSource) Close() error { return nil }
+
+func (s *toneSource) String() string { return fmt.Sprintf("tone:%dHz", s.frequency) }
+
+// fileSource plays an Ogg/Opus file from the media directory, from the
+// start again when it loops
+type fileSource struct {
+	name   string
+	loop   bool
+	file   *os.File
+	reader *oggReader
+}
+
+// newFileSource opens a file of the media directory. The name must stay
+// inside the directory.
+func newFileSource(name string, loop bool) (*fileSource, error) {
+	if !filepath.IsLocal(name) {
+		return nil, ErrFileNotFound
+	}
+	file, err := os.Open(filepath.Join(mediaDir(), name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &fileSource{name: name, loop: loop, file: file}
+	if err := s.rewind(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return s, nil
+}
+
+// rewind starts reading the file from its first audio packet
+func (s *fileSource) rewind() error {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.reader = newOggReader(s.file)
+	return s.reader.readHeaders()
+}
+
+func (s *fileSource) Next() ([]byte, time.Duration, error) {
+	for {
+		packet, err := s.reader.next()
+		if errors.Is(err, io.EOF) && s.loop {
+			if err := s.rewind(); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if duration := packetDuration(packet); duration > 0 {
+			return packet, duration, nil
+		}
+	}
+}
+
+func (s *fileSource) Close() error { return s.file.Close() }
+
+func (s *fileSource) String() string { return "file:" + s.name }
+
+// oggReader splits an Ogg stream into its packets. Only the first logical
+// stream is read; Opus files have no other.
+type oggReader struct {
+	r       *bufio.Reader
+	serial  []byte
+	packets [][]byte
+	partial []byte
+}
+
+func newOggReader(r io.Reader) *oggReader {
+	return &oggReader{r: bufio.NewReader(r)}
+}
+
+// readHeaders checks the OpusHead and skips the OpusTags packets
+func (o *oggReader) readHeaders() error {
+	head, err := o.next()
+	if err != nil || !bytes.HasPrefix(head, []byte("OpusHead")) {
+		return errNotOggOpus
+	}
+	tags, err := o.next()
+	if err != nil || !bytes.HasPrefix(tags, []byte("OpusTags")) {
+		return errNotOggOpus
+	}
+	return nil
+}
+
+// next returns the next packet of the stream
+func (o *oggReader) next() ([]byte, error) {
+	for len(o.packets) == 0 {
+		if err := o.readPage(); err != nil {
+			return nil, err
+		}
+	}
+	packet := o.packets[0]
+	o.packets = o.packets[1:]
+	return packet, nil
+}
+
+// readPage reads one page, queueing the packets it completes. A packet
+// continues on the next page when its last lacing value is 255.
+func (o *oggReader) readPage() error {
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(o.r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return errBadPage
+		}
+		return err
+	}
+	if string(header[:4]) != "OggS" {
+		return errBadPage
+	}
+
+	lacing := make([]byte, header[26])
+	if _, err := io.ReadFull(o.r, lacing); err != nil {
+		return errBadPage
+	}
+	size := 0
+	for _, n := range lacing {
+		size += int(n)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(o.r, payload); err != nil {
+		return errBadPage
+	}
+
+	serial := header[14:18]
+	if o.serial == nil {
+		o.serial = append([]byte(nil), serial...)
+	} else if !bytes.Equal(o.serial, serial) {
+		return nil
+	}
+
+	for _, n := range lacing {
+		o.partial = append(o.partial, payload[:n]...)
+		payload = payload[n:]
+		if n < 255 {
+			o.packets = append(o.packets, o.partial)
+			o.partial = nil
+		}
+	}
+	return nil
+}
+
+// packetDuration reads how much audio an Opus packet holds from its TOC byte
+// (RFC 6716 section 3.1), or 0 for an invalid packet
+func packetDuration(packet []byte) time.Duration {
+	if len(packet) == 0 {
+		return 0
+	}
+
+	toc := packet[0]
+	mode := toc >> 3
+	var frame time.Duration
+	switch {
+	case mode < 12:
+		// SILK: 10, 20, 40 or 60ms
+		frame = []time.Duration{10, 20, 40, 60}[mode%4] * time.Millisecond
+	case mode < 16:
+		// Hybrid: 10 or 20ms
+		frame = []time.Duration{10, 20}[mode%2] * time.Millisecond
+	default:
+		// CELT: 2.5, 5, 10 or 20ms
+		frame = []time.Duration{2500, 5000, 10000, 20000}[mode%4] * time.Microsecond
+	}
+
+	frames := 1
+	switch toc & 0x3 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0
+		}
+		frames = int(packet[1] & 0x3f)
+	}
+	return time.Duration(frames) * frame
+}
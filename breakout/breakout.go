@@ -0,0 +1,310 @@
+package breakout
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Aloys-y/chat-go/db"
+	"github.com/Aloys-y/chat-go/membership"
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/Aloys-y/chat-go/signaling"
+
+	"gorm.io/gorm"
+)
+
+// DefaultCountdown is how long participants are warned before the breakouts close
+const DefaultCountdown = 30 * time.Second
+
+var (
+	ErrNoBreakouts    = errors.New("at least one breakout is required")
+	ErrAlreadyOpen    = errors.New("the room already has breakouts")
+	ErrNotOpen        = errors.New("the room has no breakouts")
+	ErrIsBreakout     = errors.New("a breakout can't have breakouts of its own")
+	ErrNotParticipant = errors.New("only participants of the live room can be assigned")
+	ErrAssignedTwice  = errors.New("a participant can only be assigned to one breakout")
+	ErrNoParticipants = errors.New("the live room has no participants to assign")
+)
+
+// Breakout is a temporary sub-room of a live room
+type Breakout struct {
+	Name    string `json:"name"`
+	RoomID  uint   `json:"room_id"`
+	UserIDs []uint `json:"user_ids"`
+}
+
+// Session is the set of breakouts open for a room. EndsAt is when they close
+// on their own, or when the closing countdown runs out; it is zero when the
+// breakouts stay open until closed.
+type Session struct {
+	ParentID  uint
+	OwnerID   uint
+	Breakouts []Breakout
+	EndsAt    time.Time
+	Closing   bool
+
+	timer *time.Timer
+}
+
+var (
+	sessions    = make(map[uint]*Session)
+	sessionsMux sync.Mutex
+)
+
+// Create opens breakouts for the live room and moves the assigned
+// participants into them. With random set, the participants other than the
+// owner are spread evenly over the breakouts. A positive duration starts the
+// closing countdown when it runs out.
+func Create(parent *models.Room, breakouts []Breakout, random bool, duration time.Duration) (*Session, error) {
+	if parent.ParentID != nil {
+		return nil, ErrIsBreakout
+	}
+	if len(breakouts) == 0 {
+		return nil, ErrNoBreakouts
+	}
+
+	live, err := signaling.LiveParticipants(parent.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list participants: %w", err)
+	}
+	if err := assign(parent, breakouts, live, random); err != nil {
+		return nil, err
+	}
+
+	sessionsMux.Lock()
+	if _, exists := sessions[parent.ID]; exists {
+		sessionsMux.Unlock()
+		return nil, ErrAlreadyOpen
+	}
+	sess := &Session{ParentID: parent.ID, OwnerID: parent.OwnerID}
+	sessions[parent.ID] = sess
+	sessionsMux.Unlock()
+
+	if err := createRooms(parent, breakouts); err != nil {
+		sessionsMux.Lock()
+		delete(sessions, parent.ID)
+		sessionsMux.Unlock()
+		return nil, err
+	}
+
+	sessionsMux.Lock()
+	sess.Breakouts = breakouts
+	if duration > 0 {
+		sess.EndsAt = time.Now().Add(duration)
+		sess.timer = time.AfterFunc(duration, func() {
+			if _, err := Close(parent.ID, DefaultCountdown); err != nil && !errors.Is(err, ErrNotOpen) {
+				log.Printf("Failed to close breakouts of room %d: %v", parent.ID, err)
+			}
+		})
+	}
+	snapshot := sess.snapshot()
+	sessionsMux.Unlock()
+
+	opened := map[string]interface{}{"breakouts": breakouts}
+	if !snapshot.EndsAt.IsZero() {
+		opened["ends_at"] = snapshot.EndsAt
+	}
+	signaling.NotifyRoom(parent.ID, "breakouts_opened", opened)
+
+	for _, b := range breakouts {
+		for _, userID := range b.UserIDs {
+			signaling.MoveToRoom(userID, parent.ID, b.RoomID, parent.OwnerID)
+		}
+	}
+
+	log.Printf("Opened %d breakouts for room %d", len(breakouts), parent.ID)
+	return snapshot, nil
+}
+
+// Close warns the participants of the room and its breakouts, then brings
+// everyone back to the room when the countdown runs out. Closing breakouts
+// that are already closing returns the running countdown.
+func Close(parentID uint, countdown time.Duration) (*Session, error) {
+	sessionsMux.Lock()
+	sess, exists := sessions[parentID]
+	if !exists || sess.Breakouts == nil {
+		sessionsMux.Unlock()
+		return nil, ErrNotOpen
+	}
+	if sess.Closing {
+		snapshot := sess.snapshot()
+		sessionsMux.Unlock()
+		return snapshot, nil
+	}
+
+	if sess.timer != nil {
+		sess.timer.Stop()
+	}
+	sess.Closing = true
+	sess.EndsAt = time.Now().Add(countdown)
+	sess.timer = time.AfterFunc(countdown, func() { finish(parentID) })
+	snapshot := sess.snapshot()
+	sessionsMux.Unlock()
+
+	closing := map[string]interface{}{
+		"seconds":   int(countdown / time.Second),
+		"closes_at": snapshot.EndsAt,
+	}
+	signaling.NotifyRoom(parentID, "breakouts_closing", closing)
+	for _, b := range snapshot.Breakouts {
+		signaling.NotifyRoom(b.RoomID, "breakouts_closing", closing)
+	}
+
+	return snapshot, nil
+}
+
+// Discard drops the room's breakouts without bringing anyone back, when the
+// room itself goes away. It returns the breakout rooms.
+func Discard(parentID uint) []uint {
+	sessionsMux.Lock()
+	sess, exists := sessions[parentID]
+	if exists {
+		if sess.timer != nil {
+			sess.timer.Stop()
+		}
+		delete(sessions, parentID)
+	}
+	sessionsMux.Unlock()
+
+	if !exists {
+		return nil
+	}
+	roomIDs := make([]uint, 0, len(sess.Breakouts))
+	for _, b := range sess.Breakouts {
+		roomIDs = append(roomIDs, b.RoomID)
+	}
+	return roomIDs
+}
+
+// finish moves everyone left in the breakouts back to the room and deletes them
+func finish(parentID uint) {
+	sessionsMux.Lock()
+	sess, exists := sessions[parentID]
+	if exists {
+		delete(sessions, parentID)
+	}
+	sessionsMux.Unlock()
+
+	if !exists {
+		return
+	}
+
+	for _, b := range sess.Breakouts {
+		live, err := signaling.LiveParticipants(b.RoomID)
+		if err != nil {
+			log.Printf("Failed to list participants of breakout %d: %v", b.RoomID, err)
+			continue
+		}
+		for _, userID := range live {
+			signaling.MoveToRoom(userID, b.RoomID, parentID, sess.OwnerID)
+		}
+	}
+
+	if err := membership.DeleteBreakouts(parentID); err != nil {
+		log.Printf("Failed to delete breakouts of room %d: %v", parentID, err)
+	}
+
+	signaling.NotifyRoom(parentID, "breakouts_closed", map[string]interface{}{
+		"room_id": parentID,
+	})
+	log.Printf("Closed breakouts of room %d", parentID)
+}
+
+// assign checks the requested assignments against the live participants, or
+// spreads them over the breakouts when random is set
+func assign(parent *models.Room, breakouts []Breakout, live []uint, random bool) error {
+	for i := range breakouts {
+		if breakouts[i].Name == "" {
+			breakouts[i].Name = fmt.Sprintf("Breakout %d", i+1)
+		}
+	}
+
+	if random {
+		users := make([]uint, 0, len(live))
+		for _, userID := range live {
+			if userID != parent.OwnerID {
+				users = append(users, userID)
+			}
+		}
+		if len(users) == 0 {
+			return ErrNoParticipants
+		}
+
+		rand.Shuffle(len(users), func(i, j int) { users[i], users[j] = users[j], users[i] })
+		for i := range breakouts {
+			breakouts[i].UserIDs = nil
+		}
+		for i, userID := range users {
+			b := &breakouts[i%len(breakouts)]
+			b.UserIDs = append(b.UserIDs, userID)
+		}
+		return nil
+	}
+
+	inRoom := make(map[uint]bool, len(live))
+	for _, userID := range live {
+		inRoom[userID] = true
+	}
+	assigned := make(map[uint]bool)
+	for _, b := range breakouts {
+		for _, userID := range b.UserIDs {
+			if !inRoom[userID] {
+				return ErrNotParticipant
+			}
+			if assigned[userID] {
+				return ErrAssignedTwice
+			}
+			assigned[userID] = true
+		}
+	}
+	return nil
+}
+
+// createRooms stores the breakouts as private rooms of the owner and the
+// assigned participants, with the media settings of the parent room
+func createRooms(parent *models.Room, breakouts []Breakout) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range breakouts {
+			b := &breakouts[i]
+			room := &models.Room{
+				Name:        fmt.Sprintf("%s / %s", parent.Name, b.Name),
+				IsPublic:    false,
+				OwnerID:     parent.OwnerID,
+				MediaMode:   parent.MediaMode,
+				VideoPolicy: parent.VideoPolicy,
+				CodecPolicy: parent.CodecPolicy,
+				ParentID:    &parent.ID,
+				// Breakouts are as private as the main room
+				E2EERequired: parent.E2EERequired,
+			}
+			if err := tx.Create(room).Error; err != nil {
+				return fmt.Errorf("failed to create breakout %q: %w", b.Name, err)
+			}
+
+			var users []*models.User
+			if err := tx.Find(&users, append([]uint{parent.OwnerID}, b.UserIDs...)).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(room).Association("Users").Append(users); err != nil {
+				return err
+			}
+			b.RoomID = room.ID
+		}
+		return nil
+	})
+}
+
+// snapshot copies the session for callers outside the lock. The caller must
+// hold sessionsMux.
+func (s *Session) snapshot() *Session {
+	return &Session{
+		ParentID:  s.ParentID,
+		OwnerID:   s.OwnerID,
+		Breakouts: append([]Breakout(nil), s.Breakouts...),
+		EndsAt:    s.EndsAt,
+		Closing:   s.Closing,
+	}
+}
@@ -0,0 +1,182 @@
+// Package broadcast streams the audio of SFU rooms to large audiences over
+// HLS. The server mixes a room and cuts the mix into fragmented MP4 segments
+// kept in memory; listeners fetch the playlist and segments from the HTTP
+// port through URLs signed with the server's secret. Broadcasts live on the
+// node hosting the SFU room.
+package broadcast
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Aloys-y/chat-go/config"
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/Aloys-y/chat-go/sfu"
+	"github.com/Aloys-y/chat-go/signaling"
+)
+
+const (
+	// defaultSegmentDuration is used when broadcast.segment_duration is
+	// missing or invalid
+	defaultSegmentDuration = 2 * time.Second
+	// defaultPlaylistLength is used when broadcast.playlist_length is not
+	// configured
+	defaultPlaylistLength = 6
+	// defaultURLTTL is used when broadcast.url_ttl is missing or invalid
+	defaultURLTTL = 6 * time.Hour
+)
+
+var (
+	ErrNotSFU      = errors.New("only SFU rooms can be broadcast")
+	ErrEncrypted   = errors.New("end-to-end encrypted rooms can't be broadcast")
+	ErrInvalidTTL  = errors.New("signed URLs can't be valid for longer than broadcast.url_ttl")
+	errInvalidSign = errors.New("invalid or expired signature")
+
+	streams    = make(map[uint]*stream)
+	streamsMux sync.Mutex
+)
+
+// Info describes a broadcast along with a signed playlist URL for listeners
+type Info struct {
+	RoomID      uint
+	StartedBy   uint
+	StartedAt   time.Time
+	PlaylistURL string
+	ExpiresAt   time.Time
+}
+
+// segmentDuration returns the target length of segments
+func segmentDuration() time.Duration {
+	d, err := time.ParseDuration(config.AppConfig.Broadcast.SegmentDuration)
+	if err != nil || d < time.Second {
+		return defaultSegmentDuration
+	}
+	return d
+}
+
+// playlistLength returns how many segments a playlist lists
+func playlistLength() int {
+	if n := config.AppConfig.Broadcast.PlaylistLength; n > 0 {
+		return n
+	}
+	return defaultPlaylistLength
+}
+
+// URLTTL returns the lifetime of signed playlist URLs
+func URLTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.AppConfig.Broadcast.URLTTL)
+	if err != nil || ttl <= 0 {
+		return defaultURLTTL
+	}
+	return ttl
+}
+
+// publicURL returns the base URL listeners reach the HTTP port at
+func publicURL() string {
+	if url := config.AppConfig.Broadcast.PublicURL; url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return fmt.Sprintf("http://localhost:%d", config.AppConfig.Server.HTTPPort)
+}
+
+// Start begins broadcasting the live SFU room
+func Start(room *models.Room, startedBy uint) (Info, error) {
+	if room.MediaMode != models.MediaModeSFU {
+		return Info{}, ErrNotSFU
+	}
+	if room.E2EERequired || signaling.RoomEncrypted(room.ID) {
+		return Info{}, ErrEncrypted
+	}
+
+	s := newStream(room.ID, startedBy, segmentDuration(), playlistLength())
+	if err := sfu.StartBroadcast(room.ID, s); err != nil {
+		return Info{}, err
+	}
+	// A participant who encrypts may have joined meanwhile, before joinRoom
+	// could see the broadcast to stop it
+	if signaling.RoomEncrypted(room.ID) {
+		sfu.StopBroadcast(room.ID)
+		return Info{}, ErrEncrypted
+	}
+
+	streamsMux.Lock()
+	streams[room.ID] = s
+	streamsMux.Unlock()
+	return s.info(URLTTL()), nil
+}
+
+// Stop ends the room's broadcast. Listeners get the end of the playlist.
+func Stop(roomID uint) (Info, error) {
+	s := liveStream(roomID)
+	if err := sfu.StopBroadcast(roomID); err != nil {
+		return Info{}, err
+	}
+	if s == nil {
+		return Info{RoomID: roomID}, nil
+	}
+	return s.info(0), nil
+}
+
+// Get describes the room's broadcast with a playlist URL valid for ttl, or
+// for broadcast.url_ttl when ttl is 0
+func Get(roomID uint, ttl time.Duration) (Info, error) {
+	if ttl < 0 || ttl > URLTTL() {
+		return Info{}, ErrInvalidTTL
+	}
+	if ttl == 0 {
+		ttl = URLTTL()
+	}
+
+	s := liveStream(roomID)
+	if s == nil {
+		return Info{}, sfu.ErrNotBroadcasting
+	}
+	return s.info(ttl), nil
+}
+
+// liveStream returns the room's stream unless it ended
+func liveStream(roomID uint) *stream {
+	streamsMux.Lock()
+	s := streams[roomID]
+	streamsMux.Unlock()
+
+	if s == nil || s.isEnded() {
+		return nil
+	}
+	return s
+}
+
+// PlaylistURL returns the playlist URL of the room signed until expiresAt
+func PlaylistURL(roomID uint, expiresAt time.Time) string {
+	return fmt.Sprintf("%s/hls/%d/playlist.m3u8?%s", publicURL(), roomID, signedQuery(roomID, expiresAt.Unix()))
+}
+
+// signedQuery is the query string granting access to the room's broadcast
+// until expires
+func signedQuery(roomID uint, expires int64) string {
+	return fmt.Sprintf("expires=%d&sig=%s", expires, sign(roomID, expires))
+}
+
+// sign computes base64url(HMAC-SHA256(secret, "hls:<room id>:<expires>"))
+func sign(roomID uint, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.Auth.SecretKey))
+	fmt.Fprintf(mac, "hls:%d:%d", roomID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks the signature of a request for the room's broadcast
+func verify(roomID uint, expires int64, sig string) error {
+	if time.Now().Unix() > expires {
+		return errInvalidSign
+	}
+	if !hmac.Equal([]byte(sig), []byte(sign(roomID, expires))) {
+		return errInvalidSign
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package broadcast
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// frameDuration is the length of the Opus frames of the room mix
+const frameDuration = 20 * time.Millisecond
+
+// stream cuts the mix of a broadcast room into HLS segments, keeping the
+// last few of them for the playlist
+type stream struct {
+	roomID    uint
+	startedBy uint
+	startedAt time.Time
+
+	segmentFrames int
+	windowSize    int
+	init          []byte
+
+	mux        sync.Mutex
+	frames     [][]byte
+	segments   []*segment
+	nextSeq    int
+	decodeTime uint64
+	ended      bool
+}
+
+// segment is one fragmented MP4 segment of a stream
+type segment struct {
+	seq      int
+	duration time.Duration
+	data     []byte
+}
+
+func newStream(roomID, startedBy uint, segmentDuration time.Duration, windowSize int) *stream {
+	return &stream{
+		roomID:        roomID,
+		startedBy:     startedBy,
+		startedAt:     time.Now(),
+		segmentFrames: int(segmentDuration / frameDuration),
+		windowSize:    windowSize,
+		init:          initSegment(),
+	}
+}
+
+// info describes the stream, with a playlist URL valid for ttl when ttl is
+// set
+func (s *stream) info(ttl time.Duration) Info {
+	info := Info{
+		RoomID:    s.roomID,
+		StartedBy: s.startedBy,
+		StartedAt: s.startedAt,
+	}
+	if ttl > 0 {
+		info.ExpiresAt = time.Now().Add(ttl)
+		info.PlaylistURL = PlaylistURL(s.roomID, info.ExpiresAt)
+	}
+	return info
+}
+
+// WriteFrame implements sfu.BroadcastSink
+func (s *stream) WriteFrame(frame []byte) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.ended {
+		return
+	}
+	s.frames = append(s.frames, frame)
+	if len(s.frames) >= s.segmentFrames {
+		s.cutLocked()
+	}
+}
+
+// Close implements sfu.BroadcastSink. The last frames become a final segment
+// and the playlist is ended; the stream is dropped once listeners had time
+// to fetch it.
+func (s *stream) Close() {
+	s.mux.Lock()
+	if len(s.frames) > 0 {
+		s.cutLocked()
+	}
+	s.ended = true
+	linger := time.Duration(s.windowSize*s.segmentFrames) * frameDuration
+	s.mux.Unlock()
+
+	time.AfterFunc(linger, func() {
+		streamsMux.Lock()
+		if streams[s.roomID] == s {
+			delete(streams, s.roomID)
+		}
+		streamsMux.Unlock()
+	})
+}
+
+// isEnded reports whether the broadcast of the stream stopped
+func (s *stream) isEnded() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.ended
+}
+
+// cutLocked turns the pending frames into a segment, dropping the oldest
+// segment once the window is full. The caller must hold s.mux.
+func (s *stream) cutLocked() {
+	seg := &segment{
+		seq:      s.nextSeq,
+		duration: time.Duration(len(s.frames)) * frameDuration,
+		data:     mediaSegment(uint32(s.nextSeq+1), s.decodeTime, s.frames),
+	}
+	s.nextSeq++
+	s.decodeTime += uint64(len(s.frames)) * samplesPerFrame
+	s.frames = nil
+
+	s.segments = append(s.segments, seg)
+	if len(s.segments) > s.windowSize {
+		s.segments = s.segments[1:]
+	}
+}
+
+// playlist renders the live media playlist. URIs carry the signed query of
+// the request so players can fetch them.
+func (s *stream) playlist(query string) []byte {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	target := int(math.Ceil((time.Duration(s.segmentFrames) * frameDuration).Seconds()))
+	first := s.nextSeq
+	if len(s.segments) > 0 {
+		first = s.segments[0].seq
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:7\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", target)
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", first)
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	fmt.Fprintf(&b, "#EXT-X-MAP:URI=\"init.mp4?%s\"\n", query)
+	for _, seg := range s.segments {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n", seg.duration.Seconds())
+		fmt.Fprintf(&b, "seg-%d.m4s?%s\n", seg.seq, query)
+	}
+	if s.ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return []byte(b.String())
+}
+
+// segment returns a segment still in the window, or nil
+func (s *stream) segment(seq int) []byte {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, seg := range s.segments {
+		if seg.seq == seq {
+			return seg.data
+		}
+	}
+	return nil
+}
+
+// StartHTTPServer serves the playlists and segments of broadcasts on the
+// HTTP port
+func StartHTTPServer(port int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /hls/{room}/{file}", handleHLS)
+	addr := fmt.Sprintf(":%d", port)
+	log.Printf("HTTP server starting on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
+
+// handleHLS serves a file of a broadcast to a listener holding a signed URL
+func handleHLS(w http.ResponseWriter, r *http.Request) {
+	// Players on other origins fetch the stream too
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	roomID, err := strconv.ParseUint(r.PathValue("room"), 10, 32)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err == nil {
+		err = verify(uint(roomID), expires, query.Get("sig"))
+	}
+	if err != nil {
+		http.Error(w, errInvalidSign.Error(), http.StatusForbidden)
+		return
+	}
+
+	streamsMux.Lock()
+	s := streams[uint(roomID)]
+	streamsMux.Unlock()
+	if s == nil {
+		http.Error(w, "room is not being broadcast", http.StatusNotFound)
+		return
+	}
+
+	file := r.PathValue("file")
+	switch {
+	case file == "playlist.m3u8":
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(s.playlist(signedQuery(uint(roomID), expires)))
+	case file == "init.mp4":
+		w.Header().Set("Content-Type", "audio/mp4")
+		w.Write(s.init)
+	case strings.HasPrefix(file, "seg-") && strings.HasSuffix(file, ".m4s"):
+		seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(file, "seg-"), ".m4s"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		data := s.segment(seq)
+		if data == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "audio/mp4")
+		w.Write(data)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,165 @@
+package broadcast
+
+import (
+	"encoding/binary"
+)
+
+const (
+	// timescale is the sample rate of the mix, the time unit of the track
+	timescale = 48000
+	// samplesPerFrame is the number of samples in a frame of the mix
+	samplesPerFrame = timescale / 50
+	// opusPreSkip is the encoder delay of libopus at 48kHz, which players
+	// drop from the start of the stream
+	opusPreSkip = 312
+	// trackID is the ID of the only track of the stream
+	trackID = 1
+)
+
+// unityMatrix is the identity transformation of movie and track headers
+var unityMatrix = []uint32{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000}
+
+// box builds an ISO BMFF box of the given type around its payload
+func box(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	b := make([]byte, 0, size)
+	b = binary.BigEndian.AppendUint32(b, uint32(size))
+	b = append(b, typ...)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// fullBox builds a box whose payload starts with a version and flags
+func fullBox(typ string, version uint8, flags uint32, payload ...[]byte) []byte {
+	header := binary.BigEndian.AppendUint32(nil, uint32(version)<<24|flags)
+	return box(typ, append([][]byte{header}, payload...)...)
+}
+
+// fields packs big-endian integers; uint8, uint16, uint32 and uint64 are
+// written at their size
+func fields(values ...interface{}) []byte {
+	var b []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case uint8:
+			b = append(b, v)
+		case uint16:
+			b = binary.BigEndian.AppendUint16(b, v)
+		case uint32:
+			b = binary.BigEndian.AppendUint32(b, v)
+		case uint64:
+			b = binary.BigEndian.AppendUint64(b, v)
+		case []uint32:
+			for _, x := range v {
+				b = binary.BigEndian.AppendUint32(b, x)
+			}
+		case []byte:
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+// initSegment builds the initialization segment of a stream: one mono Opus
+// track at 48kHz whose samples all come in fragments
+func initSegment() []byte {
+	ftyp := box("ftyp", []byte("iso6"), fields(uint32(0)), []byte("iso6cmfcmp41"))
+
+	mvhd := fullBox("mvhd", 0, 0, fields(
+		uint32(0), uint32(0), // creation and modification time
+		uint32(1000), uint32(0), // timescale and duration
+		uint32(0x00010000), uint16(0x0100), // rate and volume
+		make([]byte, 10), unityMatrix, make([]byte, 24),
+		uint32(trackID+1), // next track ID
+	))
+
+	tkhd := fullBox("tkhd", 0, 0x3, fields(
+		uint32(0), uint32(0), // creation and modification time
+		uint32(trackID), uint32(0), uint32(0), // track ID, reserved, duration
+		make([]byte, 8),
+		uint16(0), uint16(0), // layer and alternate group
+		uint16(0x0100), uint16(0), // volume, reserved
+		unityMatrix,
+		uint32(0), uint32(0), // width and height
+	))
+
+	mdhd := fullBox("mdhd", 0, 0, fields(
+		uint32(0), uint32(0), // creation and modification time
+		uint32(timescale), uint32(0), // timescale and duration
+		uint16(0x55c4), uint16(0), // language "und"
+	))
+	hdlr := fullBox("hdlr", 0, 0, fields(
+		uint32(0), []byte("soun"), make([]byte, 12), []byte("SoundHandler\x00"),
+	))
+
+	// The Opus sample entry and its dOps box, as specified by "Encapsulation
+	// of Opus in ISO Base Media File Format"
+	dOps := box("dOps", fields(
+		uint8(0), uint8(1), // version and output channel count
+		uint16(opusPreSkip), uint32(timescale), // pre-skip and input sample rate
+		uint16(0), uint8(0), // output gain and channel mapping family
+	))
+	opus := box("Opus", fields(
+		make([]byte, 6), uint16(1), // reserved, data reference index
+		make([]byte, 8),
+		uint16(1), uint16(16), // channel count and sample size
+		uint16(0), uint16(0), // pre-defined, reserved
+		uint32(timescale<<16),
+	), dOps)
+
+	stbl := box("stbl",
+		fullBox("stsd", 0, 0, fields(uint32(1)), opus),
+		fullBox("stts", 0, 0, fields(uint32(0))),
+		fullBox("stsc", 0, 0, fields(uint32(0))),
+		fullBox("stsz", 0, 0, fields(uint32(0), uint32(0))),
+		fullBox("stco", 0, 0, fields(uint32(0))),
+	)
+	dinf := box("dinf", fullBox("dref", 0, 0, fields(uint32(1)), fullBox("url ", 0, 1)))
+	minf := box("minf", fullBox("smhd", 0, 0, fields(uint16(0), uint16(0))), dinf, stbl)
+
+	trex := fullBox("trex", 0, 0, fields(
+		uint32(trackID), uint32(1), // track ID, sample description index
+		uint32(samplesPerFrame), uint32(0), uint32(0), // default duration, size and flags
+	))
+
+	moov := box("moov", mvhd,
+		box("trak", tkhd, box("mdia", mdhd, hdlr, minf)),
+		box("mvex", trex),
+	)
+	return append(ftyp, moov...)
+}
+
+// mediaSegment builds a segment holding the frames as one fragment starting
+// at decodeTime, in samples
+func mediaSegment(sequence uint32, decodeTime uint64, frames [][]byte) []byte {
+	moof := fragment(sequence, decodeTime, frames, 0)
+	// The samples start right after the moof and the mdat header
+	moof = fragment(sequence, decodeTime, frames, uint32(len(moof)+8))
+
+	return append(moof, box("mdat", frames...)...)
+}
+
+// fragment builds the moof box of a segment. dataOffset is where the first
+// sample starts, counted from the start of the moof.
+func fragment(sequence uint32, decodeTime uint64, frames [][]byte, dataOffset uint32) []byte {
+	// default-base-is-moof, default-sample-duration-present
+	tfhd := fullBox("tfhd", 0, 0x020008, fields(uint32(trackID), uint32(samplesPerFrame)))
+	tfdt := fullBox("tfdt", 1, 0, fields(decodeTime))
+
+	sizes := make([]uint32, len(frames))
+	for i, f := range frames {
+		sizes[i] = uint32(len(f))
+	}
+	// data-offset-present, sample-size-present
+	trun := fullBox("trun", 0, 0x000201, fields(uint32(len(frames)), dataOffset, sizes))
+
+	return box("moof",
+		fullBox("mfhd", 0, 0, fields(sequence)),
+		box("traf", tfhd, tfdt, trun),
+	)
+}
@@ -0,0 +1,182 @@
+package calls
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Aloys-y/chat-go/db"
+	"github.com/Aloys-y/chat-go/membership"
+	"github.com/Aloys-y/chat-go/models"
+
+	"gorm.io/gorm"
+)
+
+// History filters
+const (
+	FilterAll       = ""
+	FilterMissed    = "missed"
+	FilterCompleted = "completed"
+)
+
+var (
+	ErrCallNotFound    = errors.New("call not found")
+	ErrNotRinging      = errors.New("call is no longer ringing")
+	ErrUnknownFilter   = errors.New("unknown call history filter")
+	ErrSessionNotFound = errors.New("call session not found")
+)
+
+// Start records a call that starts ringing
+func Start(callerID, calleeID uint, video bool) (*models.CallLog, error) {
+	call := &models.CallLog{
+		CallerID:  callerID,
+		CalleeID:  calleeID,
+		Video:     video,
+		Status:    models.CallRinging,
+		StartedAt: time.Now(),
+	}
+	if err := db.DB.Create(call).Error; err != nil {
+		return nil, fmt.Errorf("failed to create call: %w", err)
+	}
+	return call, nil
+}
+
+// Get loads a call
+func Get(callID uint) (*models.CallLog, error) {
+	var call models.CallLog
+	if err := db.DB.First(&call, callID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCallNotFound
+		}
+		return nil, err
+	}
+	return &call, nil
+}
+
+// Finish ends a ringing call without it being answered, as declined or
+// missed. Only the first of the callee's answer, the caller's cancel and the
+// ring timeout wins; the others get ErrNotRinging.
+func Finish(callID uint, status string) error {
+	now := time.Now()
+	result := db.DB.Model(&models.CallLog{}).
+		Where("id = ? AND status = ?", callID, models.CallRinging).
+		Updates(map[string]interface{}{
+			"status":   status,
+			"ended_at": &now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotRinging
+	}
+	return nil
+}
+
+// Answer marks the ringing call answered and creates the private room the
+// two users talk in
+func Answer(callID uint) (*models.CallLog, *models.Room, error) {
+	var call models.CallLog
+	var room *models.Room
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.CallLog{}).
+			Where("id = ? AND status = ?", callID, models.CallRinging).
+			Updates(map[string]interface{}{
+				"status":      models.CallAnswered,
+				"answered_at": &now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotRinging
+		}
+		if err := tx.First(&call, callID).Error; err != nil {
+			return err
+		}
+
+		room = &models.Room{
+			Name:        fmt.Sprintf("call-%d", call.ID),
+			IsPublic:    false,
+			OwnerID:     call.CallerID,
+			MediaMode:   models.MediaModeMesh,
+			CallID:      &call.ID,
+			CodecPolicy: membership.DefaultCodecPolicy(),
+		}
+		if err := tx.Create(room).Error; err != nil {
+			return fmt.Errorf("failed to create call room: %w", err)
+		}
+
+		var users []*models.User
+		if err := tx.Find(&users, []uint{call.CallerID, call.CalleeID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(room).Association("Users").Append(users); err != nil {
+			return err
+		}
+		return tx.Model(&call).Update("room_id", room.ID).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &call, room, nil
+}
+
+// End completes an answered call and deletes its room. It reports false when
+// the call had already ended.
+func End(callID uint) (bool, error) {
+	call, err := Get(callID)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	result := db.DB.Model(&models.CallLog{}).
+		Where("id = ? AND status = ?", callID, models.CallAnswered).
+		Updates(map[string]interface{}{
+			"status":   models.CallCompleted,
+			"ended_at": &now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	if call.RoomID != nil {
+		if err := membership.DeleteRoom(*call.RoomID); err != nil {
+			return true, fmt.Errorf("failed to delete call room: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// History lists the user's calls, newest first. FilterMissed keeps the calls
+// the user missed as the callee; FilterCompleted the answered calls that ended.
+func History(userID uint, filter string, page, pageSize int) ([]models.CallLog, int64, error) {
+	query := db.DB.Model(&models.CallLog{})
+	switch filter {
+	case FilterAll:
+		query = query.Where("caller_id = ? OR callee_id = ?", userID, userID)
+	case FilterMissed:
+		query = query.Where("callee_id = ? AND status = ?", userID, models.CallMissed)
+	case FilterCompleted:
+		query = query.Where("(caller_id = ? OR callee_id = ?) AND status = ?", userID, userID, models.CallCompleted)
+	default:
+		return nil, 0, ErrUnknownFilter
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []models.CallLog
+	err := query.Order("started_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&logs).Error
+	return logs, total, err
+}
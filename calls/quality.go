@@ -0,0 +1,57 @@
+package calls
+
+import (
+	"github.com/Aloys-y/chat-go/db"
+	"github.com/Aloys-y/chat-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecordQuality adds a participant's stats report to their aggregate for the
+// call session
+func RecordQuality(sessionID, userID uint, s models.QualitySample) error {
+	quality := &models.CallQuality{
+		SessionID:       sessionID,
+		UserID:          userID,
+		Reports:         1,
+		RTTSumMs:        s.RTTMs,
+		RTTMaxMs:        s.RTTMs,
+		JitterSumMs:     s.JitterMs,
+		JitterMaxMs:     s.JitterMs,
+		PacketLossSum:   s.PacketLoss,
+		PacketLossMax:   s.PacketLoss,
+		InboundKbpsSum:  s.InboundKbps,
+		OutboundKbpsSum: s.OutboundKbps,
+	}
+	return db.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "session_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"reports":           gorm.Expr("reports + 1"),
+			"rtt_sum_ms":        gorm.Expr("rtt_sum_ms + ?", s.RTTMs),
+			"rtt_max_ms":        gorm.Expr("GREATEST(rtt_max_ms, ?)", s.RTTMs),
+			"jitter_sum_ms":     gorm.Expr("jitter_sum_ms + ?", s.JitterMs),
+			"jitter_max_ms":     gorm.Expr("GREATEST(jitter_max_ms, ?)", s.JitterMs),
+			"packet_loss_sum":   gorm.Expr("packet_loss_sum + ?", s.PacketLoss),
+			"packet_loss_max":   gorm.Expr("GREATEST(packet_loss_max, ?)", s.PacketLoss),
+			"inbound_kbps_sum":  gorm.Expr("inbound_kbps_sum + ?", s.InboundKbps),
+			"outbound_kbps_sum": gorm.Expr("outbound_kbps_sum + ?", s.OutboundKbps),
+			"updated_at":        gorm.Expr("NOW()"),
+		}),
+	}).Create(quality).Error
+}
+
+// Quality loads the call session and the stats aggregates of its
+// participants
+func Quality(sessionID uint) (*models.CallSession, []models.CallQuality, error) {
+	session, err := GetSession(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var qualities []models.CallQuality
+	err = db.DB.Where("session_id = ?", sessionID).
+		Order("user_id").
+		Find(&qualities).Error
+	return session, qualities, err
+}
@@ -0,0 +1,230 @@
+package calls
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Aloys-y/chat-go/db"
+	"github.com/Aloys-y/chat-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JoinSession records the user joining the room's live session on nodeID,
+// starting a new session when nobody is in it, and returns the session ID.
+// Every node records its own joins, so the session spans the whole cluster.
+func JoinSession(roomID, userID uint, nodeID string) (uint, error) {
+	var sessionID uint
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		session, err := openSession(tx, roomID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			session = &models.CallSession{RoomID: roomID, StartedAt: now}
+			err = tx.Create(session).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to start call session: %w", err)
+		}
+		sessionID = session.ID
+
+		// An interval left open by a connection that went away without
+		// leaving ends here
+		if err := closeIntervals(tx, session.ID, userID, now); err != nil {
+			return err
+		}
+		participant := &models.CallParticipant{
+			SessionID: session.ID,
+			UserID:    userID,
+			NodeID:    nodeID,
+			JoinedAt:  now,
+			SeenAt:    now,
+		}
+		if err := tx.Create(participant).Error; err != nil {
+			return fmt.Errorf("failed to record call participant: %w", err)
+		}
+
+		present, err := countPresent(tx, session.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.CallSession{}).
+			Where("id = ? AND peak_participants < ?", session.ID, present).
+			Update("peak_participants", present).Error
+	})
+	return sessionID, err
+}
+
+// LeaveSession records the user leaving the room's live session and ends the
+// session when they were the last one in it
+func LeaveSession(roomID, userID uint) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		session, err := openSession(tx, roomID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := closeIntervals(tx, session.ID, userID, now); err != nil {
+			return err
+		}
+		present, err := countPresent(tx, session.ID)
+		if err != nil || present > 0 {
+			return err
+		}
+		return tx.Model(session).Update("ended_at", &now).Error
+	})
+}
+
+// TouchIntervals marks the open intervals recorded by the node as still going
+// on, on every heartbeat of the node
+func TouchIntervals(nodeID string, at time.Time) error {
+	return db.DB.Model(&models.CallParticipant{}).
+		Where("node_id = ? AND left_at IS NULL", nodeID).
+		Update("seen_at", at).Error
+}
+
+// CloseStaleIntervals ends the open intervals of nodes that stopped sending
+// heartbeats before the given time, e.g. because they crashed. Each interval
+// ends at the last heartbeat of its node, and sessions nobody is left in end
+// with their last interval.
+func CloseStaleIntervals(before time.Time) error {
+	var sessionIDs []uint
+	err := db.DB.Model(&models.CallParticipant{}).
+		Where("left_at IS NULL AND seen_at < ?", before).
+		Distinct().
+		Pluck("session_id", &sessionIDs).Error
+	if err != nil {
+		return fmt.Errorf("failed to find stale call participants: %w", err)
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err := closeStaleSession(sessionID, before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeStaleSession ends the stale intervals of one session, locking its room
+// like joins and leaves do
+func closeStaleSession(sessionID uint, before time.Time) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var session models.CallSession
+		if err := tx.First(&session, sessionID).Error; err != nil {
+			return err
+		}
+		if err := lockRoom(tx, session.RoomID); err != nil {
+			return err
+		}
+
+		err := tx.Model(&models.CallParticipant{}).
+			Where("session_id = ? AND left_at IS NULL AND seen_at < ?", sessionID, before).
+			Update("left_at", gorm.Expr("seen_at")).Error
+		if err != nil {
+			return fmt.Errorf("failed to close stale call participants: %w", err)
+		}
+		if session.EndedAt != nil {
+			return nil
+		}
+
+		present, err := countPresent(tx, sessionID)
+		if err != nil || present > 0 {
+			return err
+		}
+		var endedAt time.Time
+		err = tx.Model(&models.CallParticipant{}).
+			Where("session_id = ?", sessionID).
+			Select("MAX(left_at)").
+			Scan(&endedAt).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&session).Update("ended_at", &endedAt).Error
+	})
+}
+
+// GetSession loads a call session
+func GetSession(sessionID uint) (*models.CallSession, error) {
+	var session models.CallSession
+	if err := db.DB.First(&session, sessionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Sessions lists the room's call sessions with their participants, newest
+// first
+func Sessions(roomID uint, page, pageSize int) ([]models.CallSession, int64, error) {
+	query := db.DB.Model(&models.CallSession{}).Where("room_id = ?", roomID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var sessions []models.CallSession
+	err := query.Order("started_at DESC").
+		Offset((page-1)*pageSize).
+		Limit(pageSize).
+		Preload("Participants", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("joined_at")
+		}).
+		Find(&sessions).Error
+	return sessions, total, err
+}
+
+// openSession loads the session of the room that hasn't ended yet. It locks
+// the room's row until the transaction ends, so concurrent joins, possibly
+// on other nodes, can't each start a session of their own.
+func openSession(tx *gorm.DB, roomID uint) (*models.CallSession, error) {
+	if err := lockRoom(tx, roomID); err != nil {
+		return nil, err
+	}
+
+	var session models.CallSession
+	err := tx.Where("room_id = ? AND ended_at IS NULL", roomID).
+		Order("id DESC").
+		First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// lockRoom locks the room's row until the transaction ends. A room that was
+// deleted has nothing left to lock.
+func lockRoom(tx *gorm.DB, roomID uint) error {
+	var room models.Room
+	err := tx.Unscoped().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		First(&room, roomID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to lock room %d: %w", roomID, err)
+	}
+	return nil
+}
+
+// closeIntervals ends the user's open intervals in the session
+func closeIntervals(tx *gorm.DB, sessionID, userID uint, at time.Time) error {
+	return tx.Model(&models.CallParticipant{}).
+		Where("session_id = ? AND user_id = ? AND left_at IS NULL", sessionID, userID).
+		Update("left_at", &at).Error
+}
+
+// countPresent counts the participants currently in the session
+func countPresent(tx *gorm.DB, sessionID uint) (int, error) {
+	var count int64
+	err := tx.Model(&models.CallParticipant{}).
+		Where("session_id = ? AND left_at IS NULL", sessionID).
+		Count(&count).Error
+	return int(count), err
+}
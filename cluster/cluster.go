@@ -0,0 +1,77 @@
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"time"
+)
+
+// Handler receives messages published on a subscribed topic
+type Handler func(topic string, data []byte)
+
+// Broker is a pub/sub transport between chat-go nodes. Every node subscribes
+// to the topics it has local interest in and receives what any node,
+// including itself, publishes on them.
+type Broker interface {
+	Publish(ctx context.Context, topic string, data []byte) error
+	Subscribe(ctx context.Context, topics ...string) error
+	Unsubscribe(ctx context.Context, topics ...string) error
+	Close() error
+}
+
+// Presence is the cluster-wide registry of live nodes, connected users and
+// room members
+type Presence interface {
+	// Heartbeat marks the node as alive for ttl
+	Heartbeat(ctx context.Context, nodeID string, ttl time.Duration) error
+	// RemoveNode forgets the node right away, e.g. on shutdown
+	RemoveNode(ctx context.Context, nodeID string) error
+
+	// AddUserNode records that userID has a connection on nodeID
+	AddUserNode(ctx context.Context, userID uint, nodeID string) error
+	// RemoveUserNode removes the record added by AddUserNode
+	RemoveUserNode(ctx context.Context, userID uint, nodeID string) error
+	// UserNodes lists the live nodes userID is connected to
+	UserNodes(ctx context.Context, userID uint) ([]string, error)
+
+	// PutRoomMember stores the opaque state of a room member hosted on nodeID
+	PutRoomMember(ctx context.Context, roomID, userID uint, nodeID string, state []byte) error
+	// RemoveRoomMember removes a room member
+	RemoveRoomMember(ctx context.Context, roomID, userID uint) error
+	// RoomMembers returns the state of every member hosted on a live node
+	RoomMembers(ctx context.Context, roomID uint) (map[uint][]byte, error)
+
+	// PutLobbyMember stores the opaque state of a user waiting to enter the
+	// room, hosted on nodeID
+	PutLobbyMember(ctx context.Context, roomID, userID uint, nodeID string, state []byte) error
+	// RemoveLobbyMember removes a waiting user
+	RemoveLobbyMember(ctx context.Context, roomID, userID uint) error
+	// LobbyMembers returns the state of every waiting user hosted on a live node
+	LobbyMembers(ctx context.Context, roomID uint) (map[uint][]byte, error)
+
+	// ClaimFloor gives userID, hosted on nodeID, one of the max screen-share
+	// floors of the room unless users on live nodes hold them all. Claiming
+	// a floor the user holds succeeds. When the floors are taken it returns
+	// false and their holders.
+	ClaimFloor(ctx context.Context, roomID, userID uint, nodeID string, max int) (bool, []uint, error)
+	// ReleaseFloor gives up the user's screen-share floor
+	ReleaseFloor(ctx context.Context, roomID, userID uint) error
+
+	// NextRoomVersion atomically increments and returns the room version
+	NextRoomVersion(ctx context.Context, roomID uint) (uint64, error)
+	// RoomVersion returns the current room version
+	RoomVersion(ctx context.Context, roomID uint) (uint64, error)
+}
+
+// NewNodeID returns a node identifier made of the host name and a random suffix
+func NewNodeID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "node"
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
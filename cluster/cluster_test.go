@@ -0,0 +1,283 @@
+package cluster
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// deliveryTimeout bounds the wait for an asynchronous delivery
+const deliveryTimeout = 2 * time.Second
+
+// message is a delivery seen by a node's handler
+type message struct {
+	topic string
+	data  string
+}
+
+// node is a broker with the messages delivered to it
+type node struct {
+	broker   Broker
+	received chan message
+}
+
+func newNode(newBroker func(Handler) Broker) *node {
+	n := &node{received: make(chan message, 16)}
+	n.broker = newBroker(func(topic string, data []byte) {
+		n.received <- message{topic: topic, data: string(data)}
+	})
+	return n
+}
+
+// expect waits for the next delivery to the node
+func (n *node) expect(t *testing.T, name, topic, data string) {
+	t.Helper()
+	select {
+	case got := <-n.received:
+		if want := (message{topic: topic, data: data}); got != want {
+			t.Errorf("node %s got %+v, want %+v", name, got, want)
+		}
+	case <-time.After(deliveryTimeout):
+		t.Errorf("node %s got nothing on %s, want %q", name, topic, data)
+	}
+}
+
+// expectNothing checks that nothing was delivered to the node. Deliveries
+// are in order, so a later message reaching the other node first is enough
+// to know the unexpected one is not on its way.
+func (n *node) expectNothing(t *testing.T, name string) {
+	t.Helper()
+	select {
+	case got := <-n.received:
+		t.Errorf("node %s got %+v, want nothing", name, got)
+	default:
+	}
+}
+
+// testBroker runs two nodes created by newBroker side by side. subscribed
+// waits until the given number of nodes subscribe to a topic, for brokers
+// that subscribe asynchronously.
+func testBroker(t *testing.T, newBroker func(Handler) Broker, subscribed func(topic string, n int)) {
+	ctx := context.Background()
+	a, b := newNode(newBroker), newNode(newBroker)
+	defer a.broker.Close()
+	defer b.broker.Close()
+
+	// Both nodes host members of room 1, only b hosts user 7
+	for _, n := range []*node{a, b} {
+		if err := n.broker.Subscribe(ctx, "room:1"); err != nil {
+			t.Fatalf("Subscribe() failed: %v", err)
+		}
+	}
+	if err := b.broker.Subscribe(ctx, "user:7"); err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+	subscribed("room:1", 2)
+	subscribed("user:7", 1)
+
+	t.Run("room fan-out", func(t *testing.T) {
+		if err := a.broker.Publish(ctx, "room:1", []byte("joined")); err != nil {
+			t.Fatalf("Publish() failed: %v", err)
+		}
+		a.expect(t, "a", "room:1", "joined")
+		b.expect(t, "b", "room:1", "joined")
+	})
+
+	t.Run("direct user delivery", func(t *testing.T) {
+		if err := a.broker.Publish(ctx, "user:7", []byte("hello")); err != nil {
+			t.Fatalf("Publish() failed: %v", err)
+		}
+		b.expect(t, "b", "user:7", "hello")
+		a.expectNothing(t, "a")
+	})
+
+	t.Run("unsubscribed topic", func(t *testing.T) {
+		if err := b.broker.Unsubscribe(ctx, "room:1"); err != nil {
+			t.Fatalf("Unsubscribe() failed: %v", err)
+		}
+		subscribed("room:1", 1)
+
+		if err := a.broker.Publish(ctx, "room:1", []byte("left")); err != nil {
+			t.Fatalf("Publish() failed: %v", err)
+		}
+		a.expect(t, "a", "room:1", "left")
+		if err := a.broker.Publish(ctx, "user:7", []byte("still here")); err != nil {
+			t.Fatalf("Publish() failed: %v", err)
+		}
+		b.expect(t, "b", "user:7", "still here")
+		a.expectNothing(t, "a")
+	})
+}
+
+// testPresence runs the Presence contract against p. expire makes the
+// heartbeat of a node run out.
+func testPresence(t *testing.T, p Presence, expire func(nodeID string)) {
+	ctx := context.Background()
+	for _, nodeID := range []string{"a", "b"} {
+		if err := p.Heartbeat(ctx, nodeID, time.Minute); err != nil {
+			t.Fatalf("Heartbeat() failed: %v", err)
+		}
+	}
+
+	t.Run("room versions", func(t *testing.T) {
+		if v, err := p.RoomVersion(ctx, 1); err != nil || v != 0 {
+			t.Fatalf("RoomVersion() = %d, %v, want 0", v, err)
+		}
+		for want := uint64(1); want <= 3; want++ {
+			if v, err := p.NextRoomVersion(ctx, 1); err != nil || v != want {
+				t.Fatalf("NextRoomVersion() = %d, %v, want %d", v, err, want)
+			}
+		}
+		if v, err := p.RoomVersion(ctx, 1); err != nil || v != 3 {
+			t.Fatalf("RoomVersion() = %d, %v, want 3", v, err)
+		}
+		// Rooms are versioned independently
+		if v, err := p.RoomVersion(ctx, 2); err != nil || v != 0 {
+			t.Fatalf("RoomVersion() of another room = %d, %v, want 0", v, err)
+		}
+	})
+
+	t.Run("members", func(t *testing.T) {
+		mustPut(t, p.PutRoomMember(ctx, 1, 1, "a", []byte(`{"muted":false}`)))
+		mustPut(t, p.PutRoomMember(ctx, 1, 2, "b", []byte(`{"muted":true}`)))
+		mustPut(t, p.PutRoomMember(ctx, 1, 3, "b", []byte(`{}`)))
+		if err := p.RemoveRoomMember(ctx, 1, 3); err != nil {
+			t.Fatalf("RemoveRoomMember() failed: %v", err)
+		}
+
+		members, err := p.RoomMembers(ctx, 1)
+		if err != nil {
+			t.Fatalf("RoomMembers() failed: %v", err)
+		}
+		want := map[uint]string{1: `{"muted":false}`, 2: `{"muted":true}`}
+		if got := states(members); !reflect.DeepEqual(got, want) {
+			t.Errorf("RoomMembers() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("screen-share floor", func(t *testing.T) {
+		claim := func(userID uint, nodeID string, max int) (bool, []uint) {
+			t.Helper()
+			ok, holders, err := p.ClaimFloor(ctx, 1, userID, nodeID, max)
+			if err != nil {
+				t.Fatalf("ClaimFloor() failed: %v", err)
+			}
+			return ok, holders
+		}
+
+		if ok, _ := claim(1, "a", 1); !ok {
+			t.Fatal("ClaimFloor() of a free floor failed")
+		}
+		// Users on different nodes compete for the same floor
+		if ok, holders := claim(2, "b", 1); ok || !reflect.DeepEqual(holders, []uint{1}) {
+			t.Errorf("ClaimFloor() of a taken floor = %t, %v, want false, [1]", ok, holders)
+		}
+		if ok, _ := claim(1, "a", 1); !ok {
+			t.Error("ClaimFloor() of a held floor failed")
+		}
+		if ok, _ := claim(2, "b", 2); !ok {
+			t.Error("ClaimFloor() of the second of two floors failed")
+		}
+		if ok, holders := claim(3, "b", 2); ok || !reflect.DeepEqual(holders, []uint{1, 2}) {
+			t.Errorf("ClaimFloor() with both floors taken = %t, %v, want false, [1 2]", ok, holders)
+		}
+
+		if err := p.ReleaseFloor(ctx, 1, 2); err != nil {
+			t.Fatalf("ReleaseFloor() failed: %v", err)
+		}
+		if ok, _ := claim(3, "b", 2); !ok {
+			t.Error("ClaimFloor() of a released floor failed")
+		}
+		if err := p.ReleaseFloor(ctx, 1, 3); err != nil {
+			t.Fatalf("ReleaseFloor() failed: %v", err)
+		}
+	})
+
+	mustPut(t, p.PutLobbyMember(ctx, 1, 4, "a", []byte(`{"name":"a"}`)))
+	mustPut(t, p.PutLobbyMember(ctx, 1, 5, "b", []byte(`{"name":"b"}`)))
+	mustPut(t, p.AddUserNode(ctx, 1, "a"))
+	mustPut(t, p.AddUserNode(ctx, 2, "a"))
+	mustPut(t, p.AddUserNode(ctx, 2, "b"))
+
+	t.Run("user nodes", func(t *testing.T) {
+		if got := userNodes(t, p, 2); !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("UserNodes() = %v, want [a b]", got)
+		}
+		if err := p.RemoveUserNode(ctx, 2, "a"); err != nil {
+			t.Fatalf("RemoveUserNode() failed: %v", err)
+		}
+		if got := userNodes(t, p, 2); !reflect.DeepEqual(got, []string{"b"}) {
+			t.Errorf("UserNodes() = %v, want [b]", got)
+		}
+	})
+
+	t.Run("stale node", func(t *testing.T) {
+		// Node a stops heart-beating without cleaning up after itself
+		expire("a")
+
+		members, err := p.RoomMembers(ctx, 1)
+		if err != nil {
+			t.Fatalf("RoomMembers() failed: %v", err)
+		}
+		if got, want := states(members), map[uint]string{2: `{"muted":true}`}; !reflect.DeepEqual(got, want) {
+			t.Errorf("RoomMembers() = %v, want %v", got, want)
+		}
+		lobby, err := p.LobbyMembers(ctx, 1)
+		if err != nil {
+			t.Fatalf("LobbyMembers() failed: %v", err)
+		}
+		if got, want := states(lobby), map[uint]string{5: `{"name":"b"}`}; !reflect.DeepEqual(got, want) {
+			t.Errorf("LobbyMembers() = %v, want %v", got, want)
+		}
+		if got := userNodes(t, p, 1); len(got) != 0 {
+			t.Errorf("UserNodes() = %v, want none", got)
+		}
+		// User 1 still held the floor from node a
+		if ok, holders, err := p.ClaimFloor(ctx, 1, 2, "b", 1); err != nil || !ok {
+			t.Errorf("ClaimFloor() = %t, %v, %v, want the floor of the stale node", ok, holders, err)
+		}
+
+		// A node removed on shutdown is gone right away too
+		if err := p.RemoveNode(ctx, "b"); err != nil {
+			t.Fatalf("RemoveNode() failed: %v", err)
+		}
+		if got := userNodes(t, p, 2); len(got) != 0 {
+			t.Errorf("UserNodes() = %v, want none", got)
+		}
+		if members, err := p.RoomMembers(ctx, 1); err != nil || len(members) != 0 {
+			t.Errorf("RoomMembers() = %v, %v, want none", states(members), err)
+		}
+		if lobby, err := p.LobbyMembers(ctx, 1); err != nil || len(lobby) != 0 {
+			t.Errorf("LobbyMembers() = %v, %v, want none", states(lobby), err)
+		}
+	})
+}
+
+func mustPut(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("failed to store presence: %v", err)
+	}
+}
+
+// states converts member states to strings for comparison
+func states(members map[uint][]byte) map[uint]string {
+	s := make(map[uint]string, len(members))
+	for userID, state := range members {
+		s[userID] = string(state)
+	}
+	return s
+}
+
+// userNodes returns the sorted live nodes of the user
+func userNodes(t *testing.T, p Presence, userID uint) []string {
+	t.Helper()
+	nodes, err := p.UserNodes(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("UserNodes() failed: %v", err)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
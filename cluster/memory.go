@@ -0,0 +1,316 @@
+package cluster
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryBus connects in-process brokers. A single node uses it through
+// NewMemoryBroker; several nodes in one process (e.g. in tests) can share one.
+type MemoryBus struct {
+	mu      sync.RWMutex
+	brokers map[*MemoryBroker]struct{}
+}
+
+// NewMemoryBus creates an empty bus
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{brokers: make(map[*MemoryBroker]struct{})}
+}
+
+var defaultBus = NewMemoryBus()
+
+// MemoryBroker is a Broker delivering messages through a MemoryBus
+type MemoryBroker struct {
+	bus     *MemoryBus
+	handler Handler
+
+	mu     sync.RWMutex
+	topics map[string]bool
+}
+
+// NewMemoryBroker creates a broker on the process-wide bus
+func NewMemoryBroker(handler Handler) *MemoryBroker {
+	return defaultBus.NewBroker(handler)
+}
+
+// NewBroker creates a broker attached to the bus
+func (bus *MemoryBus) NewBroker(handler Handler) *MemoryBroker {
+	b := &MemoryBroker{
+		bus:     bus,
+		handler: handler,
+		topics:  make(map[string]bool),
+	}
+	bus.mu.Lock()
+	bus.brokers[b] = struct{}{}
+	bus.mu.Unlock()
+	return b
+}
+
+// Publish implements Broker. Delivery is asynchronous, like a real broker.
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, data []byte) error {
+	b.bus.mu.RLock()
+	defer b.bus.mu.RUnlock()
+
+	for sub := range b.bus.brokers {
+		sub.mu.RLock()
+		subscribed := sub.topics[topic]
+		sub.mu.RUnlock()
+		if subscribed {
+			go sub.handler(topic, data)
+		}
+	}
+	return nil
+}
+
+// Subscribe implements Broker
+func (b *MemoryBroker) Subscribe(ctx context.Context, topics ...string) error {
+	b.mu.Lock()
+	for _, t := range topics {
+		b.topics[t] = true
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// Unsubscribe implements Broker
+func (b *MemoryBroker) Unsubscribe(ctx context.Context, topics ...string) error {
+	b.mu.Lock()
+	for _, t := range topics {
+		delete(b.topics, t)
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// Close implements Broker
+func (b *MemoryBroker) Close() error {
+	b.bus.mu.Lock()
+	delete(b.bus.brokers, b)
+	b.bus.mu.Unlock()
+	return nil
+}
+
+// memoryMember is a room member record in MemoryPresence
+type memoryMember struct {
+	nodeID string
+	state  []byte
+}
+
+// MemoryPresence is a Presence kept in process memory, for single-node
+// deployments
+type MemoryPresence struct {
+	mu       sync.Mutex
+	nodes    map[string]time.Time
+	users    map[uint]map[string]bool
+	members  map[uint]map[uint]memoryMember
+	lobby    map[uint]map[uint]memoryMember
+	floors   map[uint]map[uint]string
+	versions map[uint]uint64
+}
+
+// NewMemoryPresence creates an empty in-memory presence registry
+func NewMemoryPresence() *MemoryPresence {
+	return &MemoryPresence{
+		nodes:    make(map[string]time.Time),
+		users:    make(map[uint]map[string]bool),
+		members:  make(map[uint]map[uint]memoryMember),
+		lobby:    make(map[uint]map[uint]memoryMember),
+		floors:   make(map[uint]map[uint]string),
+		versions: make(map[uint]uint64),
+	}
+}
+
+// aliveLocked reports whether the node's heartbeat hasn't expired
+func (p *MemoryPresence) aliveLocked(nodeID string) bool {
+	expiry, ok := p.nodes[nodeID]
+	return ok && time.Now().Before(expiry)
+}
+
+// Heartbeat implements Presence
+func (p *MemoryPresence) Heartbeat(ctx context.Context, nodeID string, ttl time.Duration) error {
+	p.mu.Lock()
+	p.nodes[nodeID] = time.Now().Add(ttl)
+	p.mu.Unlock()
+	return nil
+}
+
+// RemoveNode implements Presence
+func (p *MemoryPresence) RemoveNode(ctx context.Context, nodeID string) error {
+	p.mu.Lock()
+	delete(p.nodes, nodeID)
+	p.mu.Unlock()
+	return nil
+}
+
+// AddUserNode implements Presence
+func (p *MemoryPresence) AddUserNode(ctx context.Context, userID uint, nodeID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.users[userID] == nil {
+		p.users[userID] = make(map[string]bool)
+	}
+	p.users[userID][nodeID] = true
+	return nil
+}
+
+// RemoveUserNode implements Presence
+func (p *MemoryPresence) RemoveUserNode(ctx context.Context, userID uint, nodeID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.users[userID], nodeID)
+	if len(p.users[userID]) == 0 {
+		delete(p.users, userID)
+	}
+	return nil
+}
+
+// UserNodes implements Presence
+func (p *MemoryPresence) UserNodes(ctx context.Context, userID uint) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var nodes []string
+	for nodeID := range p.users[userID] {
+		if p.aliveLocked(nodeID) {
+			nodes = append(nodes, nodeID)
+		}
+	}
+	return nodes, nil
+}
+
+// PutRoomMember implements Presence
+func (p *MemoryPresence) PutRoomMember(ctx context.Context, roomID, userID uint, nodeID string, state []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	putMemoryMember(p.members, roomID, userID, memoryMember{nodeID: nodeID, state: state})
+	return nil
+}
+
+// RemoveRoomMember implements Presence
+func (p *MemoryPresence) RemoveRoomMember(ctx context.Context, roomID, userID uint) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	removeMemoryMember(p.members, roomID, userID)
+	return nil
+}
+
+// RoomMembers implements Presence
+func (p *MemoryPresence) RoomMembers(ctx context.Context, roomID uint) (map[uint][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.aliveMembersLocked(p.members[roomID]), nil
+}
+
+// PutLobbyMember implements Presence
+func (p *MemoryPresence) PutLobbyMember(ctx context.Context, roomID, userID uint, nodeID string, state []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	putMemoryMember(p.lobby, roomID, userID, memoryMember{nodeID: nodeID, state: state})
+	return nil
+}
+
+// RemoveLobbyMember implements Presence
+func (p *MemoryPresence) RemoveLobbyMember(ctx context.Context, roomID, userID uint) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	removeMemoryMember(p.lobby, roomID, userID)
+	return nil
+}
+
+// LobbyMembers implements Presence
+func (p *MemoryPresence) LobbyMembers(ctx context.Context, roomID uint) (map[uint][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.aliveMembersLocked(p.lobby[roomID]), nil
+}
+
+// aliveMembersLocked returns the state of the records hosted on live nodes
+func (p *MemoryPresence) aliveMembersLocked(records map[uint]memoryMember) map[uint][]byte {
+	members := make(map[uint][]byte)
+	for userID, m := range records {
+		if p.aliveLocked(m.nodeID) {
+			members[userID] = m.state
+		}
+	}
+	return members
+}
+
+func putMemoryMember(records map[uint]map[uint]memoryMember, roomID, userID uint, m memoryMember) {
+	if records[roomID] == nil {
+		records[roomID] = make(map[uint]memoryMember)
+	}
+	records[roomID][userID] = m
+}
+
+func removeMemoryMember(records map[uint]map[uint]memoryMember, roomID, userID uint) {
+	delete(records[roomID], userID)
+	if len(records[roomID]) == 0 {
+		delete(records, roomID)
+	}
+}
+
+// ClaimFloor implements Presence
+func (p *MemoryPresence) ClaimFloor(ctx context.Context, roomID, userID uint, nodeID string, max int) (bool, []uint, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	holders := p.floors[roomID]
+	if holders == nil {
+		holders = make(map[uint]string)
+		p.floors[roomID] = holders
+	}
+	var taken []uint
+	for holderID, holderNode := range holders {
+		switch {
+		case !p.aliveLocked(holderNode):
+			delete(holders, holderID)
+		case holderID != userID:
+			taken = append(taken, holderID)
+		}
+	}
+	if _, held := holders[userID]; !held && len(taken) >= max {
+		sort.Slice(taken, func(i, j int) bool { return taken[i] < taken[j] })
+		return false, taken, nil
+	}
+	holders[userID] = nodeID
+	return true, nil, nil
+}
+
+// ReleaseFloor implements Presence
+func (p *MemoryPresence) ReleaseFloor(ctx context.Context, roomID, userID uint) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.floors[roomID], userID)
+	if len(p.floors[roomID]) == 0 {
+		delete(p.floors, roomID)
+	}
+	return nil
+}
+
+// NextRoomVersion implements Presence
+func (p *MemoryPresence) NextRoomVersion(ctx context.Context, roomID uint) (uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.versions[roomID]++
+	return p.versions[roomID], nil
+}
+
+// RoomVersion implements Presence
+func (p *MemoryPresence) RoomVersion(ctx context.Context, roomID uint) (uint64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.versions[roomID], nil
+}
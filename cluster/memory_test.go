@@ -0,0 +1,20 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	bus := NewMemoryBus()
+	testBroker(t, func(h Handler) Broker { return bus.NewBroker(h) }, func(string, int) {})
+}
+
+func TestMemoryPresence(t *testing.T) {
+	p := NewMemoryPresence()
+	testPresence(t, p, func(nodeID string) {
+		p.mu.Lock()
+		p.nodes[nodeID] = time.Now()
+		p.mu.Unlock()
+	})
+}
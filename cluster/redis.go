@@ -0,0 +1,330 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// keyPrefix namespaces every Redis key and channel used by chat-go
+const keyPrefix = "chatgo:"
+
+// RedisBroker is a Broker on top of Redis pub/sub
+type RedisBroker struct {
+	client redis.UniversalClient
+	pubsub *redis.PubSub
+}
+
+// NewRedisBroker creates a broker using client and starts delivering
+// messages on subscribed topics to handler. The client is not closed by the
+// broker, so it can be shared with a RedisPresence or pointed at a local
+// stand-in server.
+func NewRedisBroker(client redis.UniversalClient, handler Handler) *RedisBroker {
+	b := &RedisBroker{
+		client: client,
+		pubsub: client.Subscribe(context.Background()),
+	}
+
+	go func() {
+		for msg := range b.pubsub.Channel() {
+			handler(msg.Channel[len(keyPrefix):], []byte(msg.Payload))
+		}
+	}()
+
+	return b
+}
+
+// channels maps topics to Redis channel names
+func channels(topics []string) []string {
+	chans := make([]string, len(topics))
+	for i, t := range topics {
+		chans[i] = keyPrefix + t
+	}
+	return chans
+}
+
+// Publish implements Broker
+func (b *RedisBroker) Publish(ctx context.Context, topic string, data []byte) error {
+	return b.client.Publish(ctx, keyPrefix+topic, data).Err()
+}
+
+// Subscribe implements Broker
+func (b *RedisBroker) Subscribe(ctx context.Context, topics ...string) error {
+	return b.pubsub.Subscribe(ctx, channels(topics)...)
+}
+
+// Unsubscribe implements Broker
+func (b *RedisBroker) Unsubscribe(ctx context.Context, topics ...string) error {
+	return b.pubsub.Unsubscribe(ctx, channels(topics)...)
+}
+
+// Close implements Broker
+func (b *RedisBroker) Close() error {
+	return b.pubsub.Close()
+}
+
+// RedisPresence is a Presence stored in Redis, shared by every node
+type RedisPresence struct {
+	client redis.UniversalClient
+}
+
+// NewRedisPresence creates a presence registry using client
+func NewRedisPresence(client redis.UniversalClient) *RedisPresence {
+	return &RedisPresence{client: client}
+}
+
+// redisMember is the JSON value stored per room member
+type redisMember struct {
+	NodeID string          `json:"node_id"`
+	State  json.RawMessage `json:"state"`
+}
+
+// nodeKeyPrefix is followed by the node ID in the key of a node's heartbeat
+const nodeKeyPrefix = keyPrefix + "node:"
+
+func nodeKey(nodeID string) string {
+	return nodeKeyPrefix + nodeID
+}
+
+func userNodesKey(userID uint) string {
+	return fmt.Sprintf("%suser:%d:nodes", keyPrefix, userID)
+}
+
+func roomMembersKey(roomID uint) string {
+	return fmt.Sprintf("%sroom:%d:members", keyPrefix, roomID)
+}
+
+func lobbyMembersKey(roomID uint) string {
+	return fmt.Sprintf("%sroom:%d:lobby", keyPrefix, roomID)
+}
+
+func floorKey(roomID uint) string {
+	return fmt.Sprintf("%sroom:%d:floor", keyPrefix, roomID)
+}
+
+func roomVersionKey(roomID uint) string {
+	return fmt.Sprintf("%sroom:%d:version", keyPrefix, roomID)
+}
+
+// aliveNodes reports which of the given nodes still have a heartbeat
+func (p *RedisPresence) aliveNodes(ctx context.Context, nodeIDs []string) (map[string]bool, error) {
+	pipe := p.client.Pipeline()
+	cmds := make(map[string]*redis.IntCmd, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		if _, ok := cmds[nodeID]; !ok {
+			cmds[nodeID] = pipe.Exists(ctx, nodeKey(nodeID))
+		}
+	}
+	if len(cmds) == 0 {
+		return map[string]bool{}, nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	alive := make(map[string]bool, len(cmds))
+	for nodeID, cmd := range cmds {
+		alive[nodeID] = cmd.Val() > 0
+	}
+	return alive, nil
+}
+
+// Heartbeat implements Presence
+func (p *RedisPresence) Heartbeat(ctx context.Context, nodeID string, ttl time.Duration) error {
+	return p.client.Set(ctx, nodeKey(nodeID), time.Now().Unix(), ttl).Err()
+}
+
+// RemoveNode implements Presence
+func (p *RedisPresence) RemoveNode(ctx context.Context, nodeID string) error {
+	return p.client.Del(ctx, nodeKey(nodeID)).Err()
+}
+
+// AddUserNode implements Presence
+func (p *RedisPresence) AddUserNode(ctx context.Context, userID uint, nodeID string) error {
+	return p.client.SAdd(ctx, userNodesKey(userID), nodeID).Err()
+}
+
+// RemoveUserNode implements Presence
+func (p *RedisPresence) RemoveUserNode(ctx context.Context, userID uint, nodeID string) error {
+	return p.client.SRem(ctx, userNodesKey(userID), nodeID).Err()
+}
+
+// UserNodes implements Presence
+func (p *RedisPresence) UserNodes(ctx context.Context, userID uint) ([]string, error) {
+	nodeIDs, err := p.client.SMembers(ctx, userNodesKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	alive, err := p.aliveNodes(ctx, nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []string
+	for _, nodeID := range nodeIDs {
+		if alive[nodeID] {
+			nodes = append(nodes, nodeID)
+		}
+	}
+	return nodes, nil
+}
+
+// PutRoomMember implements Presence
+func (p *RedisPresence) PutRoomMember(ctx context.Context, roomID, userID uint, nodeID string, state []byte) error {
+	return p.putMember(ctx, roomMembersKey(roomID), userID, nodeID, state)
+}
+
+// RemoveRoomMember implements Presence
+func (p *RedisPresence) RemoveRoomMember(ctx context.Context, roomID, userID uint) error {
+	return p.client.HDel(ctx, roomMembersKey(roomID), strconv.FormatUint(uint64(userID), 10)).Err()
+}
+
+// RoomMembers implements Presence. Members left behind by dead nodes are
+// removed on the way.
+func (p *RedisPresence) RoomMembers(ctx context.Context, roomID uint) (map[uint][]byte, error) {
+	return p.members(ctx, roomMembersKey(roomID))
+}
+
+// PutLobbyMember implements Presence
+func (p *RedisPresence) PutLobbyMember(ctx context.Context, roomID, userID uint, nodeID string, state []byte) error {
+	return p.putMember(ctx, lobbyMembersKey(roomID), userID, nodeID, state)
+}
+
+// RemoveLobbyMember implements Presence
+func (p *RedisPresence) RemoveLobbyMember(ctx context.Context, roomID, userID uint) error {
+	return p.client.HDel(ctx, lobbyMembersKey(roomID), strconv.FormatUint(uint64(userID), 10)).Err()
+}
+
+// LobbyMembers implements Presence. Users left behind by dead nodes are
+// removed on the way.
+func (p *RedisPresence) LobbyMembers(ctx context.Context, roomID uint) (map[uint][]byte, error) {
+	return p.members(ctx, lobbyMembersKey(roomID))
+}
+
+// putMember stores a member record in the hash at key
+func (p *RedisPresence) putMember(ctx context.Context, key string, userID uint, nodeID string, state []byte) error {
+	value, err := json.Marshal(redisMember{NodeID: nodeID, State: state})
+	if err != nil {
+		return err
+	}
+	return p.client.HSet(ctx, key, strconv.FormatUint(uint64(userID), 10), value).Err()
+}
+
+// members returns the state of the records in the hash at key that are
+// hosted on live nodes, removing the others
+func (p *RedisPresence) members(ctx context.Context, key string) (map[uint][]byte, error) {
+	fields, err := p.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[uint]redisMember, len(fields))
+	var nodeIDs []string
+	for field, value := range fields {
+		userID, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			continue
+		}
+		var m redisMember
+		if err := json.Unmarshal([]byte(value), &m); err != nil {
+			continue
+		}
+		records[uint(userID)] = m
+		nodeIDs = append(nodeIDs, m.NodeID)
+	}
+
+	alive, err := p.aliveNodes(ctx, nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[uint][]byte, len(records))
+	var stale []string
+	for userID, m := range records {
+		if !alive[m.NodeID] {
+			stale = append(stale, strconv.FormatUint(uint64(userID), 10))
+			continue
+		}
+		members[userID] = m.State
+	}
+	if len(stale) > 0 {
+		if err := p.client.HDel(ctx, key, stale...).Err(); err != nil {
+			log.Printf("Failed to remove stale members from %s: %v", key, err)
+		}
+	}
+	return members, nil
+}
+
+// claimFloor atomically checks and takes a floor in the hash of holders at
+// KEYS[1], mapping users to their nodes. Holders whose node key, ARGV[4]
+// followed by the node, expired are dropped first. The script reads node
+// keys it isn't given, so it needs a single Redis server rather than a
+// cluster.
+var claimFloor = redis.NewScript(`
+local holders = redis.call('HGETALL', KEYS[1])
+local taken = {}
+local held = false
+for i = 1, #holders, 2 do
+	local user, node = holders[i], holders[i + 1]
+	if redis.call('EXISTS', ARGV[4] .. node) == 0 then
+		redis.call('HDEL', KEYS[1], user)
+	elseif user == ARGV[1] then
+		held = true
+	else
+		table.insert(taken, user)
+	end
+end
+if not held and #taken >= tonumber(ARGV[3]) then
+	return taken
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return false
+`)
+
+// ClaimFloor implements Presence
+func (p *RedisPresence) ClaimFloor(ctx context.Context, roomID, userID uint, nodeID string, max int) (bool, []uint, error) {
+	taken, err := claimFloor.Run(ctx, p.client, []string{floorKey(roomID)},
+		userID, nodeID, max, nodeKeyPrefix).StringSlice()
+	if err == redis.Nil {
+		return true, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	holders := make([]uint, 0, len(taken))
+	for _, field := range taken {
+		if userID, err := strconv.ParseUint(field, 10, 32); err == nil {
+			holders = append(holders, uint(userID))
+		}
+	}
+	sort.Slice(holders, func(i, j int) bool { return holders[i] < holders[j] })
+	return false, holders, nil
+}
+
+// ReleaseFloor implements Presence
+func (p *RedisPresence) ReleaseFloor(ctx context.Context, roomID, userID uint) error {
+	return p.client.HDel(ctx, floorKey(roomID), strconv.FormatUint(uint64(userID), 10)).Err()
+}
+
+// NextRoomVersion implements Presence
+func (p *RedisPresence) NextRoomVersion(ctx context.Context, roomID uint) (uint64, error) {
+	v, err := p.client.Incr(ctx, roomVersionKey(roomID)).Result()
+	return uint64(v), err
+}
+
+// RoomVersion implements Presence
+func (p *RedisPresence) RoomVersion(ctx context.Context, roomID uint) (uint64, error) {
+	v, err := p.client.Get(ctx, roomVersionKey(roomID)).Uint64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return v, err
+}
@@ -0,0 +1,51 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newRedisClient connects to a local stand-in for Redis
+func newRedisClient(t *testing.T, mr *miniredis.Miniredis) *redis.Client {
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRedisBroker(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	// Each node has its own connection, as on separate hosts
+	newBroker := func(h Handler) Broker { return NewRedisBroker(newRedisClient(t, mr), h) }
+	subscribed := func(topic string, n int) {
+		deadline := time.Now().Add(deliveryTimeout)
+		for mr.PubSubNumSub(keyPrefix + topic)[keyPrefix+topic] != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s never had %d subscribers", topic, n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	testBroker(t, newBroker, subscribed)
+}
+
+func TestRedisPresence(t *testing.T) {
+	mr := miniredis.RunT(t)
+	p := NewRedisPresence(newRedisClient(t, mr))
+
+	testPresence(t, p, func(nodeID string) {
+		mr.SetTTL(nodeKey(nodeID), time.Second)
+		mr.FastForward(time.Second)
+	})
+
+	// Reading the members dropped the records of the dead nodes from Redis
+	for _, key := range []string{roomMembersKey(1), lobbyMembersKey(1)} {
+		if mr.Exists(key) {
+			fields, _ := mr.HKeys(key)
+			t.Errorf("%s still holds %v", key, fields)
+		}
+	}
+}
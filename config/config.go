@@ -8,10 +8,17 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Auth     AuthConfig
-	WebRTC   WebRTCConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Auth      AuthConfig
+	WebRTC    WebRTCConfig
+	Signaling SignalingConfig
+	Cluster   ClusterConfig
+	TURN      TURNConfig
+	SFU       SFUConfig
+	Storage   StorageConfig
+	Bots      BotsConfig
+	Broadcast BroadcastConfig
 }
 
 type ServerConfig struct {
@@ -33,14 +40,89 @@ type DatabaseConfig struct {
 type AuthConfig struct {
 	SecretKey  string `mapstructure:"secret_key"`
 	TokenExpiry string `mapstructure:"token_expiry"`
+	// AdminUserIDs are the users allowed to call admin RPCs
+	AdminUserIDs []uint `mapstructure:"admin_user_ids"`
+}
+
+type SignalingConfig struct {
+	ResumeGracePeriod string `mapstructure:"resume_grace_period"`
+	EventBufferSize   int    `mapstructure:"event_buffer_size"`
+	MaxPresenters     int    `mapstructure:"max_presenters"`
+	RingTimeout       string `mapstructure:"ring_timeout"`
+}
+
+type ClusterConfig struct {
+	Broker            string `mapstructure:"broker"`
+	NodeID            string `mapstructure:"node_id"`
+	HeartbeatInterval string `mapstructure:"heartbeat_interval"`
+	Redis             RedisConfig
+}
+
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
+type TURNConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	Realm          string `mapstructure:"realm"`
+	ListenAddr     string `mapstructure:"listen_addr"`
+	PublicIP       string `mapstructure:"public_ip"`
+	RelayMinPort   uint16 `mapstructure:"relay_min_port"`
+	RelayMaxPort   uint16 `mapstructure:"relay_max_port"`
+	MaxBitrateKbps int    `mapstructure:"max_bitrate_kbps"`
+}
+
+type SFUConfig struct {
+	PublicIP   string `mapstructure:"public_ip"`
+	UDPMinPort uint16 `mapstructure:"udp_min_port"`
+	UDPMaxPort uint16 `mapstructure:"udp_max_port"`
+}
+
+type StorageConfig struct {
+	Backend string `mapstructure:"backend"`
+	Dir     string `mapstructure:"dir"`
+}
+
+// BotsConfig limits the server-side bot participants. MediaDir holds the
+// Ogg/Opus files bots can play.
+type BotsConfig struct {
+	MediaDir string `mapstructure:"media_dir"`
+	MaxBots  int    `mapstructure:"max_bots"`
+}
+
+// BroadcastConfig shapes the HLS broadcasts of rooms. PublicURL is where
+// listeners reach the HTTP port; URLTTL is how long signed playlist URLs
+// stay valid.
+type BroadcastConfig struct {
+	PublicURL       string `mapstructure:"public_url"`
+	SegmentDuration string `mapstructure:"segment_duration"`
+	PlaylistLength  int    `mapstructure:"playlist_length"`
+	URLTTL          string `mapstructure:"url_ttl"`
 }
 
 type WebRTCConfig struct {
-	ICEServers []ICEServer `mapstructure:"ice_servers"`
+	ICEServers        []ICEServer       `mapstructure:"ice_servers"`
+	TURNSecret        string            `mapstructure:"turn_secret"`
+	TURNCredentialTTL string            `mapstructure:"turn_credential_ttl"`
+	CodecPolicy       CodecPolicyConfig `mapstructure:"codec_policy"`
+}
+
+// CodecPolicyConfig is the codec policy of rooms created without one
+type CodecPolicyConfig struct {
+	PreferredCodecs     []string `mapstructure:"preferred_codecs"`
+	OpusDTX             bool     `mapstructure:"opus_dtx"`
+	OpusFEC             bool     `mapstructure:"opus_fec"`
+	Stereo              bool     `mapstructure:"stereo"`
+	MaxAudioBitrateKbps int      `mapstructure:"max_audio_bitrate_kbps"`
 }
 
 type ICEServer struct {
-	URLs string `mapstructure:"urls"`
+	URLs       string `mapstructure:"urls"`
+	Username   string `mapstructure:"username"`
+	Credential string `mapstructure:"credential"`
+	Ephemeral  bool   `mapstructure:"ephemeral"`
 }
 
 var AppConfig Config
@@ -43,7 +43,7 @@ func InitDB() error {
 	sqlDB.SetMaxOpenConns(100)
 
 	// Auto migrate models
-	if err := DB.AutoMigrate(&models.User{}, &models.Room{}); err != nil {
+	if err := DB.AutoMigrate(&models.User{}, &models.Room{}, &models.RoomBan{}, &models.Recording{}, &models.RecordingFile{}, &models.ModerationAction{}, &models.CallLog{}, &models.CallSession{}, &models.CallParticipant{}, &models.CallQuality{}, &models.Device{}, &models.OneTimePrekey{}, &models.EncryptedMessage{}, &models.DirectContact{}); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
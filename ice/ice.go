@@ -0,0 +1,108 @@
+package ice
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aloys-y/chat-go/config"
+)
+
+// defaultCredentialTTL is used when turn_credential_ttl is missing or invalid
+const defaultCredentialTTL = 12 * time.Hour
+
+// Server is an ICE server entry in the shape RTCPeerConnection expects
+type Server struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// Config is the ICE configuration handed to a client. ExpiresAt is set when
+// it contains ephemeral TURN credentials; clients should fetch a new one
+// before then.
+type Config struct {
+	ICEServers []Server `json:"ice_servers"`
+	ExpiresAt  int64    `json:"expires_at,omitempty"`
+}
+
+// CredentialTTL returns the lifetime of ephemeral TURN credentials
+func CredentialTTL() time.Duration {
+	ttl, err := time.ParseDuration(config.AppConfig.WebRTC.TURNCredentialTTL)
+	if err != nil || ttl <= 0 {
+		return defaultCredentialTTL
+	}
+	return ttl
+}
+
+// EphemeralCredentials mints time-limited TURN credentials following the TURN
+// REST API convention: the username is "<expiry unix time>:<user id>" and the
+// credential is base64(HMAC-SHA1(secret, username)).
+func EphemeralCredentials(secret string, userID uint, expiresAt time.Time) (username, credential string) {
+	username = fmt.Sprintf("%d:%d", expiresAt.Unix(), userID)
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ConfigForUser builds the ICE configuration for a user from the webrtc
+// config, minting ephemeral credentials for TURN entries that ask for them
+func ConfigForUser(userID uint) Config {
+	cfg := config.AppConfig.WebRTC
+	expiresAt := time.Now().Add(CredentialTTL())
+
+	result := Config{ICEServers: make([]Server, 0, len(cfg.ICEServers))}
+	for _, s := range cfg.ICEServers {
+		server := Server{
+			URLs:       []string{s.URLs},
+			Username:   s.Username,
+			Credential: s.Credential,
+		}
+
+		if s.Ephemeral {
+			if cfg.TURNSecret == "" {
+				log.Printf("Skipping ICE server %s: ephemeral credentials need webrtc.turn_secret", s.URLs)
+				continue
+			}
+			server.Username, server.Credential = EphemeralCredentials(cfg.TURNSecret, userID, expiresAt)
+			result.ExpiresAt = expiresAt.Unix()
+		}
+
+		result.ICEServers = append(result.ICEServers, server)
+	}
+	return result
+}
+
+// ValidateEphemeral checks a TURN REST API username minted by
+// EphemeralCredentials and returns the user it was minted for along with the
+// matching credential
+func ValidateEphemeral(secret, username string) (userID uint, credential string, err error) {
+	if secret == "" {
+		return 0, "", errors.New("no TURN shared secret configured")
+	}
+
+	expiryStr, userStr, ok := strings.Cut(username, ":")
+	if !ok {
+		return 0, "", errors.New("not a TURN REST API username")
+	}
+	expiry, err := strconv.ParseInt(expiryStr, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid expiry in username: %w", err)
+	}
+	if time.Now().Unix() > expiry {
+		return 0, "", errors.New("credentials expired")
+	}
+	id, err := strconv.ParseUint(userStr, 10, 32)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid user ID in username: %w", err)
+	}
+
+	_, credential = EphemeralCredentials(secret, uint(id), time.Unix(expiry, 0))
+	return uint(id), credential, nil
+}
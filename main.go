@@ -9,10 +9,15 @@ import (
 	"syscall"
 
 	"github.com/Aloys-y/chat-go/auth"
+	"github.com/Aloys-y/chat-go/blobstore"
+	"github.com/Aloys-y/chat-go/bots"
+	"github.com/Aloys-y/chat-go/broadcast"
 	"github.com/Aloys-y/chat-go/config"
 	"github.com/Aloys-y/chat-go/db"
 	"github.com/Aloys-y/chat-go/services"
+	"github.com/Aloys-y/chat-go/sfu"
 	"github.com/Aloys-y/chat-go/signaling"
+	"github.com/Aloys-y/chat-go/turnserver"
 
 	pb "github.com/Aloys-y/chat-go/proto"
 
@@ -31,6 +36,17 @@ func main() {
 	}
 	defer db.CloseDB()
 
+	// Initialize blob store for recordings
+	if err := blobstore.Init(); err != nil {
+		log.Fatalf("Failed to initialize blob store: %v", err)
+	}
+
+	// Initialize cluster broker and presence registry
+	if err := signaling.InitCluster(); err != nil {
+		log.Fatalf("Failed to initialize cluster: %v", err)
+	}
+	defer signaling.CloseCluster()
+
 	// 创建认证拦截器
 	authInterceptor := auth.NewAuthInterceptor()
 
@@ -42,6 +58,11 @@ func main() {
 	// Register services
 	pb.RegisterUserServiceServer(grpcServer, &services.UserServiceImpl{})
 	pb.RegisterRoomServiceServer(grpcServer, &services.RoomServiceImpl{})
+	pb.RegisterRecordingServiceServer(grpcServer, &services.RecordingServiceImpl{})
+	pb.RegisterCallServiceServer(grpcServer, &services.CallServiceImpl{})
+	pb.RegisterMessagingServiceServer(grpcServer, &services.MessagingServiceImpl{})
+	pb.RegisterBotServiceServer(grpcServer, &services.BotServiceImpl{})
+	pb.RegisterBroadcastServiceServer(grpcServer, &services.BroadcastServiceImpl{})
 
 	// Start gRPC server
 	grpcAddr := fmt.Sprintf(":%d", config.AppConfig.Server.GRPCPort)
@@ -60,6 +81,18 @@ func main() {
 	// Start WebSocket server
 	go signaling.StartWSServer(config.AppConfig.Server.WSPort)
 
+	// Start HTTP server for broadcast listeners
+	go broadcast.StartHTTPServer(config.AppConfig.Server.HTTPPort)
+
+	// Start embedded TURN server
+	if config.AppConfig.TURN.Enabled {
+		turnServer, err := turnserver.Start()
+		if err != nil {
+			log.Fatalf("Failed to start TURN server: %v", err)
+		}
+		defer turnServer.Close()
+	}
+
 	// Wait for interrupt signal to gracefully shut down the servers
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -72,5 +105,14 @@ func main() {
 
 	// Stop WebSocket server (will be handled automatically when main exits)
 
+	// Bots leave their rooms
+	bots.StopAll()
+
+	// Finish recordings in progress so their files are complete
+	sfu.StopAllRecordings()
+
+	// End broadcasts so listeners see the end of their playlists
+	sfu.StopAllBroadcasts()
+
 	log.Println("Servers exited gracefully")
 }
@@ -0,0 +1,197 @@
+package membership
+
+import (
+	"errors"
+	"log"
+
+	"github.com/Aloys-y/chat-go/config"
+	"github.com/Aloys-y/chat-go/db"
+	"github.com/Aloys-y/chat-go/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoomNotFound = errors.New("room not found")
+	ErrUserNotFound = errors.New("user not found")
+	ErrNotMember    = errors.New("user is not a member of this private room")
+	ErrBanned       = errors.New("user is banned from this room")
+)
+
+// GetRoom loads a room, mapping a missing record to ErrRoomNotFound
+func GetRoom(roomID uint) (*models.Room, error) {
+	var room models.Room
+	if err := db.DB.First(&room, roomID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoomNotFound
+		}
+		return nil, err
+	}
+	return &room, nil
+}
+
+// IsMember reports whether the user is recorded in user_rooms for the room
+func IsMember(roomID, userID uint) (bool, error) {
+	var count int64
+	err := db.DB.Table("user_rooms").
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// IsBanned reports whether the user is banned from the room
+func IsBanned(roomID, userID uint) (bool, error) {
+	var count int64
+	err := db.DB.Model(&models.RoomBan{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Admit checks that the user may enter the room and records the membership.
+// Banned users are refused; private rooms only admit existing members.
+func Admit(roomID, userID uint) (*models.Room, error) {
+	room, err := GetRoom(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	banned, err := IsBanned(roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if banned {
+		return nil, ErrBanned
+	}
+
+	member, err := IsMember(roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		return room, nil
+	}
+	if !room.IsPublic && room.OwnerID != userID {
+		return nil, ErrNotMember
+	}
+
+	if err := Add(room, userID); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// Add records the user as a member of the room without any checks
+func Add(room *models.Room, userID uint) error {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return db.DB.Model(room).Association("Users").Append(&user)
+}
+
+// Remove deletes the user's membership of the room
+func Remove(roomID, userID uint) error {
+	return db.DB.Exec("DELETE FROM user_rooms WHERE room_id = ? AND user_id = ?", roomID, userID).Error
+}
+
+// Ban removes the user from the room and keeps them from joining again
+func Ban(roomID, userID, bannedBy uint, reason string) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM user_rooms WHERE room_id = ? AND user_id = ?", roomID, userID).Error; err != nil {
+			return err
+		}
+		ban := &models.RoomBan{
+			RoomID:   roomID,
+			UserID:   userID,
+			BannedBy: bannedBy,
+			Reason:   reason,
+		}
+		return tx.Where("room_id = ? AND user_id = ?", roomID, userID).FirstOrCreate(ban).Error
+	})
+}
+
+// RecordAction records a moderator's action on a participant
+func RecordAction(roomID, moderatorID, userID uint, action string) error {
+	return db.DB.Create(&models.ModerationAction{
+		RoomID:      roomID,
+		ModeratorID: moderatorID,
+		UserID:      userID,
+		Action:      action,
+	}).Error
+}
+
+// SetLocked locks or unlocks the room
+func SetLocked(roomID uint, locked bool) error {
+	return db.DB.Model(&models.Room{}).Where("id = ?", roomID).Update("locked", locked).Error
+}
+
+// DeleteRoom removes the room together with its memberships, bans,
+// moderation records and breakout rooms
+func DeleteRoom(roomID uint) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteBreakouts(tx, roomID); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM user_rooms WHERE room_id = ?", roomID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id = ?", roomID).Delete(&models.RoomBan{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id = ?", roomID).Delete(&models.ModerationAction{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Room{}, roomID).Error
+	})
+}
+
+// DeleteBreakouts removes the breakout rooms of a room. They are deleted for
+// good so their names can be used again.
+func DeleteBreakouts(parentID uint) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteBreakouts(tx, parentID)
+	})
+}
+
+func deleteBreakouts(tx *gorm.DB, parentID uint) error {
+	var breakouts []uint
+	if err := tx.Model(&models.Room{}).Where("parent_id = ?", parentID).Pluck("id", &breakouts).Error; err != nil {
+		return err
+	}
+	if len(breakouts) == 0 {
+		return nil
+	}
+
+	if err := tx.Exec("DELETE FROM user_rooms WHERE room_id IN ?", breakouts).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("room_id IN ?", breakouts).Delete(&models.ModerationAction{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&models.Room{}, breakouts).Error
+}
+
+// DefaultCodecPolicy returns the codec policy of rooms created without one,
+// from webrtc.codec_policy. Unknown codecs are left out.
+func DefaultCodecPolicy() models.CodecPolicy {
+	cfg := config.AppConfig.WebRTC.CodecPolicy
+	policy := models.CodecPolicy{
+		OpusDTX:             cfg.OpusDTX,
+		OpusFEC:             cfg.OpusFEC,
+		Stereo:              cfg.Stereo,
+		MaxAudioBitrateKbps: cfg.MaxAudioBitrateKbps,
+	}
+	for _, mimeType := range cfg.PreferredCodecs {
+		codec, ok := models.CanonicalCodec(mimeType)
+		if !ok {
+			log.Printf("Ignoring unknown codec %q in webrtc.codec_policy", mimeType)
+			continue
+		}
+		policy.PreferredCodecs = append(policy.PreferredCodecs, codec)
+	}
+	return policy
+}
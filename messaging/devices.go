@@ -0,0 +1,232 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Aloys-y/chat-go/db"
+	"github.com/Aloys-y/chat-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MaxDevices is how many devices a user may register
+	MaxDevices = 10
+	// MaxPrekeys is how many unused one-time prekeys a device may store
+	MaxPrekeys = 200
+	// LowPrekeys is the count under which a device is asked to upload more
+	// one-time prekeys
+	LowPrekeys = 10
+)
+
+var (
+	ErrDeviceNotFound  = errors.New("device not found")
+	ErrTooManyDevices  = fmt.Errorf("users can't have more than %d devices", MaxDevices)
+	ErrTooManyPrekeys  = fmt.Errorf("devices can't store more than %d one-time prekeys", MaxPrekeys)
+	ErrDuplicateDevice = errors.New("a device is addressed more than once")
+	ErrNoDevices       = errors.New("recipient has no devices")
+)
+
+// SignedPrekey is a device's medium-term prekey with its signature by the
+// device's identity key
+type SignedPrekey struct {
+	KeyID     uint32
+	PublicKey []byte
+	Signature []byte
+}
+
+// Bundle is what a sender needs to start a session with a device. Prekey is
+// nil once the device ran out of one-time prekeys; Remaining is how many it
+// has left.
+type Bundle struct {
+	Device    models.Device
+	Prekey    *models.OneTimePrekey
+	Remaining int64
+}
+
+// RegisterDevice adds a device with its public keys to the user
+func RegisterDevice(userID uint, name string, identityKey []byte, signed SignedPrekey, prekeys []models.OneTimePrekey) (*models.Device, error) {
+	if len(prekeys) > MaxPrekeys {
+		return nil, ErrTooManyPrekeys
+	}
+
+	device := &models.Device{
+		UserID:                userID,
+		Name:                  name,
+		IdentityKey:           identityKey,
+		SignedPrekeyID:        signed.KeyID,
+		SignedPrekey:          signed.PublicKey,
+		SignedPrekeySignature: signed.Signature,
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Device{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= MaxDevices {
+			return ErrTooManyDevices
+		}
+		if err := tx.Create(device).Error; err != nil {
+			return fmt.Errorf("failed to create device: %w", err)
+		}
+		return addPrekeys(tx, device.ID, prekeys)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// RemoveDevice deletes the user's device with its prekeys and the messages
+// still waiting for it
+func RemoveDevice(userID, deviceID uint) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("id = ? AND user_id = ?", deviceID, userID).Delete(&models.Device{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDeviceNotFound
+		}
+		if err := tx.Where("device_id = ?", deviceID).Delete(&models.OneTimePrekey{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("recipient_device_id = ?", deviceID).Delete(&models.EncryptedMessage{}).Error
+	})
+}
+
+// Devices lists the user's devices, oldest first
+func Devices(userID uint) ([]models.Device, error) {
+	var devices []models.Device
+	err := db.DB.Where("user_id = ?", userID).Order("id").Find(&devices).Error
+	return devices, err
+}
+
+// DeviceIDs returns the IDs of the user's devices
+func DeviceIDs(userID uint) ([]uint, error) {
+	var ids []uint
+	err := db.DB.Model(&models.Device{}).Where("user_id = ?", userID).Order("id").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// OwnDevice loads a device of the user
+func OwnDevice(userID, deviceID uint) (*models.Device, error) {
+	var device models.Device
+	if err := db.DB.Where("id = ? AND user_id = ?", deviceID, userID).First(&device).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+	return &device, nil
+}
+
+// UploadPrekeys adds one-time prekeys to the user's device and replaces its
+// signed prekey when one is given. Prekeys whose key ID the device already
+// has are ignored. It returns how many one-time prekeys the device has.
+func UploadPrekeys(userID, deviceID uint, signed *SignedPrekey, prekeys []models.OneTimePrekey) (int64, error) {
+	var count int64
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var device models.Device
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", deviceID, userID).
+			First(&device).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDeviceNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if signed != nil {
+			err := tx.Model(&device).Updates(map[string]interface{}{
+				"signed_prekey_id":        signed.KeyID,
+				"signed_prekey":           signed.PublicKey,
+				"signed_prekey_signature": signed.Signature,
+			}).Error
+			if err != nil {
+				return fmt.Errorf("failed to update signed prekey: %w", err)
+			}
+		}
+
+		if err := tx.Model(&models.OneTimePrekey{}).Where("device_id = ?", deviceID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count+int64(len(prekeys)) > MaxPrekeys {
+			return ErrTooManyPrekeys
+		}
+		if err := addPrekeys(tx, deviceID, prekeys); err != nil {
+			return err
+		}
+		return tx.Model(&models.OneTimePrekey{}).Where("device_id = ?", deviceID).Count(&count).Error
+	})
+	return count, err
+}
+
+// PrekeyCount returns how many one-time prekeys the device has left
+func PrekeyCount(deviceID uint) (int64, error) {
+	var count int64
+	err := db.DB.Model(&models.OneTimePrekey{}).Where("device_id = ?", deviceID).Count(&count).Error
+	return count, err
+}
+
+// addPrekeys stores one-time prekeys of the device, skipping key IDs it
+// already has
+func addPrekeys(tx *gorm.DB, deviceID uint, prekeys []models.OneTimePrekey) error {
+	if len(prekeys) == 0 {
+		return nil
+	}
+	for i := range prekeys {
+		prekeys[i].ID = 0
+		prekeys[i].DeviceID = deviceID
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&prekeys).Error; err != nil {
+		return fmt.Errorf("failed to store prekeys: %w", err)
+	}
+	return nil
+}
+
+// Bundles hands out the prekey bundle of the user's device, or of all the
+// user's devices when deviceID is 0. Each bundle takes one of the device's
+// one-time prekeys, which is deleted so no other sender gets it.
+func Bundles(userID, deviceID uint) ([]Bundle, error) {
+	query := db.DB.Where("user_id = ?", userID)
+	if deviceID != 0 {
+		query = query.Where("id = ?", deviceID)
+	}
+	var devices []models.Device
+	if err := query.Order("id").Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	if deviceID != 0 && len(devices) == 0 {
+		return nil, ErrDeviceNotFound
+	}
+
+	bundles := make([]Bundle, 0, len(devices))
+	for _, device := range devices {
+		bundle := Bundle{Device: device}
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			var prekey models.OneTimePrekey
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("device_id = ?", device.ID).
+				Order("id").
+				First(&prekey).Error
+			if err == nil {
+				if err := tx.Delete(&prekey).Error; err != nil {
+					return err
+				}
+				bundle.Prekey = &prekey
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			return tx.Model(&models.OneTimePrekey{}).Where("device_id = ?", device.ID).Count(&bundle.Remaining).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to take a prekey of device %d: %w", device.ID, err)
+		}
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
@@ -0,0 +1,127 @@
+package messaging
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Aloys-y/chat-go/db"
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// mockDB points db.DB at a mocked MySQL connection for the test
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create mock database: %v", err)
+	}
+	gdb, err := gorm.Open(mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open mock database: %v", err)
+	}
+
+	old := db.DB
+	db.DB = gdb
+	t.Cleanup(func() {
+		db.DB = old
+		conn.Close()
+	})
+	return mock
+}
+
+// devicesQuery matches the lookup of a user's devices
+const devicesQuery = "SELECT \\* FROM `devices` WHERE user_id = \\?"
+
+func deviceRows(ids ...uint) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "identity_key"})
+	for _, id := range ids {
+		rows.AddRow(id, 7, "phone", []byte("identity"))
+	}
+	return rows
+}
+
+// expectHandout expects one device's prekey to be taken: the oldest prekey
+// is locked, skipping prekeys other senders hold, and deleted
+func expectHandout(mock sqlmock.Sqlmock, deviceID uint, prekeyID uint, remaining int64) {
+	mock.ExpectBegin()
+	rows := sqlmock.NewRows([]string{"id", "device_id", "key_id", "public_key"})
+	if prekeyID != 0 {
+		rows.AddRow(prekeyID, deviceID, 100+prekeyID, []byte("prekey"))
+	}
+	mock.ExpectQuery("SELECT \\* FROM `one_time_prekeys` WHERE device_id = \\? ORDER BY id,`one_time_prekeys`.`id` LIMIT 1 FOR UPDATE SKIP LOCKED").
+		WithArgs(deviceID).
+		WillReturnRows(rows)
+	if prekeyID != 0 {
+		mock.ExpectExec("DELETE FROM `one_time_prekeys` WHERE `one_time_prekeys`.`id` = \\?").
+			WithArgs(prekeyID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM `one_time_prekeys` WHERE device_id = \\?").
+		WithArgs(deviceID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(remaining))
+	mock.ExpectCommit()
+}
+
+func TestBundlesHandsOutOnePrekeyPerDevice(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery(devicesQuery).WithArgs(7).WillReturnRows(deviceRows(1, 2))
+	expectHandout(mock, 1, 11, 4)
+	expectHandout(mock, 2, 0, 0)
+
+	bundles, err := Bundles(7, 0)
+	if err != nil {
+		t.Fatalf("Bundles() failed: %v", err)
+	}
+	if len(bundles) != 2 {
+		t.Fatalf("got %d bundles, want 2", len(bundles))
+	}
+
+	if p := bundles[0].Prekey; p == nil || p.ID != 11 || p.KeyID != 111 {
+		t.Errorf("device 1 got prekey %+v, want prekey 11", p)
+	}
+	if bundles[0].Remaining != 4 {
+		t.Errorf("device 1 has %d prekeys left, want 4", bundles[0].Remaining)
+	}
+	// A device out of one-time prekeys still has its signed prekey
+	if bundles[1].Prekey != nil || bundles[1].Remaining != 0 {
+		t.Errorf("device 2 got prekey %+v with %d left, want none", bundles[1].Prekey, bundles[1].Remaining)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBundlesOfOneDevice(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery(devicesQuery+" AND id = \\?").WithArgs(7, 2).WillReturnRows(deviceRows(2))
+	expectHandout(mock, 2, 21, 0)
+
+	bundles, err := Bundles(7, 2)
+	if err != nil {
+		t.Fatalf("Bundles() failed: %v", err)
+	}
+	if len(bundles) != 1 || bundles[0].Device.ID != 2 || bundles[0].Prekey == nil {
+		t.Fatalf("got bundles %+v, want device 2 with a prekey", bundles)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBundlesOfUnknownDevice(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectQuery(devicesQuery+" AND id = \\?").WithArgs(7, 3).WillReturnRows(deviceRows())
+
+	if _, err := Bundles(7, 3); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("Bundles() = %v, want %v", err, ErrDeviceNotFound)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
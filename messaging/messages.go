@@ -0,0 +1,144 @@
+package messaging
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Aloys-y/chat-go/db"
+	"github.com/Aloys-y/chat-go/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxFetch bounds how many messages a device fetches at once
+const MaxFetch = 100
+
+// DeviceMismatchError rejects a message that wasn't encrypted for exactly
+// the recipient's current devices. Missing devices need a session first,
+// stale ones were removed; the sender should refresh the device list.
+type DeviceMismatchError struct {
+	Missing []uint
+	Stale   []uint
+}
+
+func (e *DeviceMismatchError) Error() string {
+	return fmt.Sprintf("device list changed: missing devices %v, stale devices %v", e.Missing, e.Stale)
+}
+
+// Send stores a message the sender's device encrypted once for each device
+// of the recipient. Messages to oneself go to one's other devices. It
+// returns the stored messages.
+func Send(senderID, senderDeviceID, recipientID uint, messages []models.EncryptedMessage) ([]models.EncryptedMessage, error) {
+	if _, err := OwnDevice(senderID, senderDeviceID); err != nil {
+		return nil, err
+	}
+
+	devices, err := DeviceIDs(recipientID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDevices(devices, messages, senderDeviceID); err != nil {
+		return nil, err
+	}
+
+	for i := range messages {
+		messages[i].ID = 0
+		messages[i].SenderID = senderID
+		messages[i].SenderDeviceID = senderDeviceID
+		messages[i].RecipientID = recipientID
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&messages).Error; err != nil {
+			return fmt.Errorf("failed to store messages: %w", err)
+		}
+		if senderID == recipientID {
+			return nil
+		}
+		contacts := []models.DirectContact{
+			{UserID: senderID, PeerID: recipientID},
+			{UserID: recipientID, PeerID: senderID},
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&contacts).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// checkDevices verifies that the messages address each of the recipient's
+// devices once, leaving out the sending device. A recipient without any
+// other device can't be sent anything.
+func checkDevices(devices []uint, messages []models.EncryptedMessage, senderDeviceID uint) error {
+	expected := make(map[uint]bool, len(devices))
+	for _, id := range devices {
+		if id != senderDeviceID {
+			expected[id] = true
+		}
+	}
+	if len(expected) == 0 {
+		return ErrNoDevices
+	}
+
+	var mismatch DeviceMismatchError
+	addressed := make(map[uint]bool, len(messages))
+	for _, m := range messages {
+		if addressed[m.RecipientDeviceID] {
+			return ErrDuplicateDevice
+		}
+		addressed[m.RecipientDeviceID] = true
+		if !expected[m.RecipientDeviceID] {
+			mismatch.Stale = append(mismatch.Stale, m.RecipientDeviceID)
+		}
+	}
+	for id := range expected {
+		if !addressed[id] {
+			mismatch.Missing = append(mismatch.Missing, id)
+		}
+	}
+
+	if len(mismatch.Missing) > 0 || len(mismatch.Stale) > 0 {
+		sort.Slice(mismatch.Missing, func(i, j int) bool { return mismatch.Missing[i] < mismatch.Missing[j] })
+		sort.Slice(mismatch.Stale, func(i, j int) bool { return mismatch.Stale[i] < mismatch.Stale[j] })
+		return &mismatch
+	}
+	return nil
+}
+
+// Pending returns up to limit messages waiting for the device, oldest first,
+// and whether more are waiting
+func Pending(deviceID uint, limit int) ([]models.EncryptedMessage, bool, error) {
+	if limit <= 0 || limit > MaxFetch {
+		limit = MaxFetch
+	}
+
+	var messages []models.EncryptedMessage
+	err := db.DB.Where("recipient_device_id = ?", deviceID).
+		Order("id").
+		Limit(limit + 1).
+		Find(&messages).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(messages) > limit {
+		return messages[:limit], true, nil
+	}
+	return messages, false, nil
+}
+
+// Ack deletes messages the device received
+func Ack(deviceID uint, messageIDs []uint) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	return db.DB.Where("recipient_device_id = ? AND id IN ?", deviceID, messageIDs).
+		Delete(&models.EncryptedMessage{}).Error
+}
+
+// Contacts returns the users who exchanged direct messages with the user
+func Contacts(userID uint) ([]uint, error) {
+	var peers []uint
+	err := db.DB.Model(&models.DirectContact{}).Where("user_id = ?", userID).Pluck("peer_id", &peers).Error
+	return peers, err
+}
@@ -0,0 +1,95 @@
+package messaging
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Aloys-y/chat-go/models"
+)
+
+// addressed builds one message for each of the devices
+func addressed(deviceIDs ...uint) []models.EncryptedMessage {
+	messages := make([]models.EncryptedMessage, len(deviceIDs))
+	for i, id := range deviceIDs {
+		messages[i].RecipientDeviceID = id
+	}
+	return messages
+}
+
+func TestCheckDevices(t *testing.T) {
+	tests := []struct {
+		name     string
+		devices  []uint
+		messages []models.EncryptedMessage
+		sender   uint
+		want     error
+	}{
+		{
+			name:     "every device addressed",
+			devices:  []uint{1, 2, 3},
+			messages: addressed(3, 1, 2),
+			sender:   9,
+		},
+		{
+			name:     "sending device left out",
+			devices:  []uint{1, 2, 3},
+			messages: addressed(1, 3),
+			sender:   2,
+		},
+		{
+			name:     "missing and stale devices",
+			devices:  []uint{1, 2, 3},
+			messages: addressed(4, 2),
+			sender:   9,
+			want:     &DeviceMismatchError{Missing: []uint{1, 3}, Stale: []uint{4}},
+		},
+		{
+			name:     "sending device addressed",
+			devices:  []uint{1, 2},
+			messages: addressed(1, 2),
+			sender:   2,
+			want:     &DeviceMismatchError{Stale: []uint{2}},
+		},
+		{
+			name:     "device addressed twice",
+			devices:  []uint{1, 2},
+			messages: addressed(1, 2, 1),
+			sender:   9,
+			want:     ErrDuplicateDevice,
+		},
+		{
+			name:     "recipient without devices",
+			messages: addressed(1),
+			sender:   9,
+			want:     ErrNoDevices,
+		},
+		{
+			name:    "only the sending device",
+			devices: []uint{2},
+			sender:  2,
+			want:    ErrNoDevices,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDevices(tt.devices, tt.messages, tt.sender)
+
+			var want *DeviceMismatchError
+			if errors.As(tt.want, &want) {
+				var got *DeviceMismatchError
+				if !errors.As(err, &got) {
+					t.Fatalf("checkDevices() = %v, want %v", err, tt.want)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("checkDevices() = %+v, want %+v", got, want)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("checkDevices() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
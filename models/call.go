@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Call statuses. An answered call becomes completed when it ends; a call
+// that was cancelled or rang out is missed.
+const (
+	CallRinging   = "ringing"
+	CallAnswered  = "answered"
+	CallCompleted = "completed"
+	CallDeclined  = "declined"
+	CallMissed    = "missed"
+)
+
+// CallLog is a direct call from one user to another. RoomID is the ad-hoc
+// room created when the call was answered.
+type CallLog struct {
+	gorm.Model
+	CallerID   uint   `gorm:"index;not null"`
+	CalleeID   uint   `gorm:"index;not null"`
+	Video      bool   `gorm:"default:false"`
+	Status     string `gorm:"size:16;index;not null"`
+	RoomID     *uint
+	StartedAt  time.Time
+	AnsweredAt *time.Time
+	EndedAt    *time.Time
+}
+
+// Duration returns how long the answered call lasted, or zero
+func (c *CallLog) Duration() time.Duration {
+	if c.AnsweredAt == nil || c.EndedAt == nil {
+		return 0
+	}
+	return c.EndedAt.Sub(*c.AnsweredAt)
+}
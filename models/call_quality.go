@@ -0,0 +1,32 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// CallQuality aggregates the connection stats a participant reported during a
+// call session. Dividing the sums by Reports gives the averages.
+type CallQuality struct {
+	gorm.Model
+	SessionID       uint `gorm:"uniqueIndex:idx_call_quality_participant;not null"`
+	UserID          uint `gorm:"uniqueIndex:idx_call_quality_participant;not null"`
+	Reports         int  `gorm:"default:0"`
+	RTTSumMs        float64
+	RTTMaxMs        float64
+	JitterSumMs     float64
+	JitterMaxMs     float64
+	PacketLossSum   float64
+	PacketLossMax   float64
+	InboundKbpsSum  float64
+	OutboundKbpsSum float64
+}
+
+// QualitySample is one summarized stats report of a participant. PacketLoss
+// is the fraction of packets lost, from 0 to 1.
+type QualitySample struct {
+	RTTMs        float64
+	JitterMs     float64
+	PacketLoss   float64
+	InboundKbps  float64
+	OutboundKbps float64
+}
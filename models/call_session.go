@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CallSession is a stretch of time during which a room had someone in its
+// live voice session. It starts when the first participant joins and ends
+// when the last one leaves.
+type CallSession struct {
+	gorm.Model
+	RoomID           uint `gorm:"index;not null"`
+	StartedAt        time.Time
+	EndedAt          *time.Time        `gorm:"index"`
+	PeakParticipants int               `gorm:"default:0"`
+	Participants     []CallParticipant `gorm:"foreignKey:SessionID"`
+}
+
+// Duration returns how long the session lasted, or has lasted so far
+func (s *CallSession) Duration() time.Duration {
+	if s.EndedAt == nil {
+		return time.Since(s.StartedAt)
+	}
+	return s.EndedAt.Sub(s.StartedAt)
+}
+
+// CallParticipant is one interval a user spent in a call session. A user who
+// leaves and comes back gets another interval. NodeID is the signaling node
+// the user is connected to, which refreshes SeenAt on every heartbeat while
+// the interval is open.
+type CallParticipant struct {
+	gorm.Model
+	SessionID uint   `gorm:"index;not null"`
+	UserID    uint   `gorm:"index;not null"`
+	NodeID    string `gorm:"size:255;index"`
+	JoinedAt  time.Time
+	LeftAt    *time.Time
+	SeenAt    time.Time
+}
+
+// Duration returns how long the user stayed, or has stayed so far
+func (p *CallParticipant) Duration() time.Duration {
+	if p.LeftAt == nil {
+		return time.Since(p.JoinedAt)
+	}
+	return p.LeftAt.Sub(p.JoinedAt)
+}
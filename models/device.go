@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Device is an installation of a user's client taking part in end-to-end
+// encrypted direct messages. Only public keys are stored: the long-term
+// identity key and the signed prekey, whose signature clients verify.
+type Device struct {
+	gorm.Model
+	UserID                uint   `gorm:"index;not null"`
+	Name                  string `gorm:"size:64"`
+	IdentityKey           []byte `gorm:"type:varbinary(255);not null"`
+	SignedPrekeyID        uint32
+	SignedPrekey          []byte `gorm:"type:varbinary(255)"`
+	SignedPrekeySignature []byte `gorm:"type:varbinary(255)"`
+}
+
+// OneTimePrekey is a prekey of a device handed out with a single prekey
+// bundle and then deleted
+type OneTimePrekey struct {
+	ID        uint   `gorm:"primarykey"`
+	DeviceID  uint   `gorm:"uniqueIndex:idx_one_time_prekey;not null"`
+	KeyID     uint32 `gorm:"uniqueIndex:idx_one_time_prekey"`
+	PublicKey []byte `gorm:"type:varbinary(255);not null"`
+}
+
+// EncryptedMessage is a direct message encrypted for one device of the
+// recipient. Type is the client's hint on how to decrypt it, e.g. whether it
+// starts a session. The server can't read it and deletes it once the device
+// acknowledged it.
+type EncryptedMessage struct {
+	ID                uint   `gorm:"primarykey"`
+	SenderID          uint   `gorm:"not null"`
+	SenderDeviceID    uint   `gorm:"not null"`
+	RecipientID       uint   `gorm:"index;not null"`
+	RecipientDeviceID uint   `gorm:"index;not null"`
+	Type              int32  `gorm:"not null"`
+	Ciphertext        []byte `gorm:"type:mediumblob;not null"`
+	CreatedAt         time.Time
+}
+
+// DirectContact records that UserID exchanged direct messages with PeerID,
+// so PeerID learns when UserID's devices change
+type DirectContact struct {
+	UserID uint `gorm:"primaryKey;autoIncrement:false"`
+	PeerID uint `gorm:"primaryKey;autoIncrement:false"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// ModerationAction records a moderator's action on a participant of a live
+// room, such as asking them to mute
+type ModerationAction struct {
+	gorm.Model
+	RoomID      uint   `gorm:"index;not null"`
+	ModeratorID uint   `gorm:"not null"`
+	UserID      uint   `gorm:"not null"`
+	Action      string `gorm:"size:32;not null"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Recording statuses
+const (
+	RecordingActive    = "recording"
+	RecordingCompleted = "completed"
+	RecordingFailed    = "failed"
+)
+
+type Recording struct {
+	gorm.Model
+	RoomID     uint   `gorm:"index;not null"`
+	StartedBy  uint   `gorm:"not null"`
+	Status     string `gorm:"size:16;not null"`
+	StartedAt  time.Time
+	StoppedAt  *time.Time
+	DurationMs int64
+	Files      []RecordingFile
+}
+
+// RecordingFile is an Ogg/Opus file of a recording: the audio of one
+// participant, or the room mix when UserID is 0
+type RecordingFile struct {
+	gorm.Model
+	RecordingID uint   `gorm:"index;not null"`
+	UserID      uint   `gorm:"index"`
+	BlobKey     string `gorm:"not null"`
+	Size        int64
+}
+
+// ParticipantIDs returns the users whose audio was recorded
+func (r *Recording) ParticipantIDs() []uint {
+	seen := make(map[uint]bool)
+	var ids []uint
+	for _, f := range r.Files {
+		if f.UserID != 0 && !seen[f.UserID] {
+			seen[f.UserID] = true
+			ids = append(ids, f.UserID)
+		}
+	}
+	return ids
+}
@@ -1,15 +1,78 @@
 package models
 
 import (
+	"strings"
+
 	"gorm.io/gorm"
 )
 
+// Media modes of a room: full-mesh P2P, or every participant publishing to
+// the server's selective forwarding unit
+const (
+	MediaModeMesh = "mesh"
+	MediaModeSFU  = "sfu"
+)
+
 type Room struct {
 	gorm.Model
-	Name        string   `gorm:"uniqueIndex;not null"`
+	Name        string `gorm:"uniqueIndex;not null"`
 	Description string
-	IsPublic    bool     `gorm:"default:true"`
-	OwnerID     uint     `gorm:"not null"`
-	Owner       *User    `gorm:"foreignKey:OwnerID"`
-	Users       []*User  `gorm:"many2many:user_rooms;"`
-}
\ No newline at end of file
+	IsPublic    bool        `gorm:"default:true"`
+	OwnerID     uint        `gorm:"not null"`
+	Owner       *User       `gorm:"foreignKey:OwnerID"`
+	Users       []*User     `gorm:"many2many:user_rooms;"`
+	MediaMode   string      `gorm:"size:16;default:mesh"`
+	VideoPolicy VideoPolicy `gorm:"embedded;embeddedPrefix:video_"`
+	StageMode   bool        `gorm:"default:false"`
+	// ParentID is set on the breakout rooms of a room
+	ParentID *uint `gorm:"index"`
+	// MaxParticipants caps the live room, 0 means unlimited. Users arriving
+	// when it is full or Locked wait in the lobby.
+	MaxParticipants int  `gorm:"default:0"`
+	Locked          bool `gorm:"default:false"`
+	// CallID is set on the ad-hoc room of an answered direct call
+	CallID      *uint       `gorm:"index"`
+	CodecPolicy CodecPolicy `gorm:"embedded;embeddedPrefix:codec_"`
+	// E2EERequired only lets in clients that encrypt their media end to end
+	E2EERequired bool `gorm:"column:e2ee_required;default:false"`
+}
+
+// VideoPolicy limits the video participants may send in a room. Zero limits
+// mean unlimited.
+type VideoPolicy struct {
+	Disabled       bool `json:"disabled"`
+	MaxWidth       int  `json:"max_width,omitempty"`
+	MaxHeight      int  `json:"max_height,omitempty"`
+	MaxBitrateKbps int  `json:"max_bitrate_kbps,omitempty"`
+}
+
+// Codecs a codec policy can prefer, as MIME types
+var KnownCodecs = []string{
+	"audio/opus", "audio/G722", "audio/PCMU", "audio/PCMA",
+	"video/VP8", "video/VP9", "video/H264", "video/AV1",
+}
+
+// CanonicalCodec returns the known codec matching the MIME type, which is
+// compared case-insensitively
+func CanonicalCodec(mimeType string) (string, bool) {
+	for _, codec := range KnownCodecs {
+		if strings.EqualFold(codec, mimeType) {
+			return codec, true
+		}
+	}
+	return "", false
+}
+
+// CodecPolicy sets the codecs and audio encoding participants use in a room.
+// PreferredCodecs are MIME types in order of preference; SFU rooms only
+// accept published tracks in one of them when any of their kind is listed.
+// OpusDTX and OpusFEC ask for discontinuous transmission and in-band forward
+// error correction, Stereo allows two-channel Opus for music. A zero
+// MaxAudioBitrateKbps is unlimited.
+type CodecPolicy struct {
+	PreferredCodecs     []string `json:"preferred_codecs,omitempty" gorm:"serializer:json;type:varchar(255)"`
+	OpusDTX             bool     `json:"opus_dtx"`
+	OpusFEC             bool     `json:"opus_fec"`
+	Stereo              bool     `json:"stereo"`
+	MaxAudioBitrateKbps int      `json:"max_audio_bitrate_kbps,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type RoomBan struct {
+	gorm.Model
+	RoomID   uint `gorm:"uniqueIndex:idx_room_ban_user;not null"`
+	UserID   uint `gorm:"uniqueIndex:idx_room_ban_user;not null"`
+	BannedBy uint `gorm:"not null"`
+	Reason   string
+}
@@ -14,5 +14,7 @@ type User struct {
 	DisplayName  string    `gorm:"not null"`
 	LastLogin    time.Time
 	IsOnline     bool      `gorm:"default:false"`
+	// IsBot marks the accounts of server-side bot participants
+	IsBot        bool      `gorm:"default:false"`
 	Rooms        []*Room   `gorm:"many2many:user_rooms;"`
 }
\ No newline at end of file
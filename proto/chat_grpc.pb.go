@@ -26,6 +26,7 @@ type UserServiceClient interface {
 	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
 	GetUserInfo(ctx context.Context, in *GetUserInfoRequest, opts ...grpc.CallOption) (*UserInfo, error)
 	UpdateUserStatus(ctx context.Context, in *UpdateUserStatusRequest, opts ...grpc.CallOption) (*Empty, error)
+	GetICEServers(ctx context.Context, in *GetICEServersRequest, opts ...grpc.CallOption) (*ICEServersResponse, error)
 }
 
 type userServiceClient struct {
@@ -72,6 +73,15 @@ func (c *userServiceClient) UpdateUserStatus(ctx context.Context, in *UpdateUser
 	return out, nil
 }
 
+func (c *userServiceClient) GetICEServers(ctx context.Context, in *GetICEServersRequest, opts ...grpc.CallOption) (*ICEServersResponse, error) {
+	out := new(ICEServersResponse)
+	err := c.cc.Invoke(ctx, "/chat.UserService/GetICEServers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserServiceServer is the server API for UserService service.
 // All implementations must embed UnimplementedUserServiceServer
 // for forward compatibility
@@ -80,6 +90,7 @@ type UserServiceServer interface {
 	Login(context.Context, *LoginRequest) (*LoginResponse, error)
 	GetUserInfo(context.Context, *GetUserInfoRequest) (*UserInfo, error)
 	UpdateUserStatus(context.Context, *UpdateUserStatusRequest) (*Empty, error)
+	GetICEServers(context.Context, *GetICEServersRequest) (*ICEServersResponse, error)
 	mustEmbedUnimplementedUserServiceServer()
 }
 
@@ -99,6 +110,9 @@ func (UnimplementedUserServiceServer) GetUserInfo(context.Context, *GetUserInfoR
 func (UnimplementedUserServiceServer) UpdateUserStatus(context.Context, *UpdateUserStatusRequest) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateUserStatus not implemented")
 }
+func (UnimplementedUserServiceServer) GetICEServers(context.Context, *GetICEServersRequest) (*ICEServersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetICEServers not implemented")
+}
 func (UnimplementedUserServiceServer) mustEmbedUnimplementedUserServiceServer() {}
 
 // UnsafeUserServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -184,6 +198,24 @@ func _UserService_UpdateUserStatus_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_GetICEServers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetICEServersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).GetICEServers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.UserService/GetICEServers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).GetICEServers(ctx, req.(*GetICEServersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UserService_ServiceDesc is the grpc.ServiceDesc for UserService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -207,6 +239,10 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateUserStatus",
 			Handler:    _UserService_UpdateUserStatus_Handler,
 		},
+		{
+			MethodName: "GetICEServers",
+			Handler:    _UserService_GetICEServers_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/chat.proto",
@@ -222,6 +258,12 @@ type RoomServiceClient interface {
 	GetRoomInfo(ctx context.Context, in *GetRoomInfoRequest, opts ...grpc.CallOption) (*RoomInfo, error)
 	ListRooms(ctx context.Context, in *ListRoomsRequest, opts ...grpc.CallOption) (*ListRoomsResponse, error)
 	ListRoomUsers(ctx context.Context, in *ListRoomUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error)
+	KickUser(ctx context.Context, in *KickUserRequest, opts ...grpc.CallOption) (*Empty, error)
+	DeleteRoom(ctx context.Context, in *DeleteRoomRequest, opts ...grpc.CallOption) (*Empty, error)
+	SetVideoPolicy(ctx context.Context, in *SetVideoPolicyRequest, opts ...grpc.CallOption) (*RoomInfo, error)
+	SetCodecPolicy(ctx context.Context, in *SetCodecPolicyRequest, opts ...grpc.CallOption) (*RoomInfo, error)
+	CreateBreakouts(ctx context.Context, in *CreateBreakoutsRequest, opts ...grpc.CallOption) (*BreakoutsResponse, error)
+	CloseBreakouts(ctx context.Context, in *CloseBreakoutsRequest, opts ...grpc.CallOption) (*BreakoutsResponse, error)
 }
 
 type roomServiceClient struct {
@@ -286,6 +328,60 @@ func (c *roomServiceClient) ListRoomUsers(ctx context.Context, in *ListRoomUsers
 	return out, nil
 }
 
+func (c *roomServiceClient) KickUser(ctx context.Context, in *KickUserRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/chat.RoomService/KickUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roomServiceClient) DeleteRoom(ctx context.Context, in *DeleteRoomRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/chat.RoomService/DeleteRoom", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roomServiceClient) SetVideoPolicy(ctx context.Context, in *SetVideoPolicyRequest, opts ...grpc.CallOption) (*RoomInfo, error) {
+	out := new(RoomInfo)
+	err := c.cc.Invoke(ctx, "/chat.RoomService/SetVideoPolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roomServiceClient) SetCodecPolicy(ctx context.Context, in *SetCodecPolicyRequest, opts ...grpc.CallOption) (*RoomInfo, error) {
+	out := new(RoomInfo)
+	err := c.cc.Invoke(ctx, "/chat.RoomService/SetCodecPolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roomServiceClient) CreateBreakouts(ctx context.Context, in *CreateBreakoutsRequest, opts ...grpc.CallOption) (*BreakoutsResponse, error) {
+	out := new(BreakoutsResponse)
+	err := c.cc.Invoke(ctx, "/chat.RoomService/CreateBreakouts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *roomServiceClient) CloseBreakouts(ctx context.Context, in *CloseBreakoutsRequest, opts ...grpc.CallOption) (*BreakoutsResponse, error) {
+	out := new(BreakoutsResponse)
+	err := c.cc.Invoke(ctx, "/chat.RoomService/CloseBreakouts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RoomServiceServer is the server API for RoomService service.
 // All implementations must embed UnimplementedRoomServiceServer
 // for forward compatibility
@@ -296,6 +392,12 @@ type RoomServiceServer interface {
 	GetRoomInfo(context.Context, *GetRoomInfoRequest) (*RoomInfo, error)
 	ListRooms(context.Context, *ListRoomsRequest) (*ListRoomsResponse, error)
 	ListRoomUsers(context.Context, *ListRoomUsersRequest) (*ListUsersResponse, error)
+	KickUser(context.Context, *KickUserRequest) (*Empty, error)
+	DeleteRoom(context.Context, *DeleteRoomRequest) (*Empty, error)
+	SetVideoPolicy(context.Context, *SetVideoPolicyRequest) (*RoomInfo, error)
+	SetCodecPolicy(context.Context, *SetCodecPolicyRequest) (*RoomInfo, error)
+	CreateBreakouts(context.Context, *CreateBreakoutsRequest) (*BreakoutsResponse, error)
+	CloseBreakouts(context.Context, *CloseBreakoutsRequest) (*BreakoutsResponse, error)
 	mustEmbedUnimplementedRoomServiceServer()
 }
 
@@ -321,6 +423,24 @@ func (UnimplementedRoomServiceServer) ListRooms(context.Context, *ListRoomsReque
 func (UnimplementedRoomServiceServer) ListRoomUsers(context.Context, *ListRoomUsersRequest) (*ListUsersResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListRoomUsers not implemented")
 }
+func (UnimplementedRoomServiceServer) KickUser(context.Context, *KickUserRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method KickUser not implemented")
+}
+func (UnimplementedRoomServiceServer) DeleteRoom(context.Context, *DeleteRoomRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRoom not implemented")
+}
+func (UnimplementedRoomServiceServer) SetVideoPolicy(context.Context, *SetVideoPolicyRequest) (*RoomInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetVideoPolicy not implemented")
+}
+func (UnimplementedRoomServiceServer) SetCodecPolicy(context.Context, *SetCodecPolicyRequest) (*RoomInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetCodecPolicy not implemented")
+}
+func (UnimplementedRoomServiceServer) CreateBreakouts(context.Context, *CreateBreakoutsRequest) (*BreakoutsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateBreakouts not implemented")
+}
+func (UnimplementedRoomServiceServer) CloseBreakouts(context.Context, *CloseBreakoutsRequest) (*BreakoutsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CloseBreakouts not implemented")
+}
 func (UnimplementedRoomServiceServer) mustEmbedUnimplementedRoomServiceServer() {}
 
 // UnsafeRoomServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -442,6 +562,114 @@ func _RoomService_ListRoomUsers_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RoomService_KickUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KickUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoomServiceServer).KickUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.RoomService/KickUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoomServiceServer).KickUser(ctx, req.(*KickUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RoomService_DeleteRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoomServiceServer).DeleteRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.RoomService/DeleteRoom",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoomServiceServer).DeleteRoom(ctx, req.(*DeleteRoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RoomService_SetVideoPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetVideoPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoomServiceServer).SetVideoPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.RoomService/SetVideoPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoomServiceServer).SetVideoPolicy(ctx, req.(*SetVideoPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RoomService_SetCodecPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetCodecPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoomServiceServer).SetCodecPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.RoomService/SetCodecPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoomServiceServer).SetCodecPolicy(ctx, req.(*SetCodecPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RoomService_CreateBreakouts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateBreakoutsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoomServiceServer).CreateBreakouts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.RoomService/CreateBreakouts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoomServiceServer).CreateBreakouts(ctx, req.(*CreateBreakoutsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RoomService_CloseBreakouts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CloseBreakoutsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RoomServiceServer).CloseBreakouts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.RoomService/CloseBreakouts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RoomServiceServer).CloseBreakouts(ctx, req.(*CloseBreakoutsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // RoomService_ServiceDesc is the grpc.ServiceDesc for RoomService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -473,6 +701,1036 @@ var RoomService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListRoomUsers",
 			Handler:    _RoomService_ListRoomUsers_Handler,
 		},
+		{
+			MethodName: "KickUser",
+			Handler:    _RoomService_KickUser_Handler,
+		},
+		{
+			MethodName: "DeleteRoom",
+			Handler:    _RoomService_DeleteRoom_Handler,
+		},
+		{
+			MethodName: "SetVideoPolicy",
+			Handler:    _RoomService_SetVideoPolicy_Handler,
+		},
+		{
+			MethodName: "SetCodecPolicy",
+			Handler:    _RoomService_SetCodecPolicy_Handler,
+		},
+		{
+			MethodName: "CreateBreakouts",
+			Handler:    _RoomService_CreateBreakouts_Handler,
+		},
+		{
+			MethodName: "CloseBreakouts",
+			Handler:    _RoomService_CloseBreakouts_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/chat.proto",
+}
+
+// RecordingServiceClient is the client API for RecordingService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RecordingServiceClient interface {
+	StartRecording(ctx context.Context, in *StartRecordingRequest, opts ...grpc.CallOption) (*RecordingInfo, error)
+	StopRecording(ctx context.Context, in *StopRecordingRequest, opts ...grpc.CallOption) (*RecordingInfo, error)
+	ListRecordings(ctx context.Context, in *ListRecordingsRequest, opts ...grpc.CallOption) (*ListRecordingsResponse, error)
+	DownloadRecording(ctx context.Context, in *DownloadRecordingRequest, opts ...grpc.CallOption) (*RecordingChunk, error)
+}
+
+type recordingServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRecordingServiceClient(cc grpc.ClientConnInterface) RecordingServiceClient {
+	return &recordingServiceClient{cc}
+}
+
+func (c *recordingServiceClient) StartRecording(ctx context.Context, in *StartRecordingRequest, opts ...grpc.CallOption) (*RecordingInfo, error) {
+	out := new(RecordingInfo)
+	err := c.cc.Invoke(ctx, "/chat.RecordingService/StartRecording", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recordingServiceClient) StopRecording(ctx context.Context, in *StopRecordingRequest, opts ...grpc.CallOption) (*RecordingInfo, error) {
+	out := new(RecordingInfo)
+	err := c.cc.Invoke(ctx, "/chat.RecordingService/StopRecording", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recordingServiceClient) ListRecordings(ctx context.Context, in *ListRecordingsRequest, opts ...grpc.CallOption) (*ListRecordingsResponse, error) {
+	out := new(ListRecordingsResponse)
+	err := c.cc.Invoke(ctx, "/chat.RecordingService/ListRecordings", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *recordingServiceClient) DownloadRecording(ctx context.Context, in *DownloadRecordingRequest, opts ...grpc.CallOption) (*RecordingChunk, error) {
+	out := new(RecordingChunk)
+	err := c.cc.Invoke(ctx, "/chat.RecordingService/DownloadRecording", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RecordingServiceServer is the server API for RecordingService service.
+// All implementations must embed UnimplementedRecordingServiceServer
+// for forward compatibility
+type RecordingServiceServer interface {
+	StartRecording(context.Context, *StartRecordingRequest) (*RecordingInfo, error)
+	StopRecording(context.Context, *StopRecordingRequest) (*RecordingInfo, error)
+	ListRecordings(context.Context, *ListRecordingsRequest) (*ListRecordingsResponse, error)
+	DownloadRecording(context.Context, *DownloadRecordingRequest) (*RecordingChunk, error)
+	mustEmbedUnimplementedRecordingServiceServer()
+}
+
+// UnimplementedRecordingServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedRecordingServiceServer struct {
+}
+
+func (UnimplementedRecordingServiceServer) StartRecording(context.Context, *StartRecordingRequest) (*RecordingInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartRecording not implemented")
+}
+func (UnimplementedRecordingServiceServer) StopRecording(context.Context, *StopRecordingRequest) (*RecordingInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopRecording not implemented")
+}
+func (UnimplementedRecordingServiceServer) ListRecordings(context.Context, *ListRecordingsRequest) (*ListRecordingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRecordings not implemented")
+}
+func (UnimplementedRecordingServiceServer) DownloadRecording(context.Context, *DownloadRecordingRequest) (*RecordingChunk, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DownloadRecording not implemented")
+}
+func (UnimplementedRecordingServiceServer) mustEmbedUnimplementedRecordingServiceServer() {}
+
+// UnsafeRecordingServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RecordingServiceServer will
+// result in compilation errors.
+type UnsafeRecordingServiceServer interface {
+	mustEmbedUnimplementedRecordingServiceServer()
+}
+
+func RegisterRecordingServiceServer(s grpc.ServiceRegistrar, srv RecordingServiceServer) {
+	s.RegisterService(&RecordingService_ServiceDesc, srv)
+}
+
+func _RecordingService_StartRecording_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartRecordingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecordingServiceServer).StartRecording(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.RecordingService/StartRecording",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecordingServiceServer).StartRecording(ctx, req.(*StartRecordingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecordingService_StopRecording_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopRecordingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecordingServiceServer).StopRecording(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.RecordingService/StopRecording",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecordingServiceServer).StopRecording(ctx, req.(*StopRecordingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecordingService_ListRecordings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRecordingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecordingServiceServer).ListRecordings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.RecordingService/ListRecordings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecordingServiceServer).ListRecordings(ctx, req.(*ListRecordingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RecordingService_DownloadRecording_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DownloadRecordingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RecordingServiceServer).DownloadRecording(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.RecordingService/DownloadRecording",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RecordingServiceServer).DownloadRecording(ctx, req.(*DownloadRecordingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RecordingService_ServiceDesc is the grpc.ServiceDesc for RecordingService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RecordingService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.RecordingService",
+	HandlerType: (*RecordingServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartRecording",
+			Handler:    _RecordingService_StartRecording_Handler,
+		},
+		{
+			MethodName: "StopRecording",
+			Handler:    _RecordingService_StopRecording_Handler,
+		},
+		{
+			MethodName: "ListRecordings",
+			Handler:    _RecordingService_ListRecordings_Handler,
+		},
+		{
+			MethodName: "DownloadRecording",
+			Handler:    _RecordingService_DownloadRecording_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/chat.proto",
+}
+
+// CallServiceClient is the client API for CallService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CallServiceClient interface {
+	ListCallHistory(ctx context.Context, in *ListCallHistoryRequest, opts ...grpc.CallOption) (*ListCallHistoryResponse, error)
+	ListCallSessions(ctx context.Context, in *ListCallSessionsRequest, opts ...grpc.CallOption) (*ListCallSessionsResponse, error)
+	GetCallQuality(ctx context.Context, in *GetCallQualityRequest, opts ...grpc.CallOption) (*CallQualityResponse, error)
+}
+
+type callServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCallServiceClient(cc grpc.ClientConnInterface) CallServiceClient {
+	return &callServiceClient{cc}
+}
+
+func (c *callServiceClient) ListCallHistory(ctx context.Context, in *ListCallHistoryRequest, opts ...grpc.CallOption) (*ListCallHistoryResponse, error) {
+	out := new(ListCallHistoryResponse)
+	err := c.cc.Invoke(ctx, "/chat.CallService/ListCallHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *callServiceClient) ListCallSessions(ctx context.Context, in *ListCallSessionsRequest, opts ...grpc.CallOption) (*ListCallSessionsResponse, error) {
+	out := new(ListCallSessionsResponse)
+	err := c.cc.Invoke(ctx, "/chat.CallService/ListCallSessions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *callServiceClient) GetCallQuality(ctx context.Context, in *GetCallQualityRequest, opts ...grpc.CallOption) (*CallQualityResponse, error) {
+	out := new(CallQualityResponse)
+	err := c.cc.Invoke(ctx, "/chat.CallService/GetCallQuality", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CallServiceServer is the server API for CallService service.
+// All implementations must embed UnimplementedCallServiceServer
+// for forward compatibility
+type CallServiceServer interface {
+	ListCallHistory(context.Context, *ListCallHistoryRequest) (*ListCallHistoryResponse, error)
+	ListCallSessions(context.Context, *ListCallSessionsRequest) (*ListCallSessionsResponse, error)
+	GetCallQuality(context.Context, *GetCallQualityRequest) (*CallQualityResponse, error)
+	mustEmbedUnimplementedCallServiceServer()
+}
+
+// UnimplementedCallServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCallServiceServer struct {
+}
+
+func (UnimplementedCallServiceServer) ListCallHistory(context.Context, *ListCallHistoryRequest) (*ListCallHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCallHistory not implemented")
+}
+func (UnimplementedCallServiceServer) ListCallSessions(context.Context, *ListCallSessionsRequest) (*ListCallSessionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCallSessions not implemented")
+}
+func (UnimplementedCallServiceServer) GetCallQuality(context.Context, *GetCallQualityRequest) (*CallQualityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCallQuality not implemented")
+}
+func (UnimplementedCallServiceServer) mustEmbedUnimplementedCallServiceServer() {}
+
+// UnsafeCallServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CallServiceServer will
+// result in compilation errors.
+type UnsafeCallServiceServer interface {
+	mustEmbedUnimplementedCallServiceServer()
+}
+
+func RegisterCallServiceServer(s grpc.ServiceRegistrar, srv CallServiceServer) {
+	s.RegisterService(&CallService_ServiceDesc, srv)
+}
+
+func _CallService_ListCallHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCallHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CallServiceServer).ListCallHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.CallService/ListCallHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CallServiceServer).ListCallHistory(ctx, req.(*ListCallHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CallService_ListCallSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCallSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CallServiceServer).ListCallSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.CallService/ListCallSessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CallServiceServer).ListCallSessions(ctx, req.(*ListCallSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CallService_GetCallQuality_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCallQualityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CallServiceServer).GetCallQuality(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.CallService/GetCallQuality",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CallServiceServer).GetCallQuality(ctx, req.(*GetCallQualityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CallService_ServiceDesc is the grpc.ServiceDesc for CallService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CallService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.CallService",
+	HandlerType: (*CallServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListCallHistory",
+			Handler:    _CallService_ListCallHistory_Handler,
+		},
+		{
+			MethodName: "ListCallSessions",
+			Handler:    _CallService_ListCallSessions_Handler,
+		},
+		{
+			MethodName: "GetCallQuality",
+			Handler:    _CallService_GetCallQuality_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/chat.proto",
+}
+
+// MessagingServiceClient is the client API for MessagingService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MessagingServiceClient interface {
+	RegisterDevice(ctx context.Context, in *RegisterDeviceRequest, opts ...grpc.CallOption) (*DeviceInfo, error)
+	RemoveDevice(ctx context.Context, in *RemoveDeviceRequest, opts ...grpc.CallOption) (*Empty, error)
+	ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error)
+	UploadPrekeys(ctx context.Context, in *UploadPrekeysRequest, opts ...grpc.CallOption) (*PrekeyCountResponse, error)
+	FetchPrekeyBundle(ctx context.Context, in *FetchPrekeyBundleRequest, opts ...grpc.CallOption) (*PrekeyBundleResponse, error)
+	SendEncryptedMessage(ctx context.Context, in *SendEncryptedMessageRequest, opts ...grpc.CallOption) (*SendEncryptedMessageResponse, error)
+	FetchMessages(ctx context.Context, in *FetchMessagesRequest, opts ...grpc.CallOption) (*FetchMessagesResponse, error)
+	AckMessages(ctx context.Context, in *AckMessagesRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type messagingServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMessagingServiceClient(cc grpc.ClientConnInterface) MessagingServiceClient {
+	return &messagingServiceClient{cc}
+}
+
+func (c *messagingServiceClient) RegisterDevice(ctx context.Context, in *RegisterDeviceRequest, opts ...grpc.CallOption) (*DeviceInfo, error) {
+	out := new(DeviceInfo)
+	err := c.cc.Invoke(ctx, "/chat.MessagingService/RegisterDevice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messagingServiceClient) RemoveDevice(ctx context.Context, in *RemoveDeviceRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/chat.MessagingService/RemoveDevice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messagingServiceClient) ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error) {
+	out := new(ListDevicesResponse)
+	err := c.cc.Invoke(ctx, "/chat.MessagingService/ListDevices", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messagingServiceClient) UploadPrekeys(ctx context.Context, in *UploadPrekeysRequest, opts ...grpc.CallOption) (*PrekeyCountResponse, error) {
+	out := new(PrekeyCountResponse)
+	err := c.cc.Invoke(ctx, "/chat.MessagingService/UploadPrekeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messagingServiceClient) FetchPrekeyBundle(ctx context.Context, in *FetchPrekeyBundleRequest, opts ...grpc.CallOption) (*PrekeyBundleResponse, error) {
+	out := new(PrekeyBundleResponse)
+	err := c.cc.Invoke(ctx, "/chat.MessagingService/FetchPrekeyBundle", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messagingServiceClient) SendEncryptedMessage(ctx context.Context, in *SendEncryptedMessageRequest, opts ...grpc.CallOption) (*SendEncryptedMessageResponse, error) {
+	out := new(SendEncryptedMessageResponse)
+	err := c.cc.Invoke(ctx, "/chat.MessagingService/SendEncryptedMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messagingServiceClient) FetchMessages(ctx context.Context, in *FetchMessagesRequest, opts ...grpc.CallOption) (*FetchMessagesResponse, error) {
+	out := new(FetchMessagesResponse)
+	err := c.cc.Invoke(ctx, "/chat.MessagingService/FetchMessages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messagingServiceClient) AckMessages(ctx context.Context, in *AckMessagesRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/chat.MessagingService/AckMessages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MessagingServiceServer is the server API for MessagingService service.
+// All implementations must embed UnimplementedMessagingServiceServer
+// for forward compatibility
+type MessagingServiceServer interface {
+	RegisterDevice(context.Context, *RegisterDeviceRequest) (*DeviceInfo, error)
+	RemoveDevice(context.Context, *RemoveDeviceRequest) (*Empty, error)
+	ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error)
+	UploadPrekeys(context.Context, *UploadPrekeysRequest) (*PrekeyCountResponse, error)
+	FetchPrekeyBundle(context.Context, *FetchPrekeyBundleRequest) (*PrekeyBundleResponse, error)
+	SendEncryptedMessage(context.Context, *SendEncryptedMessageRequest) (*SendEncryptedMessageResponse, error)
+	FetchMessages(context.Context, *FetchMessagesRequest) (*FetchMessagesResponse, error)
+	AckMessages(context.Context, *AckMessagesRequest) (*Empty, error)
+	mustEmbedUnimplementedMessagingServiceServer()
+}
+
+// UnimplementedMessagingServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedMessagingServiceServer struct {
+}
+
+func (UnimplementedMessagingServiceServer) RegisterDevice(context.Context, *RegisterDeviceRequest) (*DeviceInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterDevice not implemented")
+}
+func (UnimplementedMessagingServiceServer) RemoveDevice(context.Context, *RemoveDeviceRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveDevice not implemented")
+}
+func (UnimplementedMessagingServiceServer) ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDevices not implemented")
+}
+func (UnimplementedMessagingServiceServer) UploadPrekeys(context.Context, *UploadPrekeysRequest) (*PrekeyCountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadPrekeys not implemented")
+}
+func (UnimplementedMessagingServiceServer) FetchPrekeyBundle(context.Context, *FetchPrekeyBundleRequest) (*PrekeyBundleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FetchPrekeyBundle not implemented")
+}
+func (UnimplementedMessagingServiceServer) SendEncryptedMessage(context.Context, *SendEncryptedMessageRequest) (*SendEncryptedMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendEncryptedMessage not implemented")
+}
+func (UnimplementedMessagingServiceServer) FetchMessages(context.Context, *FetchMessagesRequest) (*FetchMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FetchMessages not implemented")
+}
+func (UnimplementedMessagingServiceServer) AckMessages(context.Context, *AckMessagesRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AckMessages not implemented")
+}
+func (UnimplementedMessagingServiceServer) mustEmbedUnimplementedMessagingServiceServer() {}
+
+// UnsafeMessagingServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MessagingServiceServer will
+// result in compilation errors.
+type UnsafeMessagingServiceServer interface {
+	mustEmbedUnimplementedMessagingServiceServer()
+}
+
+func RegisterMessagingServiceServer(s grpc.ServiceRegistrar, srv MessagingServiceServer) {
+	s.RegisterService(&MessagingService_ServiceDesc, srv)
+}
+
+func _MessagingService_RegisterDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessagingServiceServer).RegisterDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.MessagingService/RegisterDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessagingServiceServer).RegisterDevice(ctx, req.(*RegisterDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessagingService_RemoveDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessagingServiceServer).RemoveDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.MessagingService/RemoveDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessagingServiceServer).RemoveDevice(ctx, req.(*RemoveDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessagingService_ListDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessagingServiceServer).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.MessagingService/ListDevices",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessagingServiceServer).ListDevices(ctx, req.(*ListDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessagingService_UploadPrekeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadPrekeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessagingServiceServer).UploadPrekeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.MessagingService/UploadPrekeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessagingServiceServer).UploadPrekeys(ctx, req.(*UploadPrekeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessagingService_FetchPrekeyBundle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FetchPrekeyBundleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessagingServiceServer).FetchPrekeyBundle(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.MessagingService/FetchPrekeyBundle",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessagingServiceServer).FetchPrekeyBundle(ctx, req.(*FetchPrekeyBundleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessagingService_SendEncryptedMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendEncryptedMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessagingServiceServer).SendEncryptedMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.MessagingService/SendEncryptedMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessagingServiceServer).SendEncryptedMessage(ctx, req.(*SendEncryptedMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessagingService_FetchMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FetchMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessagingServiceServer).FetchMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.MessagingService/FetchMessages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessagingServiceServer).FetchMessages(ctx, req.(*FetchMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessagingService_AckMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AckMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessagingServiceServer).AckMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.MessagingService/AckMessages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessagingServiceServer).AckMessages(ctx, req.(*AckMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// MessagingService_ServiceDesc is the grpc.ServiceDesc for MessagingService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MessagingService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.MessagingService",
+	HandlerType: (*MessagingServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RegisterDevice",
+			Handler:    _MessagingService_RegisterDevice_Handler,
+		},
+		{
+			MethodName: "RemoveDevice",
+			Handler:    _MessagingService_RemoveDevice_Handler,
+		},
+		{
+			MethodName: "ListDevices",
+			Handler:    _MessagingService_ListDevices_Handler,
+		},
+		{
+			MethodName: "UploadPrekeys",
+			Handler:    _MessagingService_UploadPrekeys_Handler,
+		},
+		{
+			MethodName: "FetchPrekeyBundle",
+			Handler:    _MessagingService_FetchPrekeyBundle_Handler,
+		},
+		{
+			MethodName: "SendEncryptedMessage",
+			Handler:    _MessagingService_SendEncryptedMessage_Handler,
+		},
+		{
+			MethodName: "FetchMessages",
+			Handler:    _MessagingService_FetchMessages_Handler,
+		},
+		{
+			MethodName: "AckMessages",
+			Handler:    _MessagingService_AckMessages_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/chat.proto",
+}
+
+// BotServiceClient is the client API for BotService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BotServiceClient interface {
+	StartBot(ctx context.Context, in *StartBotRequest, opts ...grpc.CallOption) (*BotInfo, error)
+	StopBot(ctx context.Context, in *StopBotRequest, opts ...grpc.CallOption) (*BotInfo, error)
+	ListBots(ctx context.Context, in *ListBotsRequest, opts ...grpc.CallOption) (*ListBotsResponse, error)
+}
+
+type botServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBotServiceClient(cc grpc.ClientConnInterface) BotServiceClient {
+	return &botServiceClient{cc}
+}
+
+func (c *botServiceClient) StartBot(ctx context.Context, in *StartBotRequest, opts ...grpc.CallOption) (*BotInfo, error) {
+	out := new(BotInfo)
+	err := c.cc.Invoke(ctx, "/chat.BotService/StartBot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *botServiceClient) StopBot(ctx context.Context, in *StopBotRequest, opts ...grpc.CallOption) (*BotInfo, error) {
+	out := new(BotInfo)
+	err := c.cc.Invoke(ctx, "/chat.BotService/StopBot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *botServiceClient) ListBots(ctx context.Context, in *ListBotsRequest, opts ...grpc.CallOption) (*ListBotsResponse, error) {
+	out := new(ListBotsResponse)
+	err := c.cc.Invoke(ctx, "/chat.BotService/ListBots", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BotServiceServer is the server API for BotService service.
+// All implementations must embed UnimplementedBotServiceServer
+// for forward compatibility
+type BotServiceServer interface {
+	StartBot(context.Context, *StartBotRequest) (*BotInfo, error)
+	StopBot(context.Context, *StopBotRequest) (*BotInfo, error)
+	ListBots(context.Context, *ListBotsRequest) (*ListBotsResponse, error)
+	mustEmbedUnimplementedBotServiceServer()
+}
+
+// UnimplementedBotServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedBotServiceServer struct {
+}
+
+func (UnimplementedBotServiceServer) StartBot(context.Context, *StartBotRequest) (*BotInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartBot not implemented")
+}
+func (UnimplementedBotServiceServer) StopBot(context.Context, *StopBotRequest) (*BotInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopBot not implemented")
+}
+func (UnimplementedBotServiceServer) ListBots(context.Context, *ListBotsRequest) (*ListBotsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBots not implemented")
+}
+func (UnimplementedBotServiceServer) mustEmbedUnimplementedBotServiceServer() {}
+
+// UnsafeBotServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BotServiceServer will
+// result in compilation errors.
+type UnsafeBotServiceServer interface {
+	mustEmbedUnimplementedBotServiceServer()
+}
+
+func RegisterBotServiceServer(s grpc.ServiceRegistrar, srv BotServiceServer) {
+	s.RegisterService(&BotService_ServiceDesc, srv)
+}
+
+func _BotService_StartBot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartBotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BotServiceServer).StartBot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.BotService/StartBot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BotServiceServer).StartBot(ctx, req.(*StartBotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BotService_StopBot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopBotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BotServiceServer).StopBot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.BotService/StopBot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BotServiceServer).StopBot(ctx, req.(*StopBotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BotService_ListBots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBotsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BotServiceServer).ListBots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.BotService/ListBots",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BotServiceServer).ListBots(ctx, req.(*ListBotsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// BotService_ServiceDesc is the grpc.ServiceDesc for BotService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BotService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.BotService",
+	HandlerType: (*BotServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartBot",
+			Handler:    _BotService_StartBot_Handler,
+		},
+		{
+			MethodName: "StopBot",
+			Handler:    _BotService_StopBot_Handler,
+		},
+		{
+			MethodName: "ListBots",
+			Handler:    _BotService_ListBots_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/chat.proto",
+}
+
+// BroadcastServiceClient is the client API for BroadcastService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BroadcastServiceClient interface {
+	StartBroadcast(ctx context.Context, in *StartBroadcastRequest, opts ...grpc.CallOption) (*BroadcastInfo, error)
+	StopBroadcast(ctx context.Context, in *StopBroadcastRequest, opts ...grpc.CallOption) (*BroadcastInfo, error)
+	GetBroadcast(ctx context.Context, in *GetBroadcastRequest, opts ...grpc.CallOption) (*BroadcastInfo, error)
+}
+
+type broadcastServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBroadcastServiceClient(cc grpc.ClientConnInterface) BroadcastServiceClient {
+	return &broadcastServiceClient{cc}
+}
+
+func (c *broadcastServiceClient) StartBroadcast(ctx context.Context, in *StartBroadcastRequest, opts ...grpc.CallOption) (*BroadcastInfo, error) {
+	out := new(BroadcastInfo)
+	err := c.cc.Invoke(ctx, "/chat.BroadcastService/StartBroadcast", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *broadcastServiceClient) StopBroadcast(ctx context.Context, in *StopBroadcastRequest, opts ...grpc.CallOption) (*BroadcastInfo, error) {
+	out := new(BroadcastInfo)
+	err := c.cc.Invoke(ctx, "/chat.BroadcastService/StopBroadcast", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *broadcastServiceClient) GetBroadcast(ctx context.Context, in *GetBroadcastRequest, opts ...grpc.CallOption) (*BroadcastInfo, error) {
+	out := new(BroadcastInfo)
+	err := c.cc.Invoke(ctx, "/chat.BroadcastService/GetBroadcast", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BroadcastServiceServer is the server API for BroadcastService service.
+// All implementations must embed UnimplementedBroadcastServiceServer
+// for forward compatibility
+type BroadcastServiceServer interface {
+	StartBroadcast(context.Context, *StartBroadcastRequest) (*BroadcastInfo, error)
+	StopBroadcast(context.Context, *StopBroadcastRequest) (*BroadcastInfo, error)
+	GetBroadcast(context.Context, *GetBroadcastRequest) (*BroadcastInfo, error)
+	mustEmbedUnimplementedBroadcastServiceServer()
+}
+
+// UnimplementedBroadcastServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedBroadcastServiceServer struct {
+}
+
+func (UnimplementedBroadcastServiceServer) StartBroadcast(context.Context, *StartBroadcastRequest) (*BroadcastInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartBroadcast not implemented")
+}
+func (UnimplementedBroadcastServiceServer) StopBroadcast(context.Context, *StopBroadcastRequest) (*BroadcastInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopBroadcast not implemented")
+}
+func (UnimplementedBroadcastServiceServer) GetBroadcast(context.Context, *GetBroadcastRequest) (*BroadcastInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBroadcast not implemented")
+}
+func (UnimplementedBroadcastServiceServer) mustEmbedUnimplementedBroadcastServiceServer() {}
+
+// UnsafeBroadcastServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BroadcastServiceServer will
+// result in compilation errors.
+type UnsafeBroadcastServiceServer interface {
+	mustEmbedUnimplementedBroadcastServiceServer()
+}
+
+func RegisterBroadcastServiceServer(s grpc.ServiceRegistrar, srv BroadcastServiceServer) {
+	s.RegisterService(&BroadcastService_ServiceDesc, srv)
+}
+
+func _BroadcastService_StartBroadcast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartBroadcastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BroadcastServiceServer).StartBroadcast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.BroadcastService/StartBroadcast",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BroadcastServiceServer).StartBroadcast(ctx, req.(*StartBroadcastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BroadcastService_StopBroadcast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopBroadcastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BroadcastServiceServer).StopBroadcast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.BroadcastService/StopBroadcast",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BroadcastServiceServer).StopBroadcast(ctx, req.(*StopBroadcastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BroadcastService_GetBroadcast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBroadcastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BroadcastServiceServer).GetBroadcast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.BroadcastService/GetBroadcast",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BroadcastServiceServer).GetBroadcast(ctx, req.(*GetBroadcastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// BroadcastService_ServiceDesc is the grpc.ServiceDesc for BroadcastService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BroadcastService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.BroadcastService",
+	HandlerType: (*BroadcastServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartBroadcast",
+			Handler:    _BroadcastService_StartBroadcast_Handler,
+		},
+		{
+			MethodName: "StopBroadcast",
+			Handler:    _BroadcastService_StopBroadcast_Handler,
+		},
+		{
+			MethodName: "GetBroadcast",
+			Handler:    _BroadcastService_GetBroadcast_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/chat.proto",
@@ -318,6 +318,67 @@ proto.chat.UserServicePromiseClient.prototype.updateUserStatus =
 };
 
 
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.GetICEServersRequest,
+ *   !proto.chat.ICEServersResponse>}
+ */
+const methodDescriptor_UserService_GetICEServers = new grpc.web.MethodDescriptor(
+  '/chat.UserService/GetICEServers',
+  grpc.web.MethodType.UNARY,
+  proto.chat.GetICEServersRequest,
+  proto.chat.ICEServersResponse,
+  /**
+   * @param {!proto.chat.GetICEServersRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.ICEServersResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.GetICEServersRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.ICEServersResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.ICEServersResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.UserServiceClient.prototype.getICEServers =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.UserService/GetICEServers',
+      request,
+      metadata || {},
+      methodDescriptor_UserService_GetICEServers,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.GetICEServersRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.ICEServersResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.UserServicePromiseClient.prototype.getICEServers =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.UserService/GetICEServers',
+      request,
+      metadata || {},
+      methodDescriptor_UserService_GetICEServers);
+};
+
+
 /**
  * @param {string} hostname
  * @param {?Object} credentials
@@ -736,5 +797,1912 @@ proto.chat.RoomServicePromiseClient.prototype.listRoomUsers =
 };
 
 
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.KickUserRequest,
+ *   !proto.chat.Empty>}
+ */
+const methodDescriptor_RoomService_KickUser = new grpc.web.MethodDescriptor(
+  '/chat.RoomService/KickUser',
+  grpc.web.MethodType.UNARY,
+  proto.chat.KickUserRequest,
+  proto.chat.Empty,
+  /**
+   * @param {!proto.chat.KickUserRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.Empty.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.KickUserRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.Empty)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.Empty>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.RoomServiceClient.prototype.kickUser =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.RoomService/KickUser',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_KickUser,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.KickUserRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.Empty>}
+ *     Promise that resolves to the response
+ */
+proto.chat.RoomServicePromiseClient.prototype.kickUser =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.RoomService/KickUser',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_KickUser);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.DeleteRoomRequest,
+ *   !proto.chat.Empty>}
+ */
+const methodDescriptor_RoomService_DeleteRoom = new grpc.web.MethodDescriptor(
+  '/chat.RoomService/DeleteRoom',
+  grpc.web.MethodType.UNARY,
+  proto.chat.DeleteRoomRequest,
+  proto.chat.Empty,
+  /**
+   * @param {!proto.chat.DeleteRoomRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.Empty.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.DeleteRoomRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.Empty)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.Empty>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.RoomServiceClient.prototype.deleteRoom =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.RoomService/DeleteRoom',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_DeleteRoom,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.DeleteRoomRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.Empty>}
+ *     Promise that resolves to the response
+ */
+proto.chat.RoomServicePromiseClient.prototype.deleteRoom =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.RoomService/DeleteRoom',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_DeleteRoom);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.SetVideoPolicyRequest,
+ *   !proto.chat.RoomInfo>}
+ */
+const methodDescriptor_RoomService_SetVideoPolicy = new grpc.web.MethodDescriptor(
+  '/chat.RoomService/SetVideoPolicy',
+  grpc.web.MethodType.UNARY,
+  proto.chat.SetVideoPolicyRequest,
+  proto.chat.RoomInfo,
+  /**
+   * @param {!proto.chat.SetVideoPolicyRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.RoomInfo.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.SetVideoPolicyRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.RoomInfo)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.RoomInfo>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.RoomServiceClient.prototype.setVideoPolicy =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.RoomService/SetVideoPolicy',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_SetVideoPolicy,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.SetVideoPolicyRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.RoomInfo>}
+ *     Promise that resolves to the response
+ */
+proto.chat.RoomServicePromiseClient.prototype.setVideoPolicy =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.RoomService/SetVideoPolicy',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_SetVideoPolicy);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.SetCodecPolicyRequest,
+ *   !proto.chat.RoomInfo>}
+ */
+const methodDescriptor_RoomService_SetCodecPolicy = new grpc.web.MethodDescriptor(
+  '/chat.RoomService/SetCodecPolicy',
+  grpc.web.MethodType.UNARY,
+  proto.chat.SetCodecPolicyRequest,
+  proto.chat.RoomInfo,
+  /**
+   * @param {!proto.chat.SetCodecPolicyRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.RoomInfo.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.SetCodecPolicyRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.RoomInfo)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.RoomInfo>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.RoomServiceClient.prototype.setCodecPolicy =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.RoomService/SetCodecPolicy',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_SetCodecPolicy,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.SetCodecPolicyRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.RoomInfo>}
+ *     Promise that resolves to the response
+ */
+proto.chat.RoomServicePromiseClient.prototype.setCodecPolicy =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.RoomService/SetCodecPolicy',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_SetCodecPolicy);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.CreateBreakoutsRequest,
+ *   !proto.chat.BreakoutsResponse>}
+ */
+const methodDescriptor_RoomService_CreateBreakouts = new grpc.web.MethodDescriptor(
+  '/chat.RoomService/CreateBreakouts',
+  grpc.web.MethodType.UNARY,
+  proto.chat.CreateBreakoutsRequest,
+  proto.chat.BreakoutsResponse,
+  /**
+   * @param {!proto.chat.CreateBreakoutsRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.BreakoutsResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.CreateBreakoutsRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.BreakoutsResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.BreakoutsResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.RoomServiceClient.prototype.createBreakouts =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.RoomService/CreateBreakouts',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_CreateBreakouts,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.CreateBreakoutsRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.BreakoutsResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.RoomServicePromiseClient.prototype.createBreakouts =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.RoomService/CreateBreakouts',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_CreateBreakouts);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.CloseBreakoutsRequest,
+ *   !proto.chat.BreakoutsResponse>}
+ */
+const methodDescriptor_RoomService_CloseBreakouts = new grpc.web.MethodDescriptor(
+  '/chat.RoomService/CloseBreakouts',
+  grpc.web.MethodType.UNARY,
+  proto.chat.CloseBreakoutsRequest,
+  proto.chat.BreakoutsResponse,
+  /**
+   * @param {!proto.chat.CloseBreakoutsRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.BreakoutsResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.CloseBreakoutsRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.BreakoutsResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.BreakoutsResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.RoomServiceClient.prototype.closeBreakouts =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.RoomService/CloseBreakouts',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_CloseBreakouts,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.CloseBreakoutsRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.BreakoutsResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.RoomServicePromiseClient.prototype.closeBreakouts =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.RoomService/CloseBreakouts',
+      request,
+      metadata || {},
+      methodDescriptor_RoomService_CloseBreakouts);
+};
+
+
+/**
+ * @param {string} hostname
+ * @param {?Object} credentials
+ * @param {?grpc.web.ClientOptions} options
+ * @constructor
+ * @struct
+ * @final
+ */
+proto.chat.RecordingServiceClient =
+    function(hostname, credentials, options) {
+  if (!options) options = {};
+  options.format = 'text';
+
+  /**
+   * @private @const {!grpc.web.GrpcWebClientBase} The client
+   */
+  this.client_ = new grpc.web.GrpcWebClientBase(options);
+
+  /**
+   * @private @const {string} The hostname
+   */
+  this.hostname_ = hostname.replace(/\/+$/, '');
+
+};
+
+
+/**
+ * @param {string} hostname
+ * @param {?Object} credentials
+ * @param {?grpc.web.ClientOptions} options
+ * @constructor
+ * @struct
+ * @final
+ */
+proto.chat.RecordingServicePromiseClient =
+    function(hostname, credentials, options) {
+  if (!options) options = {};
+  options.format = 'text';
+
+  /**
+   * @private @const {!grpc.web.GrpcWebClientBase} The client
+   */
+  this.client_ = new grpc.web.GrpcWebClientBase(options);
+
+  /**
+   * @private @const {string} The hostname
+   */
+  this.hostname_ = hostname.replace(/\/+$/, '');
+
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.StartRecordingRequest,
+ *   !proto.chat.RecordingInfo>}
+ */
+const methodDescriptor_RecordingService_StartRecording = new grpc.web.MethodDescriptor(
+  '/chat.RecordingService/StartRecording',
+  grpc.web.MethodType.UNARY,
+  proto.chat.StartRecordingRequest,
+  proto.chat.RecordingInfo,
+  /**
+   * @param {!proto.chat.StartRecordingRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.RecordingInfo.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.StartRecordingRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.RecordingInfo)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.RecordingInfo>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.RecordingServiceClient.prototype.startRecording =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.RecordingService/StartRecording',
+      request,
+      metadata || {},
+      methodDescriptor_RecordingService_StartRecording,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.StartRecordingRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.RecordingInfo>}
+ *     Promise that resolves to the response
+ */
+proto.chat.RecordingServicePromiseClient.prototype.startRecording =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.RecordingService/StartRecording',
+      request,
+      metadata || {},
+      methodDescriptor_RecordingService_StartRecording);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.StopRecordingRequest,
+ *   !proto.chat.RecordingInfo>}
+ */
+const methodDescriptor_RecordingService_StopRecording = new grpc.web.MethodDescriptor(
+  '/chat.RecordingService/StopRecording',
+  grpc.web.MethodType.UNARY,
+  proto.chat.StopRecordingRequest,
+  proto.chat.RecordingInfo,
+  /**
+   * @param {!proto.chat.StopRecordingRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.RecordingInfo.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.StopRecordingRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.RecordingInfo)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.RecordingInfo>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.RecordingServiceClient.prototype.stopRecording =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.RecordingService/StopRecording',
+      request,
+      metadata || {},
+      methodDescriptor_RecordingService_StopRecording,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.StopRecordingRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.RecordingInfo>}
+ *     Promise that resolves to the response
+ */
+proto.chat.RecordingServicePromiseClient.prototype.stopRecording =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.RecordingService/StopRecording',
+      request,
+      metadata || {},
+      methodDescriptor_RecordingService_StopRecording);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.ListRecordingsRequest,
+ *   !proto.chat.ListRecordingsResponse>}
+ */
+const methodDescriptor_RecordingService_ListRecordings = new grpc.web.MethodDescriptor(
+  '/chat.RecordingService/ListRecordings',
+  grpc.web.MethodType.UNARY,
+  proto.chat.ListRecordingsRequest,
+  proto.chat.ListRecordingsResponse,
+  /**
+   * @param {!proto.chat.ListRecordingsRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.ListRecordingsResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.ListRecordingsRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.ListRecordingsResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.ListRecordingsResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.RecordingServiceClient.prototype.listRecordings =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.RecordingService/ListRecordings',
+      request,
+      metadata || {},
+      methodDescriptor_RecordingService_ListRecordings,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.ListRecordingsRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.ListRecordingsResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.RecordingServicePromiseClient.prototype.listRecordings =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.RecordingService/ListRecordings',
+      request,
+      metadata || {},
+      methodDescriptor_RecordingService_ListRecordings);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.DownloadRecordingRequest,
+ *   !proto.chat.RecordingChunk>}
+ */
+const methodDescriptor_RecordingService_DownloadRecording = new grpc.web.MethodDescriptor(
+  '/chat.RecordingService/DownloadRecording',
+  grpc.web.MethodType.UNARY,
+  proto.chat.DownloadRecordingRequest,
+  proto.chat.RecordingChunk,
+  /**
+   * @param {!proto.chat.DownloadRecordingRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.RecordingChunk.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.DownloadRecordingRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.RecordingChunk)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.RecordingChunk>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.RecordingServiceClient.prototype.downloadRecording =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.RecordingService/DownloadRecording',
+      request,
+      metadata || {},
+      methodDescriptor_RecordingService_DownloadRecording,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.DownloadRecordingRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.RecordingChunk>}
+ *     Promise that resolves to the response
+ */
+proto.chat.RecordingServicePromiseClient.prototype.downloadRecording =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.RecordingService/DownloadRecording',
+      request,
+      metadata || {},
+      methodDescriptor_RecordingService_DownloadRecording);
+};
+
+
+/**
+ * @param {string} hostname
+ * @param {?Object} credentials
+ * @param {?grpc.web.ClientOptions} options
+ * @constructor
+ * @struct
+ * @final
+ */
+proto.chat.CallServiceClient =
+    function(hostname, credentials, options) {
+  if (!options) options = {};
+  options.format = 'text';
+
+  /**
+   * @private @const {!grpc.web.GrpcWebClientBase} The client
+   */
+  this.client_ = new grpc.web.GrpcWebClientBase(options);
+
+  /**
+   * @private @const {string} The hostname
+   */
+  this.hostname_ = hostname.replace(/\/+$/, '');
+
+};
+
+
+/**
+ * @param {string} hostname
+ * @param {?Object} credentials
+ * @param {?grpc.web.ClientOptions} options
+ * @constructor
+ * @struct
+ * @final
+ */
+proto.chat.CallServicePromiseClient =
+    function(hostname, credentials, options) {
+  if (!options) options = {};
+  options.format = 'text';
+
+  /**
+   * @private @const {!grpc.web.GrpcWebClientBase} The client
+   */
+  this.client_ = new grpc.web.GrpcWebClientBase(options);
+
+  /**
+   * @private @const {string} The hostname
+   */
+  this.hostname_ = hostname.replace(/\/+$/, '');
+
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.ListCallHistoryRequest,
+ *   !proto.chat.ListCallHistoryResponse>}
+ */
+const methodDescriptor_CallService_ListCallHistory = new grpc.web.MethodDescriptor(
+  '/chat.CallService/ListCallHistory',
+  grpc.web.MethodType.UNARY,
+  proto.chat.ListCallHistoryRequest,
+  proto.chat.ListCallHistoryResponse,
+  /**
+   * @param {!proto.chat.ListCallHistoryRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.ListCallHistoryResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.ListCallHistoryRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.ListCallHistoryResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.ListCallHistoryResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.CallServiceClient.prototype.listCallHistory =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.CallService/ListCallHistory',
+      request,
+      metadata || {},
+      methodDescriptor_CallService_ListCallHistory,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.ListCallHistoryRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.ListCallHistoryResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.CallServicePromiseClient.prototype.listCallHistory =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.CallService/ListCallHistory',
+      request,
+      metadata || {},
+      methodDescriptor_CallService_ListCallHistory);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.ListCallSessionsRequest,
+ *   !proto.chat.ListCallSessionsResponse>}
+ */
+const methodDescriptor_CallService_ListCallSessions = new grpc.web.MethodDescriptor(
+  '/chat.CallService/ListCallSessions',
+  grpc.web.MethodType.UNARY,
+  proto.chat.ListCallSessionsRequest,
+  proto.chat.ListCallSessionsResponse,
+  /**
+   * @param {!proto.chat.ListCallSessionsRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.ListCallSessionsResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.ListCallSessionsRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.ListCallSessionsResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.ListCallSessionsResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.CallServiceClient.prototype.listCallSessions =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.CallService/ListCallSessions',
+      request,
+      metadata || {},
+      methodDescriptor_CallService_ListCallSessions,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.ListCallSessionsRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.ListCallSessionsResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.CallServicePromiseClient.prototype.listCallSessions =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.CallService/ListCallSessions',
+      request,
+      metadata || {},
+      methodDescriptor_CallService_ListCallSessions);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.GetCallQualityRequest,
+ *   !proto.chat.CallQualityResponse>}
+ */
+const methodDescriptor_CallService_GetCallQuality = new grpc.web.MethodDescriptor(
+  '/chat.CallService/GetCallQuality',
+  grpc.web.MethodType.UNARY,
+  proto.chat.GetCallQualityRequest,
+  proto.chat.CallQualityResponse,
+  /**
+   * @param {!proto.chat.GetCallQualityRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.CallQualityResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.GetCallQualityRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.CallQualityResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.CallQualityResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.CallServiceClient.prototype.getCallQuality =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.CallService/GetCallQuality',
+      request,
+      metadata || {},
+      methodDescriptor_CallService_GetCallQuality,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.GetCallQualityRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.CallQualityResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.CallServicePromiseClient.prototype.getCallQuality =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.CallService/GetCallQuality',
+      request,
+      metadata || {},
+      methodDescriptor_CallService_GetCallQuality);
+};
+
+
+/**
+ * @param {string} hostname
+ * @param {?Object} credentials
+ * @param {?grpc.web.ClientOptions} options
+ * @constructor
+ * @struct
+ * @final
+ */
+proto.chat.MessagingServiceClient =
+    function(hostname, credentials, options) {
+  if (!options) options = {};
+  options.format = 'text';
+
+  /**
+   * @private @const {!grpc.web.GrpcWebClientBase} The client
+   */
+  this.client_ = new grpc.web.GrpcWebClientBase(options);
+
+  /**
+   * @private @const {string} The hostname
+   */
+  this.hostname_ = hostname.replace(/\/+$/, '');
+
+};
+
+
+/**
+ * @param {string} hostname
+ * @param {?Object} credentials
+ * @param {?grpc.web.ClientOptions} options
+ * @constructor
+ * @struct
+ * @final
+ */
+proto.chat.MessagingServicePromiseClient =
+    function(hostname, credentials, options) {
+  if (!options) options = {};
+  options.format = 'text';
+
+  /**
+   * @private @const {!grpc.web.GrpcWebClientBase} The client
+   */
+  this.client_ = new grpc.web.GrpcWebClientBase(options);
+
+  /**
+   * @private @const {string} The hostname
+   */
+  this.hostname_ = hostname.replace(/\/+$/, '');
+
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.RegisterDeviceRequest,
+ *   !proto.chat.DeviceInfo>}
+ */
+const methodDescriptor_MessagingService_RegisterDevice = new grpc.web.MethodDescriptor(
+  '/chat.MessagingService/RegisterDevice',
+  grpc.web.MethodType.UNARY,
+  proto.chat.RegisterDeviceRequest,
+  proto.chat.DeviceInfo,
+  /**
+   * @param {!proto.chat.RegisterDeviceRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.DeviceInfo.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.RegisterDeviceRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.DeviceInfo)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.DeviceInfo>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.MessagingServiceClient.prototype.registerDevice =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.MessagingService/RegisterDevice',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_RegisterDevice,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.RegisterDeviceRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.DeviceInfo>}
+ *     Promise that resolves to the response
+ */
+proto.chat.MessagingServicePromiseClient.prototype.registerDevice =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.MessagingService/RegisterDevice',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_RegisterDevice);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.RemoveDeviceRequest,
+ *   !proto.chat.Empty>}
+ */
+const methodDescriptor_MessagingService_RemoveDevice = new grpc.web.MethodDescriptor(
+  '/chat.MessagingService/RemoveDevice',
+  grpc.web.MethodType.UNARY,
+  proto.chat.RemoveDeviceRequest,
+  proto.chat.Empty,
+  /**
+   * @param {!proto.chat.RemoveDeviceRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.Empty.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.RemoveDeviceRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.Empty)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.Empty>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.MessagingServiceClient.prototype.removeDevice =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.MessagingService/RemoveDevice',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_RemoveDevice,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.RemoveDeviceRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.Empty>}
+ *     Promise that resolves to the response
+ */
+proto.chat.MessagingServicePromiseClient.prototype.removeDevice =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.MessagingService/RemoveDevice',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_RemoveDevice);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.ListDevicesRequest,
+ *   !proto.chat.ListDevicesResponse>}
+ */
+const methodDescriptor_MessagingService_ListDevices = new grpc.web.MethodDescriptor(
+  '/chat.MessagingService/ListDevices',
+  grpc.web.MethodType.UNARY,
+  proto.chat.ListDevicesRequest,
+  proto.chat.ListDevicesResponse,
+  /**
+   * @param {!proto.chat.ListDevicesRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.ListDevicesResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.ListDevicesRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.ListDevicesResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.ListDevicesResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.MessagingServiceClient.prototype.listDevices =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.MessagingService/ListDevices',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_ListDevices,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.ListDevicesRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.ListDevicesResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.MessagingServicePromiseClient.prototype.listDevices =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.MessagingService/ListDevices',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_ListDevices);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.UploadPrekeysRequest,
+ *   !proto.chat.PrekeyCountResponse>}
+ */
+const methodDescriptor_MessagingService_UploadPrekeys = new grpc.web.MethodDescriptor(
+  '/chat.MessagingService/UploadPrekeys',
+  grpc.web.MethodType.UNARY,
+  proto.chat.UploadPrekeysRequest,
+  proto.chat.PrekeyCountResponse,
+  /**
+   * @param {!proto.chat.UploadPrekeysRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.PrekeyCountResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.UploadPrekeysRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.PrekeyCountResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.PrekeyCountResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.MessagingServiceClient.prototype.uploadPrekeys =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.MessagingService/UploadPrekeys',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_UploadPrekeys,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.UploadPrekeysRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.PrekeyCountResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.MessagingServicePromiseClient.prototype.uploadPrekeys =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.MessagingService/UploadPrekeys',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_UploadPrekeys);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.FetchPrekeyBundleRequest,
+ *   !proto.chat.PrekeyBundleResponse>}
+ */
+const methodDescriptor_MessagingService_FetchPrekeyBundle = new grpc.web.MethodDescriptor(
+  '/chat.MessagingService/FetchPrekeyBundle',
+  grpc.web.MethodType.UNARY,
+  proto.chat.FetchPrekeyBundleRequest,
+  proto.chat.PrekeyBundleResponse,
+  /**
+   * @param {!proto.chat.FetchPrekeyBundleRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.PrekeyBundleResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.FetchPrekeyBundleRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.PrekeyBundleResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.PrekeyBundleResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.MessagingServiceClient.prototype.fetchPrekeyBundle =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.MessagingService/FetchPrekeyBundle',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_FetchPrekeyBundle,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.FetchPrekeyBundleRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.PrekeyBundleResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.MessagingServicePromiseClient.prototype.fetchPrekeyBundle =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.MessagingService/FetchPrekeyBundle',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_FetchPrekeyBundle);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.SendEncryptedMessageRequest,
+ *   !proto.chat.SendEncryptedMessageResponse>}
+ */
+const methodDescriptor_MessagingService_SendEncryptedMessage = new grpc.web.MethodDescriptor(
+  '/chat.MessagingService/SendEncryptedMessage',
+  grpc.web.MethodType.UNARY,
+  proto.chat.SendEncryptedMessageRequest,
+  proto.chat.SendEncryptedMessageResponse,
+  /**
+   * @param {!proto.chat.SendEncryptedMessageRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.SendEncryptedMessageResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.SendEncryptedMessageRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.SendEncryptedMessageResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.SendEncryptedMessageResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.MessagingServiceClient.prototype.sendEncryptedMessage =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.MessagingService/SendEncryptedMessage',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_SendEncryptedMessage,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.SendEncryptedMessageRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.SendEncryptedMessageResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.MessagingServicePromiseClient.prototype.sendEncryptedMessage =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.MessagingService/SendEncryptedMessage',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_SendEncryptedMessage);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.FetchMessagesRequest,
+ *   !proto.chat.FetchMessagesResponse>}
+ */
+const methodDescriptor_MessagingService_FetchMessages = new grpc.web.MethodDescriptor(
+  '/chat.MessagingService/FetchMessages',
+  grpc.web.MethodType.UNARY,
+  proto.chat.FetchMessagesRequest,
+  proto.chat.FetchMessagesResponse,
+  /**
+   * @param {!proto.chat.FetchMessagesRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.FetchMessagesResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.FetchMessagesRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.FetchMessagesResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.FetchMessagesResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.MessagingServiceClient.prototype.fetchMessages =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.MessagingService/FetchMessages',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_FetchMessages,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.FetchMessagesRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.FetchMessagesResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.MessagingServicePromiseClient.prototype.fetchMessages =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.MessagingService/FetchMessages',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_FetchMessages);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.AckMessagesRequest,
+ *   !proto.chat.Empty>}
+ */
+const methodDescriptor_MessagingService_AckMessages = new grpc.web.MethodDescriptor(
+  '/chat.MessagingService/AckMessages',
+  grpc.web.MethodType.UNARY,
+  proto.chat.AckMessagesRequest,
+  proto.chat.Empty,
+  /**
+   * @param {!proto.chat.AckMessagesRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.Empty.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.AckMessagesRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.Empty)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.Empty>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.MessagingServiceClient.prototype.ackMessages =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.MessagingService/AckMessages',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_AckMessages,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.AckMessagesRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.Empty>}
+ *     Promise that resolves to the response
+ */
+proto.chat.MessagingServicePromiseClient.prototype.ackMessages =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.MessagingService/AckMessages',
+      request,
+      metadata || {},
+      methodDescriptor_MessagingService_AckMessages);
+};
+
+
+/**
+ * @param {string} hostname
+ * @param {?Object} credentials
+ * @param {?grpc.web.ClientOptions} options
+ * @constructor
+ * @struct
+ * @final
+ */
+proto.chat.BotServiceClient =
+    function(hostname, credentials, options) {
+  if (!options) options = {};
+  options.format = 'text';
+
+  /**
+   * @private @const {!grpc.web.GrpcWebClientBase} The client
+   */
+  this.client_ = new grpc.web.GrpcWebClientBase(options);
+
+  /**
+   * @private @const {string} The hostname
+   */
+  this.hostname_ = hostname.replace(/\/+$/, '');
+
+};
+
+
+/**
+ * @param {string} hostname
+ * @param {?Object} credentials
+ * @param {?grpc.web.ClientOptions} options
+ * @constructor
+ * @struct
+ * @final
+ */
+proto.chat.BotServicePromiseClient =
+    function(hostname, credentials, options) {
+  if (!options) options = {};
+  options.format = 'text';
+
+  /**
+   * @private @const {!grpc.web.GrpcWebClientBase} The client
+   */
+  this.client_ = new grpc.web.GrpcWebClientBase(options);
+
+  /**
+   * @private @const {string} The hostname
+   */
+  this.hostname_ = hostname.replace(/\/+$/, '');
+
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.StartBotRequest,
+ *   !proto.chat.BotInfo>}
+ */
+const methodDescriptor_BotService_StartBot = new grpc.web.MethodDescriptor(
+  '/chat.BotService/StartBot',
+  grpc.web.MethodType.UNARY,
+  proto.chat.StartBotRequest,
+  proto.chat.BotInfo,
+  /**
+   * @param {!proto.chat.StartBotRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.BotInfo.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.StartBotRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.BotInfo)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.BotInfo>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.BotServiceClient.prototype.startBot =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.BotService/StartBot',
+      request,
+      metadata || {},
+      methodDescriptor_BotService_StartBot,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.StartBotRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.BotInfo>}
+ *     Promise that resolves to the response
+ */
+proto.chat.BotServicePromiseClient.prototype.startBot =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.BotService/StartBot',
+      request,
+      metadata || {},
+      methodDescriptor_BotService_StartBot);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.StopBotRequest,
+ *   !proto.chat.BotInfo>}
+ */
+const methodDescriptor_BotService_StopBot = new grpc.web.MethodDescriptor(
+  '/chat.BotService/StopBot',
+  grpc.web.MethodType.UNARY,
+  proto.chat.StopBotRequest,
+  proto.chat.BotInfo,
+  /**
+   * @param {!proto.chat.StopBotRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.BotInfo.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.StopBotRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.BotInfo)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.BotInfo>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.BotServiceClient.prototype.stopBot =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.BotService/StopBot',
+      request,
+      metadata || {},
+      methodDescriptor_BotService_StopBot,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.StopBotRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.BotInfo>}
+ *     Promise that resolves to the response
+ */
+proto.chat.BotServicePromiseClient.prototype.stopBot =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.BotService/StopBot',
+      request,
+      metadata || {},
+      methodDescriptor_BotService_StopBot);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.ListBotsRequest,
+ *   !proto.chat.ListBotsResponse>}
+ */
+const methodDescriptor_BotService_ListBots = new grpc.web.MethodDescriptor(
+  '/chat.BotService/ListBots',
+  grpc.web.MethodType.UNARY,
+  proto.chat.ListBotsRequest,
+  proto.chat.ListBotsResponse,
+  /**
+   * @param {!proto.chat.ListBotsRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.ListBotsResponse.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.ListBotsRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.ListBotsResponse)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.ListBotsResponse>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.BotServiceClient.prototype.listBots =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.BotService/ListBots',
+      request,
+      metadata || {},
+      methodDescriptor_BotService_ListBots,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.ListBotsRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.ListBotsResponse>}
+ *     Promise that resolves to the response
+ */
+proto.chat.BotServicePromiseClient.prototype.listBots =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.BotService/ListBots',
+      request,
+      metadata || {},
+      methodDescriptor_BotService_ListBots);
+};
+
+
+/**
+ * @param {string} hostname
+ * @param {?Object} credentials
+ * @param {?grpc.web.ClientOptions} options
+ * @constructor
+ * @struct
+ * @final
+ */
+proto.chat.BroadcastServiceClient =
+    function(hostname, credentials, options) {
+  if (!options) options = {};
+  options.format = 'text';
+
+  /**
+   * @private @const {!grpc.web.GrpcWebClientBase} The client
+   */
+  this.client_ = new grpc.web.GrpcWebClientBase(options);
+
+  /**
+   * @private @const {string} The hostname
+   */
+  this.hostname_ = hostname.replace(/\/+$/, '');
+
+};
+
+
+/**
+ * @param {string} hostname
+ * @param {?Object} credentials
+ * @param {?grpc.web.ClientOptions} options
+ * @constructor
+ * @struct
+ * @final
+ */
+proto.chat.BroadcastServicePromiseClient =
+    function(hostname, credentials, options) {
+  if (!options) options = {};
+  options.format = 'text';
+
+  /**
+   * @private @const {!grpc.web.GrpcWebClientBase} The client
+   */
+  this.client_ = new grpc.web.GrpcWebClientBase(options);
+
+  /**
+   * @private @const {string} The hostname
+   */
+  this.hostname_ = hostname.replace(/\/+$/, '');
+
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.StartBroadcastRequest,
+ *   !proto.chat.BroadcastInfo>}
+ */
+const methodDescriptor_BroadcastService_StartBroadcast = new grpc.web.MethodDescriptor(
+  '/chat.BroadcastService/StartBroadcast',
+  grpc.web.MethodType.UNARY,
+  proto.chat.StartBroadcastRequest,
+  proto.chat.BroadcastInfo,
+  /**
+   * @param {!proto.chat.StartBroadcastRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.BroadcastInfo.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.StartBroadcastRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.BroadcastInfo)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.BroadcastInfo>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.BroadcastServiceClient.prototype.startBroadcast =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.BroadcastService/StartBroadcast',
+      request,
+      metadata || {},
+      methodDescriptor_BroadcastService_StartBroadcast,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.StartBroadcastRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.BroadcastInfo>}
+ *     Promise that resolves to the response
+ */
+proto.chat.BroadcastServicePromiseClient.prototype.startBroadcast =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.BroadcastService/StartBroadcast',
+      request,
+      metadata || {},
+      methodDescriptor_BroadcastService_StartBroadcast);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.StopBroadcastRequest,
+ *   !proto.chat.BroadcastInfo>}
+ */
+const methodDescriptor_BroadcastService_StopBroadcast = new grpc.web.MethodDescriptor(
+  '/chat.BroadcastService/StopBroadcast',
+  grpc.web.MethodType.UNARY,
+  proto.chat.StopBroadcastRequest,
+  proto.chat.BroadcastInfo,
+  /**
+   * @param {!proto.chat.StopBroadcastRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.BroadcastInfo.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.StopBroadcastRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.BroadcastInfo)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.BroadcastInfo>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.BroadcastServiceClient.prototype.stopBroadcast =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.BroadcastService/StopBroadcast',
+      request,
+      metadata || {},
+      methodDescriptor_BroadcastService_StopBroadcast,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.StopBroadcastRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.BroadcastInfo>}
+ *     Promise that resolves to the response
+ */
+proto.chat.BroadcastServicePromiseClient.prototype.stopBroadcast =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.BroadcastService/StopBroadcast',
+      request,
+      metadata || {},
+      methodDescriptor_BroadcastService_StopBroadcast);
+};
+
+
+/**
+ * @const
+ * @type {!grpc.web.MethodDescriptor<
+ *   !proto.chat.GetBroadcastRequest,
+ *   !proto.chat.BroadcastInfo>}
+ */
+const methodDescriptor_BroadcastService_GetBroadcast = new grpc.web.MethodDescriptor(
+  '/chat.BroadcastService/GetBroadcast',
+  grpc.web.MethodType.UNARY,
+  proto.chat.GetBroadcastRequest,
+  proto.chat.BroadcastInfo,
+  /**
+   * @param {!proto.chat.GetBroadcastRequest} request
+   * @return {!Uint8Array}
+   */
+  function(request) {
+    return request.serializeBinary();
+  },
+  proto.chat.BroadcastInfo.deserializeBinary
+);
+
+
+/**
+ * @param {!proto.chat.GetBroadcastRequest} request The
+ *     request proto
+ * @param {?Object<string, string>} metadata User defined
+ *     call metadata
+ * @param {function(?grpc.web.RpcError, ?proto.chat.BroadcastInfo)}
+ *     callback The callback function(error, response)
+ * @return {!grpc.web.ClientReadableStream<!proto.chat.BroadcastInfo>|undefined}
+ *     The XHR Node Readable Stream
+ */
+proto.chat.BroadcastServiceClient.prototype.getBroadcast =
+    function(request, metadata, callback) {
+  return this.client_.rpcCall(this.hostname_ +
+      '/chat.BroadcastService/GetBroadcast',
+      request,
+      metadata || {},
+      methodDescriptor_BroadcastService_GetBroadcast,
+      callback);
+};
+
+
+/**
+ * @param {!proto.chat.GetBroadcastRequest} request The
+ *     request proto
+ * @param {?Object<string, string>=} metadata User defined
+ *     call metadata
+ * @return {!Promise<!proto.chat.BroadcastInfo>}
+ *     Promise that resolves to the response
+ */
+proto.chat.BroadcastServicePromiseClient.prototype.getBroadcast =
+    function(request, metadata) {
+  return this.client_.unaryCall(this.hostname_ +
+      '/chat.BroadcastService/GetBroadcast',
+      request,
+      metadata || {},
+      methodDescriptor_BroadcastService_GetBroadcast);
+};
+
+
 module.exports = proto.chat;
 
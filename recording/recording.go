@@ -0,0 +1,201 @@
+package recording
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Aloys-y/chat-go/blobstore"
+	"github.com/Aloys-y/chat-go/db"
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/Aloys-y/chat-go/sfu"
+	"github.com/Aloys-y/chat-go/signaling"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotSFU            = errors.New("only SFU rooms can be recorded")
+	ErrEncrypted         = errors.New("end-to-end encrypted rooms can't be recorded")
+	ErrRecordingNotFound = errors.New("recording not found")
+	ErrFileNotFound      = errors.New("recording file not found")
+)
+
+// Start begins recording the live SFU room. Each participant's audio and
+// the room mix are written to the blob store as Ogg/Opus files.
+func Start(room *models.Room, startedBy uint) (*models.Recording, error) {
+	if room.MediaMode != models.MediaModeSFU {
+		return nil, ErrNotSFU
+	}
+	if room.E2EERequired || signaling.RoomEncrypted(room.ID) {
+		return nil, ErrEncrypted
+	}
+
+	rec := &models.Recording{
+		RoomID:    room.ID,
+		StartedBy: startedBy,
+		Status:    models.RecordingActive,
+		StartedAt: time.Now(),
+	}
+	if err := db.DB.Create(rec).Error; err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	s := &sink{recordingID: rec.ID}
+	if err := sfu.StartRecording(room.ID, rec.ID, s); err != nil {
+		db.DB.Model(rec).Update("status", models.RecordingFailed)
+		return nil, err
+	}
+	// A participant who encrypts may have joined meanwhile, before joinRoom
+	// could see the recording to stop it. Whoever stops it, the recording
+	// is discarded rather than completed.
+	if signaling.RoomEncrypted(room.ID) {
+		s.refuse()
+		sfu.StopRecording(room.ID)
+		return nil, ErrEncrypted
+	}
+	return rec, nil
+}
+
+// Stop ends the room's recording and returns it with its files
+func Stop(roomID uint) (*models.Recording, error) {
+	result, err := sfu.StopRecording(roomID)
+	if err != nil {
+		return nil, err
+	}
+	return Get(result.ID)
+}
+
+// Get loads a recording with its files
+func Get(recordingID uint) (*models.Recording, error) {
+	var rec models.Recording
+	if err := db.DB.Preload("Files").First(&rec, recordingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordingNotFound
+		}
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// List returns the recordings of a room, newest first
+func List(roomID uint) ([]models.Recording, error) {
+	var recs []models.Recording
+	err := db.DB.Preload("Files").
+		Where("room_id = ?", roomID).
+		Order("started_at DESC").
+		Find(&recs).Error
+	return recs, err
+}
+
+// OpenFile loads a recording file and opens its blob
+func OpenFile(fileID uint) (*models.RecordingFile, io.ReadSeekCloser, error) {
+	var file models.RecordingFile
+	if err := db.DB.First(&file, fileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrFileNotFound
+		}
+		return nil, nil, err
+	}
+
+	blob, err := blobstore.Default.Open(file.BlobKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &file, blob, nil
+}
+
+// blobKey is where a file of a recording is stored
+func blobKey(recordingID uint, name string) string {
+	return fmt.Sprintf("recordings/%d/%s", recordingID, name)
+}
+
+// sink stores the files of a recording in the blob store and records them
+// in the database when the recording ends
+type sink struct {
+	recordingID uint
+
+	// mux orders Finish and refuse, which may run on different goroutines
+	mux      sync.Mutex
+	refused  bool
+	finished bool
+	result   sfu.RecordingResult
+}
+
+func (s *sink) Create(name string) (io.WriteCloser, error) {
+	return blobstore.Default.Create(blobKey(s.recordingID, name))
+}
+
+func (s *sink) Finish(result sfu.RecordingResult) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.finished, s.result = true, result
+	if s.refused {
+		s.discardLocked()
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, f := range result.Files {
+			file := &models.RecordingFile{
+				RecordingID: s.recordingID,
+				UserID:      f.UserID,
+				BlobKey:     blobKey(s.recordingID, f.Name),
+				Size:        f.Size,
+			}
+			if err := tx.Create(file).Error; err != nil {
+				return err
+			}
+		}
+
+		stoppedAt := time.Now()
+		return tx.Model(&models.Recording{}).Where("id = ?", s.recordingID).Updates(map[string]interface{}{
+			"status":      models.RecordingCompleted,
+			"stopped_at":  &stoppedAt,
+			"duration_ms": result.Duration.Milliseconds(),
+		}).Error
+	})
+	if err != nil {
+		log.Printf("Failed to save recording %d: %v", s.recordingID, err)
+	}
+}
+
+// refuse marks a recording that must not be kept, discarding it if it
+// already finished
+func (s *sink) refuse() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.refused = true
+	if s.finished {
+		s.discardLocked()
+	}
+}
+
+// discardLocked deletes the files of a refused recording and marks it failed.
+// The caller must hold s.mux.
+func (s *sink) discardLocked() {
+	for _, f := range s.result.Files {
+		if err := blobstore.Default.Delete(blobKey(s.recordingID, f.Name)); err != nil {
+			log.Printf("Failed to delete file %s of recording %d: %v", f.Name, s.recordingID, err)
+		}
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("recording_id = ?", s.recordingID).Delete(&models.RecordingFile{}).Error; err != nil {
+			return err
+		}
+		stoppedAt := time.Now()
+		return tx.Model(&models.Recording{}).Where("id = ?", s.recordingID).Updates(map[string]interface{}{
+			"status":     models.RecordingFailed,
+			"stopped_at": &stoppedAt,
+		}).Error
+	})
+	if err != nil {
+		log.Printf("Failed to discard recording %d: %v", s.recordingID, err)
+	}
+}
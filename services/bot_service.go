@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Aloys-y/chat-go/auth"
+	"github.com/Aloys-y/chat-go/bots"
+	"github.com/Aloys-y/chat-go/proto"
+	"github.com/Aloys-y/chat-go/signaling"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type BotServiceImpl struct {
+	proto.UnimplementedBotServiceServer
+}
+
+// StartBot implements BotServiceServer
+func (s *BotServiceImpl) StartBot(ctx context.Context, req *proto.StartBotRequest) (*proto.BotInfo, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if req.DurationSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "duration_seconds must not be negative")
+	}
+
+	info, err := bots.Start(bots.Options{
+		RoomID:      uint(req.RoomId),
+		Name:        req.Name,
+		DisplayName: req.DisplayName,
+		File:        req.File,
+		ToneHz:      int(req.ToneHz),
+		Loop:        req.Loop,
+		Duration:    time.Duration(req.DurationSeconds) * time.Second,
+	})
+	if err != nil {
+		return nil, botStatus(err)
+	}
+	return botInfo(info), nil
+}
+
+// StopBot implements BotServiceServer
+func (s *BotServiceImpl) StopBot(ctx context.Context, req *proto.StopBotRequest) (*proto.BotInfo, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	info, err := bots.Stop(uint(req.UserId))
+	if err != nil {
+		return nil, botStatus(err)
+	}
+	return botInfo(info), nil
+}
+
+// ListBots implements BotServiceServer
+func (s *BotServiceImpl) ListBots(ctx context.Context, req *proto.ListBotsRequest) (*proto.ListBotsResponse, error) {
+	if err := checkAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	resp := &proto.ListBotsResponse{}
+	for _, info := range bots.List(uint(req.RoomId)) {
+		resp.Bots = append(resp.Bots, botInfo(info))
+	}
+	return resp, nil
+}
+
+// checkAdmin makes sure the caller is an admin
+func checkAdmin(ctx context.Context) error {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return err
+	}
+	if !auth.IsAdmin(caller) {
+		return status.Error(codes.PermissionDenied, "only admins can do this")
+	}
+	return nil
+}
+
+// botInfo converts a bot snapshot to its protobuf form
+func botInfo(info bots.Info) *proto.BotInfo {
+	return &proto.BotInfo{
+		UserId:          uint64(info.UserID),
+		Name:            info.Name,
+		RoomId:          uint64(info.RoomID),
+		Source:          info.Source,
+		Loop:            info.Loop,
+		StartedAt:       info.StartedAt.Unix(),
+		Muted:           info.Muted,
+		Peers:           int32(info.Peers),
+		PacketsSent:     info.PacketsSent,
+		PacketsReceived: info.PacketsReceived,
+	}
+}
+
+// botStatus maps bot errors to gRPC status errors
+func botStatus(err error) error {
+	var joinErr *bots.JoinError
+	switch {
+	case errors.Is(err, bots.ErrBotNotFound), errors.Is(err, bots.ErrFileNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, bots.ErrBotRunning), errors.Is(err, bots.ErrNameTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, bots.ErrInvalidName), errors.Is(err, bots.ErrNoSource), errors.Is(err, bots.ErrInvalidTone):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, bots.ErrTooManyBots):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, bots.ErrEncrypted):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.As(err, &joinErr):
+		switch joinErr.Code {
+		case signaling.ErrCodeForbidden, signaling.ErrCodeBanned:
+			return status.Error(codes.PermissionDenied, err.Error())
+		case signaling.ErrCodeRoomNotFound:
+			return status.Error(codes.NotFound, err.Error())
+		default:
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+	default:
+		return membershipStatus(err)
+	}
+}
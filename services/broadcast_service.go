@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Aloys-y/chat-go/broadcast"
+	"github.com/Aloys-y/chat-go/proto"
+	"github.com/Aloys-y/chat-go/sfu"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type BroadcastServiceImpl struct {
+	proto.UnimplementedBroadcastServiceServer
+}
+
+// StartBroadcast implements BroadcastServiceServer. Only the room owner may
+// broadcast; the room must be a live SFU room.
+func (s *BroadcastServiceImpl) StartBroadcast(ctx context.Context, req *proto.StartBroadcastRequest) (*proto.BroadcastInfo, error) {
+	room, err := ownedRoom(ctx, uint(req.RoomId))
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := broadcast.Start(room, room.OwnerID)
+	if err != nil {
+		return nil, broadcastStatus(err)
+	}
+	return broadcastInfo(info), nil
+}
+
+// StopBroadcast implements BroadcastServiceServer. Only the room owner may
+// stop a broadcast.
+func (s *BroadcastServiceImpl) StopBroadcast(ctx context.Context, req *proto.StopBroadcastRequest) (*proto.BroadcastInfo, error) {
+	room, err := ownedRoom(ctx, uint(req.RoomId))
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := broadcast.Stop(room.ID)
+	if err != nil {
+		return nil, broadcastStatus(err)
+	}
+	return broadcastInfo(info), nil
+}
+
+// GetBroadcast implements BroadcastServiceServer. The room owner signs new
+// playlist URLs to hand out to listeners.
+func (s *BroadcastServiceImpl) GetBroadcast(ctx context.Context, req *proto.GetBroadcastRequest) (*proto.BroadcastInfo, error) {
+	room, err := ownedRoom(ctx, uint(req.RoomId))
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := broadcast.Get(room.ID, time.Duration(req.TtlSeconds)*time.Second)
+	if err != nil {
+		return nil, broadcastStatus(err)
+	}
+	return broadcastInfo(info), nil
+}
+
+// broadcastInfo converts a broadcast to its protobuf form
+func broadcastInfo(info broadcast.Info) *proto.BroadcastInfo {
+	pb := &proto.BroadcastInfo{
+		RoomId:      uint64(info.RoomID),
+		StartedBy:   uint64(info.StartedBy),
+		PlaylistUrl: info.PlaylistURL,
+	}
+	if !info.StartedAt.IsZero() {
+		pb.StartedAt = info.StartedAt.Unix()
+	}
+	if !info.ExpiresAt.IsZero() {
+		pb.ExpiresAt = info.ExpiresAt.Unix()
+	}
+	return pb
+}
+
+// broadcastStatus maps broadcast errors to gRPC status errors
+func broadcastStatus(err error) error {
+	switch {
+	case errors.Is(err, sfu.ErrAlreadyBroadcasting):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, broadcast.ErrInvalidTTL):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, broadcast.ErrNotSFU), errors.Is(err, broadcast.ErrEncrypted), errors.Is(err, sfu.ErrNoRoom), errors.Is(err, sfu.ErrNotBroadcasting):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return err
+	}
+}
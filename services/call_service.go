@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Aloys-y/chat-go/calls"
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/Aloys-y/chat-go/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultPageSize = 20
+
+type CallServiceImpl struct {
+	proto.UnimplementedCallServiceServer
+}
+
+// ListCallHistory implements CallServiceServer. Users only see their own calls.
+func (s *CallServiceImpl) ListCallHistory(ctx context.Context, req *proto.ListCallHistoryRequest) (*proto.ListCallHistoryResponse, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page, pageSize := req.Page, req.PageSize
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	logs, total, err := calls.History(caller, req.Filter, int(page), int(pageSize))
+	if err != nil {
+		if errors.Is(err, calls.ErrUnknownFilter) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown filter %q", req.Filter)
+		}
+		return nil, fmt.Errorf("failed to list calls: %w", err)
+	}
+
+	infos := make([]*proto.CallInfo, 0, len(logs))
+	for i := range logs {
+		infos = append(infos, callInfo(&logs[i]))
+	}
+	return &proto.ListCallHistoryResponse{
+		Calls:      infos,
+		TotalCount: int32(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
+
+// ListCallSessions implements CallServiceServer
+func (s *CallServiceImpl) ListCallSessions(ctx context.Context, req *proto.ListCallSessionsRequest) (*proto.ListCallSessionsResponse, error) {
+	if err := checkRoomMember(ctx, uint(req.RoomId)); err != nil {
+		return nil, err
+	}
+
+	page, pageSize := req.Page, req.PageSize
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	sessions, total, err := calls.Sessions(uint(req.RoomId), int(page), int(pageSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list call sessions: %w", err)
+	}
+
+	infos := make([]*proto.CallSessionInfo, 0, len(sessions))
+	for i := range sessions {
+		infos = append(infos, callSessionInfo(&sessions[i]))
+	}
+	return &proto.ListCallSessionsResponse{
+		Sessions:   infos,
+		TotalCount: int32(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}, nil
+}
+
+// GetCallQuality implements CallServiceServer
+func (s *CallServiceImpl) GetCallQuality(ctx context.Context, req *proto.GetCallQualityRequest) (*proto.CallQualityResponse, error) {
+	session, qualities, err := calls.Quality(uint(req.SessionId))
+	if err != nil {
+		if errors.Is(err, calls.ErrSessionNotFound) {
+			return nil, status.Errorf(codes.NotFound, "call session %d not found", req.SessionId)
+		}
+		return nil, fmt.Errorf("failed to get call quality: %w", err)
+	}
+	if err := checkRoomMember(ctx, session.RoomID); err != nil {
+		return nil, err
+	}
+
+	resp := &proto.CallQualityResponse{
+		SessionId:    uint64(session.ID),
+		RoomId:       uint64(session.RoomID),
+		Participants: make([]*proto.ParticipantQuality, 0, len(qualities)),
+	}
+	var overall models.CallQuality
+	for i := range qualities {
+		q := &qualities[i]
+		resp.Participants = append(resp.Participants, &proto.ParticipantQuality{
+			UserId: uint64(q.UserID),
+			Stats:  qualityStats(q),
+		})
+
+		overall.Reports += q.Reports
+		overall.RTTSumMs += q.RTTSumMs
+		overall.RTTMaxMs = math.Max(overall.RTTMaxMs, q.RTTMaxMs)
+		overall.JitterSumMs += q.JitterSumMs
+		overall.JitterMaxMs = math.Max(overall.JitterMaxMs, q.JitterMaxMs)
+		overall.PacketLossSum += q.PacketLossSum
+		overall.PacketLossMax = math.Max(overall.PacketLossMax, q.PacketLossMax)
+		overall.InboundKbpsSum += q.InboundKbpsSum
+		overall.OutboundKbpsSum += q.OutboundKbpsSum
+	}
+	resp.Overall = qualityStats(&overall)
+	return resp, nil
+}
+
+// callInfo converts a call log entry for responses
+func callInfo(call *models.CallLog) *proto.CallInfo {
+	info := &proto.CallInfo{
+		Id:              uint64(call.ID),
+		CallerId:        uint64(call.CallerID),
+		CalleeId:        uint64(call.CalleeID),
+		Video:           call.Video,
+		Status:          call.Status,
+		StartedAt:       call.StartedAt.Unix(),
+		DurationSeconds: int64(call.Duration().Seconds()),
+	}
+	if call.AnsweredAt != nil {
+		info.AnsweredAt = call.AnsweredAt.Unix()
+	}
+	if call.EndedAt != nil {
+		info.EndedAt = call.EndedAt.Unix()
+	}
+	return info
+}
+
+// callSessionInfo converts a call session and its participants for responses
+func callSessionInfo(session *models.CallSession) *proto.CallSessionInfo {
+	info := &proto.CallSessionInfo{
+		Id:               uint64(session.ID),
+		RoomId:           uint64(session.RoomID),
+		StartedAt:        session.StartedAt.Unix(),
+		DurationSeconds:  int64(session.Duration().Seconds()),
+		PeakParticipants: int32(session.PeakParticipants),
+		Participants:     make([]*proto.CallParticipantInfo, 0, len(session.Participants)),
+	}
+	if session.EndedAt != nil {
+		info.EndedAt = session.EndedAt.Unix()
+	}
+
+	users := make(map[uint]bool)
+	for i := range session.Participants {
+		p := &session.Participants[i]
+		users[p.UserID] = true
+
+		participant := &proto.CallParticipantInfo{
+			UserId:          uint64(p.UserID),
+			JoinedAt:        p.JoinedAt.Unix(),
+			DurationSeconds: int64(p.Duration().Seconds()),
+		}
+		if p.LeftAt != nil {
+			participant.LeftAt = p.LeftAt.Unix()
+		}
+		info.Participants = append(info.Participants, participant)
+	}
+	info.UniqueParticipants = int32(len(users))
+	return info
+}
+
+// qualityStats turns the sums of a quality aggregate into averages
+func qualityStats(q *models.CallQuality) *proto.QualityStats {
+	stats := &proto.QualityStats{
+		Reports:       int32(q.Reports),
+		MaxRttMs:      q.RTTMaxMs,
+		MaxJitterMs:   q.JitterMaxMs,
+		MaxPacketLoss: q.PacketLossMax,
+	}
+	if q.Reports > 0 {
+		n := float64(q.Reports)
+		stats.AvgRttMs = q.RTTSumMs / n
+		stats.AvgJitterMs = q.JitterSumMs / n
+		stats.AvgPacketLoss = q.PacketLossSum / n
+		stats.AvgInboundKbps = q.InboundKbpsSum / n
+		stats.AvgOutboundKbps = q.OutboundKbpsSum / n
+	}
+	return stats
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Aloys-y/chat-go/auth"
+	"github.com/Aloys-y/chat-go/messaging"
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/Aloys-y/chat-go/proto"
+	"github.com/Aloys-y/chat-go/signaling"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxDeviceNameLength = 64
+	// maxKeyLength bounds public keys and signatures
+	maxKeyLength = 255
+	// maxPrekeyUpload bounds the one-time prekeys of a single request
+	maxPrekeyUpload = 100
+	// maxCiphertextLength bounds a message encrypted for one device
+	maxCiphertextLength = 64 * 1024
+)
+
+type MessagingServiceImpl struct {
+	proto.UnimplementedMessagingServiceServer
+}
+
+// RegisterDevice implements MessagingServiceServer. The caller's contacts
+// learn about the new device.
+func (s *MessagingServiceImpl) RegisterDevice(ctx context.Context, req *proto.RegisterDeviceRequest) (*proto.DeviceInfo, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Name) > maxDeviceNameLength {
+		return nil, status.Errorf(codes.InvalidArgument, "name must be at most %d characters", maxDeviceNameLength)
+	}
+	if err := checkKey("identity_key", req.IdentityKey); err != nil {
+		return nil, err
+	}
+	if req.SignedPrekey == nil {
+		return nil, status.Error(codes.InvalidArgument, "signed_prekey is required")
+	}
+	if err := checkSignedPrekey(req.SignedPrekey); err != nil {
+		return nil, err
+	}
+	prekeys, err := oneTimePrekeys(req.OneTimePrekeys)
+	if err != nil {
+		return nil, err
+	}
+
+	device, err := messaging.RegisterDevice(caller, req.Name, req.IdentityKey, signedPrekey(req.SignedPrekey), prekeys)
+	if err != nil {
+		return nil, messagingStatus(err)
+	}
+	notifyDevicesChanged(caller)
+
+	count, err := messaging.PrekeyCount(device.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count prekeys: %w", err)
+	}
+	info := deviceInfo(device)
+	info.OneTimePrekeys = int32(count)
+	return info, nil
+}
+
+// RemoveDevice implements MessagingServiceServer. Users can only remove
+// their own devices; messages still waiting for the device are dropped.
+func (s *MessagingServiceImpl) RemoveDevice(ctx context.Context, req *proto.RemoveDeviceRequest) (*proto.Empty, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := messaging.RemoveDevice(caller, uint(req.DeviceId)); err != nil {
+		return nil, messagingStatus(err)
+	}
+	notifyDevicesChanged(caller)
+	return &proto.Empty{}, nil
+}
+
+// ListDevices implements MessagingServiceServer
+func (s *MessagingServiceImpl) ListDevices(ctx context.Context, req *proto.ListDevicesRequest) (*proto.ListDevicesResponse, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := messaging.Devices(uint(req.UserId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	resp := &proto.ListDevicesResponse{Devices: make([]*proto.DeviceInfo, 0, len(devices))}
+	for i := range devices {
+		info := deviceInfo(&devices[i])
+		if devices[i].UserID == caller {
+			count, err := messaging.PrekeyCount(devices[i].ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to count prekeys: %w", err)
+			}
+			info.OneTimePrekeys = int32(count)
+		}
+		resp.Devices = append(resp.Devices, info)
+	}
+	return resp, nil
+}
+
+// UploadPrekeys implements MessagingServiceServer
+func (s *MessagingServiceImpl) UploadPrekeys(ctx context.Context, req *proto.UploadPrekeysRequest) (*proto.PrekeyCountResponse, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var signed *messaging.SignedPrekey
+	if req.SignedPrekey != nil {
+		if err := checkSignedPrekey(req.SignedPrekey); err != nil {
+			return nil, err
+		}
+		sp := signedPrekey(req.SignedPrekey)
+		signed = &sp
+	}
+	prekeys, err := oneTimePrekeys(req.OneTimePrekeys)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := messaging.UploadPrekeys(caller, uint(req.DeviceId), signed, prekeys)
+	if err != nil {
+		return nil, messagingStatus(err)
+	}
+	return &proto.PrekeyCountResponse{OneTimePrekeys: int32(count)}, nil
+}
+
+// FetchPrekeyBundle implements MessagingServiceServer. Every bundle uses up
+// one of the device's one-time prekeys; owners running low are told to
+// upload more.
+func (s *MessagingServiceImpl) FetchPrekeyBundle(ctx context.Context, req *proto.FetchPrekeyBundleRequest) (*proto.PrekeyBundleResponse, error) {
+	if _, err := callerID(ctx); err != nil {
+		return nil, err
+	}
+
+	bundles, err := messaging.Bundles(uint(req.UserId), uint(req.DeviceId))
+	if err != nil {
+		return nil, messagingStatus(err)
+	}
+
+	resp := &proto.PrekeyBundleResponse{Bundles: make([]*proto.PrekeyBundle, 0, len(bundles))}
+	for _, b := range bundles {
+		bundle := &proto.PrekeyBundle{
+			UserId:      uint64(b.Device.UserID),
+			DeviceId:    uint64(b.Device.ID),
+			IdentityKey: b.Device.IdentityKey,
+			SignedPrekey: &proto.SignedPrekey{
+				KeyId:     b.Device.SignedPrekeyID,
+				PublicKey: b.Device.SignedPrekey,
+				Signature: b.Device.SignedPrekeySignature,
+			},
+		}
+		if b.Prekey != nil {
+			bundle.OneTimePrekey = &proto.OneTimePrekey{
+				KeyId:     b.Prekey.KeyID,
+				PublicKey: b.Prekey.PublicKey,
+			}
+		}
+		if b.Remaining < messaging.LowPrekeys {
+			signaling.NotifyPrekeysLow(b.Device.UserID, b.Device.ID, b.Remaining)
+		}
+		resp.Bundles = append(resp.Bundles, bundle)
+	}
+	return resp, nil
+}
+
+// SendEncryptedMessage implements MessagingServiceServer. The message must
+// be encrypted for exactly the recipient's current devices, otherwise it is
+// refused with FailedPrecondition and the sender should refresh the list.
+func (s *MessagingServiceImpl) SendEncryptedMessage(ctx context.Context, req *proto.SendEncryptedMessageRequest) (*proto.SendEncryptedMessageResponse, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := auth.GetUserByID(uint(req.RecipientId)); err != nil {
+		return nil, status.Errorf(codes.NotFound, "user %d not found", req.RecipientId)
+	}
+	if len(req.Messages) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "messages are required")
+	}
+
+	messages := make([]models.EncryptedMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		if len(m.Ciphertext) == 0 || len(m.Ciphertext) > maxCiphertextLength {
+			return nil, status.Errorf(codes.InvalidArgument, "ciphertext must be 1 to %d bytes", maxCiphertextLength)
+		}
+		messages = append(messages, models.EncryptedMessage{
+			RecipientDeviceID: uint(m.DeviceId),
+			Type:              m.Type,
+			Ciphertext:        m.Ciphertext,
+		})
+	}
+
+	stored, err := messaging.Send(caller, uint(req.SenderDeviceId), uint(req.RecipientId), messages)
+	if err != nil {
+		return nil, messagingStatus(err)
+	}
+
+	resp := &proto.SendEncryptedMessageResponse{MessageIds: make([]uint64, 0, len(stored))}
+	deviceIDs := make([]uint, 0, len(stored))
+	for _, m := range stored {
+		resp.MessageIds = append(resp.MessageIds, uint64(m.ID))
+		resp.SentAt = m.CreatedAt.Unix()
+		deviceIDs = append(deviceIDs, m.RecipientDeviceID)
+	}
+	signaling.NotifyEncryptedMessage(uint(req.RecipientId), caller, uint(req.SenderDeviceId), deviceIDs)
+	return resp, nil
+}
+
+// FetchMessages implements MessagingServiceServer. Messages stay stored
+// until they are acknowledged.
+func (s *MessagingServiceImpl) FetchMessages(ctx context.Context, req *proto.FetchMessagesRequest) (*proto.FetchMessagesResponse, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := messaging.OwnDevice(caller, uint(req.DeviceId)); err != nil {
+		return nil, messagingStatus(err)
+	}
+
+	messages, more, err := messaging.Pending(uint(req.DeviceId), int(req.Limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch messages: %w", err)
+	}
+
+	resp := &proto.FetchMessagesResponse{
+		Messages: make([]*proto.EncryptedMessage, 0, len(messages)),
+		More:     more,
+	}
+	for _, m := range messages {
+		resp.Messages = append(resp.Messages, &proto.EncryptedMessage{
+			Id:             uint64(m.ID),
+			SenderId:       uint64(m.SenderID),
+			SenderDeviceId: uint64(m.SenderDeviceID),
+			Type:           m.Type,
+			Ciphertext:     m.Ciphertext,
+			SentAt:         m.CreatedAt.Unix(),
+		})
+	}
+	return resp, nil
+}
+
+// AckMessages implements MessagingServiceServer. Acknowledged messages are
+// deleted from the server.
+func (s *MessagingServiceImpl) AckMessages(ctx context.Context, req *proto.AckMessagesRequest) (*proto.Empty, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := messaging.OwnDevice(caller, uint(req.DeviceId)); err != nil {
+		return nil, messagingStatus(err)
+	}
+
+	ids := make([]uint, 0, len(req.MessageIds))
+	for _, id := range req.MessageIds {
+		ids = append(ids, uint(id))
+	}
+	if err := messaging.Ack(uint(req.DeviceId), ids); err != nil {
+		return nil, fmt.Errorf("failed to acknowledge messages: %w", err)
+	}
+	return &proto.Empty{}, nil
+}
+
+// notifyDevicesChanged tells the user's sessions and contacts about the
+// user's current devices
+func notifyDevicesChanged(userID uint) {
+	deviceIDs, err := messaging.DeviceIDs(userID)
+	if err != nil {
+		log.Printf("Failed to list devices of user %d: %v", userID, err)
+		return
+	}
+	contacts, err := messaging.Contacts(userID)
+	if err != nil {
+		log.Printf("Failed to list contacts of user %d: %v", userID, err)
+	}
+	signaling.NotifyDevicesChanged(userID, deviceIDs, contacts)
+}
+
+// checkKey rejects empty and oversized keys
+func checkKey(field string, key []byte) error {
+	if len(key) == 0 || len(key) > maxKeyLength {
+		return status.Errorf(codes.InvalidArgument, "%s must be 1 to %d bytes", field, maxKeyLength)
+	}
+	return nil
+}
+
+// checkSignedPrekey checks the sizes of a signed prekey
+func checkSignedPrekey(p *proto.SignedPrekey) error {
+	if err := checkKey("signed_prekey.public_key", p.PublicKey); err != nil {
+		return err
+	}
+	return checkKey("signed_prekey.signature", p.Signature)
+}
+
+// signedPrekey converts a checked signed prekey
+func signedPrekey(p *proto.SignedPrekey) messaging.SignedPrekey {
+	return messaging.SignedPrekey{
+		KeyID:     p.KeyId,
+		PublicKey: p.PublicKey,
+		Signature: p.Signature,
+	}
+}
+
+// oneTimePrekeys checks and converts uploaded one-time prekeys
+func oneTimePrekeys(prekeys []*proto.OneTimePrekey) ([]models.OneTimePrekey, error) {
+	if len(prekeys) > maxPrekeyUpload {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d one-time prekeys can be uploaded at once", maxPrekeyUpload)
+	}
+	keys := make([]models.OneTimePrekey, 0, len(prekeys))
+	for _, p := range prekeys {
+		if err := checkKey("one_time_prekeys.public_key", p.PublicKey); err != nil {
+			return nil, err
+		}
+		keys = append(keys, models.OneTimePrekey{KeyID: p.KeyId, PublicKey: p.PublicKey})
+	}
+	return keys, nil
+}
+
+// deviceInfo converts a device for responses
+func deviceInfo(d *models.Device) *proto.DeviceInfo {
+	return &proto.DeviceInfo{
+		Id:          uint64(d.ID),
+		UserId:      uint64(d.UserID),
+		Name:        d.Name,
+		IdentityKey: d.IdentityKey,
+		CreatedAt:   d.CreatedAt.Unix(),
+	}
+}
+
+// messagingStatus maps messaging errors to gRPC status errors
+func messagingStatus(err error) error {
+	var mismatch *messaging.DeviceMismatchError
+	switch {
+	case errors.As(err, &mismatch), errors.Is(err, messaging.ErrNoDevices):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, messaging.ErrDeviceNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, messaging.ErrTooManyDevices), errors.Is(err, messaging.ErrTooManyPrekeys):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, messaging.ErrDuplicateDevice):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Aloys-y/chat-go/membership"
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/Aloys-y/chat-go/proto"
+	"github.com/Aloys-y/chat-go/recording"
+	"github.com/Aloys-y/chat-go/sfu"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultChunkSize = 256 * 1024
+	maxChunkSize     = 1024 * 1024
+)
+
+type RecordingServiceImpl struct {
+	proto.UnimplementedRecordingServiceServer
+}
+
+// StartRecording implements RecordingServiceServer. Only the room owner may
+// record; the room must be a live SFU room.
+func (s *RecordingServiceImpl) StartRecording(ctx context.Context, req *proto.StartRecordingRequest) (*proto.RecordingInfo, error) {
+	room, err := ownedRoom(ctx, uint(req.RoomId))
+	if err != nil {
+		return nil, err
+	}
+
+	rec, err := recording.Start(room, room.OwnerID)
+	if err != nil {
+		return nil, recordingStatus(err)
+	}
+	return recordingInfo(rec), nil
+}
+
+// StopRecording implements RecordingServiceServer. Only the room owner may
+// stop a recording.
+func (s *RecordingServiceImpl) StopRecording(ctx context.Context, req *proto.StopRecordingRequest) (*proto.RecordingInfo, error) {
+	room, err := ownedRoom(ctx, uint(req.RoomId))
+	if err != nil {
+		return nil, err
+	}
+
+	rec, err := recording.Stop(room.ID)
+	if err != nil {
+		return nil, recordingStatus(err)
+	}
+	return recordingInfo(rec), nil
+}
+
+// ListRecordings implements RecordingServiceServer. Recordings are visible
+// to the members of the room.
+func (s *RecordingServiceImpl) ListRecordings(ctx context.Context, req *proto.ListRecordingsRequest) (*proto.ListRecordingsResponse, error) {
+	if err := checkRoomMember(ctx, uint(req.RoomId)); err != nil {
+		return nil, err
+	}
+
+	recs, err := recording.List(uint(req.RoomId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recordings: %w", err)
+	}
+
+	infos := make([]*proto.RecordingInfo, 0, len(recs))
+	for i := range recs {
+		infos = append(infos, recordingInfo(&recs[i]))
+	}
+	return &proto.ListRecordingsResponse{Recordings: infos}, nil
+}
+
+// DownloadRecording implements RecordingServiceServer. Files are read in
+// chunks of at most limit bytes starting at offset.
+func (s *RecordingServiceImpl) DownloadRecording(ctx context.Context, req *proto.DownloadRecordingRequest) (*proto.RecordingChunk, error) {
+	file, blob, err := recording.OpenFile(uint(req.FileId))
+	if err != nil {
+		return nil, recordingStatus(err)
+	}
+	defer blob.Close()
+
+	rec, err := recording.Get(file.RecordingID)
+	if err != nil {
+		return nil, recordingStatus(err)
+	}
+	if err := checkRoomMember(ctx, rec.RoomID); err != nil {
+		return nil, err
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultChunkSize
+	}
+	if limit > maxChunkSize {
+		limit = maxChunkSize
+	}
+
+	size, err := blob.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if req.Offset < 0 || req.Offset > size {
+		return nil, status.Errorf(codes.OutOfRange, "offset %d is outside the file (%d bytes)", req.Offset, size)
+	}
+	if _, err := blob.Seek(req.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, limit)
+	n, err := io.ReadFull(blob, data)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	return &proto.RecordingChunk{
+		Data:      data[:n],
+		Offset:    req.Offset,
+		TotalSize: size,
+	}, nil
+}
+
+// checkRoomMember makes sure the caller owns or is a member of the room
+func checkRoomMember(ctx context.Context, roomID uint) error {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return err
+	}
+
+	room, err := membership.GetRoom(roomID)
+	if err != nil {
+		return membershipStatus(err)
+	}
+	if room.OwnerID == caller {
+		return nil
+	}
+
+	member, err := membership.IsMember(roomID, caller)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return status.Errorf(codes.PermissionDenied, "not a member of room %d", roomID)
+	}
+	return nil
+}
+
+// recordingInfo converts a recording to its protobuf form
+func recordingInfo(rec *models.Recording) *proto.RecordingInfo {
+	info := &proto.RecordingInfo{
+		Id:         uint64(rec.ID),
+		RoomId:     uint64(rec.RoomID),
+		StartedBy:  uint64(rec.StartedBy),
+		Status:     rec.Status,
+		StartedAt:  rec.StartedAt.Unix(),
+		DurationMs: rec.DurationMs,
+	}
+	if rec.StoppedAt != nil {
+		info.StoppedAt = rec.StoppedAt.Unix()
+	}
+	for _, id := range rec.ParticipantIDs() {
+		info.ParticipantIds = append(info.ParticipantIds, uint64(id))
+	}
+	for _, f := range rec.Files {
+		info.Files = append(info.Files, &proto.RecordingFile{
+			Id:     uint64(f.ID),
+			UserId: uint64(f.UserID),
+			Mixed:  f.UserID == 0,
+			Size:   f.Size,
+		})
+	}
+	return info
+}
+
+// recordingStatus maps recording errors to gRPC status errors
+func recordingStatus(err error) error {
+	switch {
+	case errors.Is(err, recording.ErrRecordingNotFound), errors.Is(err, recording.ErrFileNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, sfu.ErrAlreadyRecording):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, recording.ErrNotSFU), errors.Is(err, recording.ErrEncrypted), errors.Is(err, sfu.ErrNoRoom), errors.Is(err, sfu.ErrNotRecording):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return err
+	}
+}
@@ -2,11 +2,26 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/Aloys-y/chat-go/auth"
+	"github.com/Aloys-y/chat-go/breakout"
 	"github.com/Aloys-y/chat-go/db"
+	"github.com/Aloys-y/chat-go/membership"
 	"github.com/Aloys-y/chat-go/models"
 	"github.com/Aloys-y/chat-go/proto"
+	"github.com/Aloys-y/chat-go/signaling"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Bitrates Opus can encode at
+const (
+	minOpusBitrateKbps = 6
+	maxOpusBitrateKbps = 510
 )
 
 type RoomServiceImpl struct {
@@ -20,11 +35,40 @@ func (s *RoomServiceImpl) CreateRoom(ctx context.Context, req *proto.CreateRoomR
 		return nil, err
 	}
 
+	if err := checkVideoPolicy(req.VideoPolicy); err != nil {
+		return nil, err
+	}
+	codecs := membership.DefaultCodecPolicy()
+	if req.CodecPolicy != nil {
+		if err := checkCodecPolicy(req.CodecPolicy); err != nil {
+			return nil, err
+		}
+		codecs = codecPolicy(req.CodecPolicy)
+	}
+	if req.MaxParticipants < 0 {
+		return nil, status.Error(codes.InvalidArgument, "max_participants must not be negative")
+	}
+
+	mediaMode := req.MediaMode
+	switch mediaMode {
+	case "":
+		mediaMode = models.MediaModeMesh
+	case models.MediaModeMesh, models.MediaModeSFU:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown media mode %q", req.MediaMode)
+	}
+
 	room := &models.Room{
-		Name:        req.Name,
-		Description: req.Description,
-		IsPublic:    req.IsPublic,
-		OwnerID:     uint(req.OwnerId),
+		Name:            req.Name,
+		Description:     req.Description,
+		IsPublic:        req.IsPublic,
+		OwnerID:         uint(req.OwnerId),
+		MediaMode:       mediaMode,
+		VideoPolicy:     videoPolicy(req.VideoPolicy),
+		StageMode:       req.StageMode,
+		MaxParticipants: int(req.MaxParticipants),
+		CodecPolicy:     codecs,
+		E2EERequired:    req.E2EeRequired,
 	}
 
 	if err := db.DB.Create(room).Error; err != nil {
@@ -48,43 +92,60 @@ func (s *RoomServiceImpl) CreateRoom(ctx context.Context, req *proto.CreateRoomR
 			DisplayName: owner.DisplayName,
 			IsOnline:    owner.IsOnline,
 		},
-		UserCount: 1,
+		UserCount:       1,
+		MediaMode:       room.MediaMode,
+		VideoPolicy:     videoPolicyInfo(room.VideoPolicy),
+		StageMode:       room.StageMode,
+		MaxParticipants: int32(room.MaxParticipants),
+		CodecPolicy:     codecPolicyInfo(room.CodecPolicy),
+		E2EeRequired:    room.E2EERequired,
 	}, nil
 }
 
-// JoinRoom implements RoomServiceServer
+// JoinRoom implements RoomServiceServer. Banned users are refused; users join
+// rooms themselves unless the room owner adds them, and only the owner adds
+// users to a private room.
 func (s *RoomServiceImpl) JoinRoom(ctx context.Context, req *proto.JoinRoomRequest) (*proto.RoomInfo, error) {
-	var room models.Room
-	var user models.User
-
-	if err := db.DB.First(&room, req.RoomId).Error; err != nil {
+	caller, err := callerID(ctx)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := db.DB.First(&user, req.UserId).Error; err != nil {
-		return nil, err
+	room, err := membership.GetRoom(uint(req.RoomId))
+	if err != nil {
+		return nil, membershipStatus(err)
+	}
+	if caller != uint(req.UserId) && room.OwnerID != caller {
+		return nil, status.Error(codes.PermissionDenied, "only the room owner can add other users")
 	}
 
-	// Check if user is already in the room
-	var users []*models.User
-	if err := db.DB.Model(&room).Association("Users").Find(&users); err != nil {
+	banned, err := membership.IsBanned(room.ID, uint(req.UserId))
+	if err != nil {
 		return nil, err
 	}
+	if banned {
+		return nil, membershipStatus(membership.ErrBanned)
+	}
 
 	// Check if the user is already in the room
-	for _, u := range users {
-		if u.ID == uint(req.UserId) {
-			return nil, nil // User already in room
-		}
+	member, err := membership.IsMember(room.ID, uint(req.UserId))
+	if err != nil {
+		return nil, err
 	}
 
-	// Add user to the room
-	if err := db.DB.Model(&room).Association("Users").Append(&user); err != nil {
-		return nil, err
+	if !member {
+		if !room.IsPublic && room.OwnerID != caller {
+			return nil, membershipStatus(membership.ErrNotMember)
+		}
+
+		// Add user to the room
+		if err := membership.Add(room, uint(req.UserId)); err != nil {
+			return nil, membershipStatus(err)
+		}
 	}
 
 	// Get updated room info
-	if err := db.DB.Preload("Owner").First(&room, req.RoomId).Error; err != nil {
+	if err := db.DB.Preload("Owner").First(room, req.RoomId).Error; err != nil {
 		return nil, err
 	}
 
@@ -100,12 +161,30 @@ func (s *RoomServiceImpl) JoinRoom(ctx context.Context, req *proto.JoinRoomReque
 			DisplayName: room.Owner.DisplayName,
 			IsOnline:    room.Owner.IsOnline,
 		},
-		UserCount: int32(db.DB.Model(&room).Association("Users").Count()),
+		UserCount:       int32(db.DB.Model(room).Association("Users").Count()),
+		MediaMode:       room.MediaMode,
+		VideoPolicy:     videoPolicyInfo(room.VideoPolicy),
+		StageMode:       room.StageMode,
+		ParentId:        parentID(room.ParentID),
+		MaxParticipants: int32(room.MaxParticipants),
+		Locked:          room.Locked,
+		CodecPolicy:     codecPolicyInfo(room.CodecPolicy),
+		E2EeRequired:    room.E2EERequired,
 	}, nil
 }
 
-// LeaveRoom implements RoomServiceServer
+// LeaveRoom implements RoomServiceServer. A live connection of the user in the
+// room is removed as well.
 func (s *RoomServiceImpl) LeaveRoom(ctx context.Context, req *proto.LeaveRoomRequest) (*proto.Empty, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Others are removed with KickUser, which checks moderator rights
+	if caller != uint(req.UserId) {
+		return nil, status.Error(codes.PermissionDenied, "users can only leave rooms themselves")
+	}
+
 	var room models.Room
 	var user models.User
 
@@ -122,6 +201,8 @@ func (s *RoomServiceImpl) LeaveRoom(ctx context.Context, req *proto.LeaveRoomReq
 		return nil, fmt.Errorf("failed to remove user from room: %w", err)
 	}
 
+	signaling.RemoveFromRoom(room.ID, user.ID, signaling.RemovalLeft)
+
 	return &proto.Empty{}, nil
 }
 
@@ -144,7 +225,15 @@ func (s *RoomServiceImpl) GetRoomInfo(ctx context.Context, req *proto.GetRoomInf
 			DisplayName: room.Owner.DisplayName,
 			IsOnline:    room.Owner.IsOnline,
 		},
-		UserCount: int32(db.DB.Model(&room).Association("Users").Count()),
+		UserCount:       int32(db.DB.Model(&room).Association("Users").Count()),
+		MediaMode:       room.MediaMode,
+		VideoPolicy:     videoPolicyInfo(room.VideoPolicy),
+		StageMode:       room.StageMode,
+		ParentId:        parentID(room.ParentID),
+		MaxParticipants: int32(room.MaxParticipants),
+		Locked:          room.Locked,
+		CodecPolicy:     codecPolicyInfo(room.CodecPolicy),
+		E2EeRequired:    room.E2EERequired,
 	}, nil
 }
 
@@ -153,7 +242,7 @@ func (s *RoomServiceImpl) ListRooms(ctx context.Context, req *proto.ListRoomsReq
 	var rooms []models.Room
 	var totalCount int64
 
-	query := db.DB.Model(&models.Room{}).Where("is_public = ?", req.IsPublic)
+	query := db.DB.Model(&models.Room{}).Where("is_public = ? AND parent_id IS NULL", req.IsPublic)
 	query.Count(&totalCount)
 
 	// Pagination
@@ -177,7 +266,15 @@ func (s *RoomServiceImpl) ListRooms(ctx context.Context, req *proto.ListRoomsReq
 				DisplayName: room.Owner.DisplayName,
 				IsOnline:    room.Owner.IsOnline,
 			},
-			UserCount: userCount,
+			UserCount:       userCount,
+			MediaMode:       room.MediaMode,
+			VideoPolicy:     videoPolicyInfo(room.VideoPolicy),
+			StageMode:       room.StageMode,
+			ParentId:        parentID(room.ParentID),
+			MaxParticipants: int32(room.MaxParticipants),
+			Locked:          room.Locked,
+			CodecPolicy:     codecPolicyInfo(room.CodecPolicy),
+			E2EeRequired:    room.E2EERequired,
 		})
 	}
 
@@ -197,7 +294,7 @@ func (s *RoomServiceImpl) ListRoomUsers(ctx context.Context, req *proto.ListRoom
 	}
 
 	var users []models.User
-	if err := db.DB.Model(&room).Association("Users").Find(&users).Error; err != nil {
+	if err := db.DB.Model(&room).Association("Users").Find(&users); err != nil {
 		return nil, fmt.Errorf("failed to find room users: %w", err)
 	}
 
@@ -214,3 +311,299 @@ func (s *RoomServiceImpl) ListRoomUsers(ctx context.Context, req *proto.ListRoom
 
 	return &proto.ListUsersResponse{Users: userInfos}, nil
 }
+
+// KickUser implements RoomServiceServer. Only the room owner may kick; the
+// kicked user is dropped from the live room and, when banned, can't rejoin.
+func (s *RoomServiceImpl) KickUser(ctx context.Context, req *proto.KickUserRequest) (*proto.Empty, error) {
+	room, err := ownedRoom(ctx, uint(req.RoomId))
+	if err != nil {
+		return nil, err
+	}
+
+	userID := uint(req.UserId)
+	if userID == room.OwnerID {
+		return nil, status.Error(codes.InvalidArgument, "the room owner can't be kicked")
+	}
+
+	reason := signaling.RemovalKicked
+	if req.Ban {
+		if err := membership.Ban(room.ID, userID, room.OwnerID, req.Reason); err != nil {
+			return nil, fmt.Errorf("failed to ban user: %w", err)
+		}
+		reason = signaling.RemovalBanned
+	} else if err := membership.Remove(room.ID, userID); err != nil {
+		return nil, fmt.Errorf("failed to remove user from room: %w", err)
+	}
+
+	signaling.RemoveFromRoom(room.ID, userID, reason)
+
+	return &proto.Empty{}, nil
+}
+
+// DeleteRoom implements RoomServiceServer. Only the room owner may delete it;
+// everyone still connected to the live room is removed.
+func (s *RoomServiceImpl) DeleteRoom(ctx context.Context, req *proto.DeleteRoomRequest) (*proto.Empty, error) {
+	room, err := ownedRoom(ctx, uint(req.RoomId))
+	if err != nil {
+		return nil, err
+	}
+
+	breakouts := breakout.Discard(room.ID)
+	if err := membership.DeleteRoom(room.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete room: %w", err)
+	}
+
+	signaling.CloseRoom(room.ID, signaling.RemovalRoomDeleted)
+	for _, breakoutID := range breakouts {
+		signaling.CloseRoom(breakoutID, signaling.RemovalRoomDeleted)
+	}
+
+	return &proto.Empty{}, nil
+}
+
+// SetVideoPolicy implements RoomServiceServer. Only the room owner may change
+// the policy; it applies to participants already in the live room as well.
+func (s *RoomServiceImpl) SetVideoPolicy(ctx context.Context, req *proto.SetVideoPolicyRequest) (*proto.RoomInfo, error) {
+	room, err := ownedRoom(ctx, uint(req.RoomId))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVideoPolicy(req.Policy); err != nil {
+		return nil, err
+	}
+
+	policy := videoPolicy(req.Policy)
+	if err := db.DB.Model(room).Select("VideoPolicy").Updates(models.Room{VideoPolicy: policy}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update video policy: %w", err)
+	}
+
+	signaling.SetVideoPolicy(room.ID, policy)
+
+	return s.GetRoomInfo(ctx, &proto.GetRoomInfoRequest{RoomId: req.RoomId})
+}
+
+// SetCodecPolicy implements RoomServiceServer. Only the room owner may change
+// the policy; participants already in the live room are told and new SFU
+// publications are checked against it.
+func (s *RoomServiceImpl) SetCodecPolicy(ctx context.Context, req *proto.SetCodecPolicyRequest) (*proto.RoomInfo, error) {
+	room, err := ownedRoom(ctx, uint(req.RoomId))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCodecPolicy(req.Policy); err != nil {
+		return nil, err
+	}
+
+	policy := codecPolicy(req.Policy)
+	if err := db.DB.Model(room).Select("CodecPolicy").Updates(models.Room{CodecPolicy: policy}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update codec policy: %w", err)
+	}
+
+	signaling.SetCodecPolicy(room.ID, policy)
+
+	return s.GetRoomInfo(ctx, &proto.GetRoomInfoRequest{RoomId: req.RoomId})
+}
+
+// CreateBreakouts implements RoomServiceServer. Only the room owner may open
+// breakouts; the assigned participants are moved into them right away.
+func (s *RoomServiceImpl) CreateBreakouts(ctx context.Context, req *proto.CreateBreakoutsRequest) (*proto.BreakoutsResponse, error) {
+	room, err := ownedRoom(ctx, uint(req.RoomId))
+	if err != nil {
+		return nil, err
+	}
+	if req.DurationSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "duration must not be negative")
+	}
+
+	breakouts := make([]breakout.Breakout, 0, len(req.Breakouts))
+	for _, b := range req.Breakouts {
+		userIDs := make([]uint, 0, len(b.UserIds))
+		for _, userID := range b.UserIds {
+			userIDs = append(userIDs, uint(userID))
+		}
+		breakouts = append(breakouts, breakout.Breakout{Name: b.Name, UserIDs: userIDs})
+	}
+
+	sess, err := breakout.Create(room, breakouts, req.Random, time.Duration(req.DurationSeconds)*time.Second)
+	if err != nil {
+		return nil, breakoutStatus(err)
+	}
+	return breakoutsResponse(sess), nil
+}
+
+// CloseBreakouts implements RoomServiceServer. Only the room owner may close
+// breakouts; everyone returns to the room when the countdown runs out.
+func (s *RoomServiceImpl) CloseBreakouts(ctx context.Context, req *proto.CloseBreakoutsRequest) (*proto.BreakoutsResponse, error) {
+	room, err := ownedRoom(ctx, uint(req.RoomId))
+	if err != nil {
+		return nil, err
+	}
+	if req.CountdownSeconds < 0 {
+		return nil, status.Error(codes.InvalidArgument, "countdown must not be negative")
+	}
+
+	countdown := breakout.DefaultCountdown
+	if req.CountdownSeconds > 0 {
+		countdown = time.Duration(req.CountdownSeconds) * time.Second
+	}
+
+	sess, err := breakout.Close(room.ID, countdown)
+	if err != nil {
+		return nil, breakoutStatus(err)
+	}
+	return breakoutsResponse(sess), nil
+}
+
+// breakoutsResponse converts open breakouts for responses
+func breakoutsResponse(sess *breakout.Session) *proto.BreakoutsResponse {
+	resp := &proto.BreakoutsResponse{
+		RoomId:    uint64(sess.ParentID),
+		Breakouts: make([]*proto.Breakout, 0, len(sess.Breakouts)),
+	}
+	if !sess.EndsAt.IsZero() {
+		resp.EndsAt = sess.EndsAt.Unix()
+	}
+	for _, b := range sess.Breakouts {
+		userIDs := make([]uint64, 0, len(b.UserIDs))
+		for _, userID := range b.UserIDs {
+			userIDs = append(userIDs, uint64(userID))
+		}
+		resp.Breakouts = append(resp.Breakouts, &proto.Breakout{
+			Name:    b.Name,
+			UserIds: userIDs,
+			RoomId:  uint64(b.RoomID),
+		})
+	}
+	return resp
+}
+
+// breakoutStatus maps breakout errors to gRPC status errors
+func breakoutStatus(err error) error {
+	switch {
+	case errors.Is(err, breakout.ErrNoBreakouts), errors.Is(err, breakout.ErrNotParticipant),
+		errors.Is(err, breakout.ErrAssignedTwice), errors.Is(err, breakout.ErrNoParticipants):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, breakout.ErrAlreadyOpen):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, breakout.ErrNotOpen), errors.Is(err, breakout.ErrIsBreakout):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return err
+	}
+}
+
+// parentID converts a breakout's parent room for responses; 0 means none
+func parentID(id *uint) uint64 {
+	if id == nil {
+		return 0
+	}
+	return uint64(*id)
+}
+
+// checkVideoPolicy rejects negative limits
+func checkVideoPolicy(p *proto.VideoPolicy) error {
+	if p.GetMaxWidth() < 0 || p.GetMaxHeight() < 0 || p.GetMaxBitrateKbps() < 0 {
+		return status.Error(codes.InvalidArgument, "video policy limits must not be negative")
+	}
+	return nil
+}
+
+// videoPolicy converts a requested video policy; nil allows any video
+func videoPolicy(p *proto.VideoPolicy) models.VideoPolicy {
+	return models.VideoPolicy{
+		Disabled:       p.GetDisabled(),
+		MaxWidth:       int(p.GetMaxWidth()),
+		MaxHeight:      int(p.GetMaxHeight()),
+		MaxBitrateKbps: int(p.GetMaxBitrateKbps()),
+	}
+}
+
+// videoPolicyInfo converts a room's video policy for responses
+func videoPolicyInfo(p models.VideoPolicy) *proto.VideoPolicy {
+	return &proto.VideoPolicy{
+		Disabled:       p.Disabled,
+		MaxWidth:       int32(p.MaxWidth),
+		MaxHeight:      int32(p.MaxHeight),
+		MaxBitrateKbps: int32(p.MaxBitrateKbps),
+	}
+}
+
+// checkCodecPolicy rejects unknown codecs and audio bitrates Opus can't
+// encode at
+func checkCodecPolicy(p *proto.CodecPolicy) error {
+	for _, mimeType := range p.GetPreferredCodecs() {
+		if _, ok := models.CanonicalCodec(mimeType); !ok {
+			return status.Errorf(codes.InvalidArgument, "unknown codec %q", mimeType)
+		}
+	}
+	if kbps := p.GetMaxAudioBitrateKbps(); kbps != 0 && (kbps < minOpusBitrateKbps || kbps > maxOpusBitrateKbps) {
+		return status.Errorf(codes.InvalidArgument, "max_audio_bitrate_kbps must be 0 or between %d and %d",
+			minOpusBitrateKbps, maxOpusBitrateKbps)
+	}
+	return nil
+}
+
+// codecPolicy converts a checked codec policy, spelling the codecs the way
+// they are known
+func codecPolicy(p *proto.CodecPolicy) models.CodecPolicy {
+	policy := models.CodecPolicy{
+		OpusDTX:             p.GetOpusDtx(),
+		OpusFEC:             p.GetOpusFec(),
+		Stereo:              p.GetStereo(),
+		MaxAudioBitrateKbps: int(p.GetMaxAudioBitrateKbps()),
+	}
+	for _, mimeType := range p.GetPreferredCodecs() {
+		codec, _ := models.CanonicalCodec(mimeType)
+		policy.PreferredCodecs = append(policy.PreferredCodecs, codec)
+	}
+	return policy
+}
+
+// codecPolicyInfo converts a room's codec policy for responses
+func codecPolicyInfo(p models.CodecPolicy) *proto.CodecPolicy {
+	return &proto.CodecPolicy{
+		PreferredCodecs:     p.PreferredCodecs,
+		OpusDtx:             p.OpusDTX,
+		OpusFec:             p.OpusFEC,
+		Stereo:              p.Stereo,
+		MaxAudioBitrateKbps: int32(p.MaxAudioBitrateKbps),
+	}
+}
+
+// callerID returns the authenticated user put in the context by the auth interceptor
+func callerID(ctx context.Context) (uint, error) {
+	userID, ok := ctx.Value(auth.CtxUserID).(uint)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "user is not authenticated")
+	}
+	return userID, nil
+}
+
+// ownedRoom loads a room the caller owns
+func ownedRoom(ctx context.Context, roomID uint) (*models.Room, error) {
+	caller, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	room, err := membership.GetRoom(roomID)
+	if err != nil {
+		return nil, membershipStatus(err)
+	}
+	if room.OwnerID != caller {
+		return nil, status.Error(codes.PermissionDenied, "only the room owner can do this")
+	}
+	return room, nil
+}
+
+// membershipStatus maps membership errors to gRPC status errors
+func membershipStatus(err error) error {
+	switch {
+	case errors.Is(err, membership.ErrRoomNotFound), errors.Is(err, membership.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, membership.ErrNotMember), errors.Is(err, membership.ErrBanned):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return err
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/Aloys-y/chat-go/auth"
 	"github.com/Aloys-y/chat-go/db"
+	"github.com/Aloys-y/chat-go/ice"
 	"github.com/Aloys-y/chat-go/models"
 	"github.com/Aloys-y/chat-go/proto"
 )
@@ -95,3 +96,27 @@ func (s *UserServiceImpl) UpdateUserStatus(ctx context.Context, req *proto.Updat
 
 	return &proto.Empty{}, nil
 }
+
+// GetICEServers implements UserServiceServer. TURN entries configured as
+// ephemeral carry credentials minted for the caller.
+func (s *UserServiceImpl) GetICEServers(ctx context.Context, req *proto.GetICEServersRequest) (*proto.ICEServersResponse, error) {
+	userID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := ice.ConfigForUser(userID)
+	servers := make([]*proto.ICEServer, 0, len(cfg.ICEServers))
+	for _, server := range cfg.ICEServers {
+		servers = append(servers, &proto.ICEServer{
+			Urls:       server.URLs,
+			Username:   server.Username,
+			Credential: server.Credential,
+		})
+	}
+
+	return &proto.ICEServersResponse{
+		IceServers: servers,
+		ExpiresAt:  cfg.ExpiresAt,
+	}, nil
+}
@@ -0,0 +1,156 @@
+package sfu
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// Signaling message types announcing broadcasts to participants
+const (
+	MsgBroadcastStarted = "broadcast_started"
+	MsgBroadcastStopped = "broadcast_stopped"
+)
+
+var (
+	ErrAlreadyBroadcasting = errors.New("room is already being broadcast")
+	ErrNotBroadcasting     = errors.New("room is not being broadcast")
+)
+
+// BroadcastSink publishes the mix of a broadcast room
+type BroadcastSink interface {
+	// WriteFrame receives the next 20ms Opus frame of the mono room mix.
+	// Frames keep coming while nobody talks, so their count tracks real time.
+	WriteFrame(frame []byte)
+	// Close is called once the broadcast stopped
+	Close()
+}
+
+// broadcaster mixes the audio tracks of a room for a broadcast
+type broadcaster struct {
+	sink    BroadcastSink
+	started time.Time
+	mixer   *mixer
+	encoder *mixEncoder
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartBroadcast starts mixing the live SFU room into the sink.
+// Participants are told with a broadcast_started notice.
+func StartBroadcast(roomID uint, sink BroadcastSink) error {
+	roomsMux.Lock()
+	room, exists := rooms[roomID]
+	roomsMux.Unlock()
+	if !exists {
+		return ErrNoRoom
+	}
+
+	room.mux.Lock()
+	defer room.mux.Unlock()
+
+	if room.broadcast.Load() != nil {
+		return ErrAlreadyBroadcasting
+	}
+
+	encoder, err := newMixEncoder()
+	if err != nil {
+		return err
+	}
+	b := &broadcaster{
+		sink:    sink,
+		started: time.Now(),
+		mixer:   newMixer(),
+		encoder: encoder,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	room.broadcast.Store(b)
+	go b.mixLoop()
+
+	for userID := range room.peers {
+		room.signal(userID, MsgBroadcastStarted, b.notice())
+	}
+
+	log.Printf("Broadcast of SFU room %d started", roomID)
+	return nil
+}
+
+// StopBroadcast stops the room's broadcast and tells the participants with a
+// broadcast_stopped notice
+func StopBroadcast(roomID uint) error {
+	roomsMux.Lock()
+	room, exists := rooms[roomID]
+	roomsMux.Unlock()
+	if !exists {
+		return ErrNotBroadcasting
+	}
+
+	b := room.broadcast.Swap(nil)
+	if b == nil {
+		return ErrNotBroadcasting
+	}
+	b.finish()
+
+	room.mux.Lock()
+	for userID := range room.peers {
+		room.signal(userID, MsgBroadcastStopped, b.notice())
+	}
+	room.mux.Unlock()
+
+	log.Printf("Broadcast of SFU room %d stopped after %s", roomID, time.Since(b.started))
+	return nil
+}
+
+// StopAllBroadcasts stops every broadcast in progress, on shutdown
+func StopAllBroadcasts() {
+	roomsMux.Lock()
+	var broadcasts []*broadcaster
+	for _, room := range rooms {
+		if b := room.broadcast.Swap(nil); b != nil {
+			broadcasts = append(broadcasts, b)
+		}
+	}
+	roomsMux.Unlock()
+
+	for _, b := range broadcasts {
+		b.finish()
+	}
+}
+
+// notice is the payload of the broadcast notices
+func (b *broadcaster) notice() map[string]interface{} {
+	return map[string]interface{}{
+		"started_at": b.started.Unix(),
+	}
+}
+
+// mixLoop hands one frame of the room mix per interval to the sink until the
+// broadcast stops
+func (b *broadcaster) mixLoop() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(mixInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			frame, err := b.encoder.encode(b.mixer.next().sum)
+			if err != nil {
+				log.Printf("Failed to encode broadcast mix: %v", err)
+				continue
+			}
+			b.sink.WriteFrame(frame)
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// finish stops the mix loop and closes the sink
+func (b *broadcaster) finish() {
+	close(b.stop)
+	<-b.done
+	b.sink.Close()
+}
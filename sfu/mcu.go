@@ -0,0 +1,245 @@
+package sfu
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// MsgAudioMode tells a client whether it receives the room's audio mixed by
+// the server or every participant's track
+const MsgAudioMode = "sfu_audio_mode"
+
+// MixedStreamID is the media stream ID of the mixed audio track, which
+// belongs to no participant
+const MixedStreamID = "mixed"
+
+// mcu mixes the audio of a room for the peers that receive one mixed track
+// instead of a track per participant, e.g. on poor connections. Each
+// listener gets the mix without their own voice, encoded for them alone.
+type mcu struct {
+	mixer *mixer
+
+	mux       sync.Mutex
+	listeners map[*Peer]*mixListener
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// mixListener is the mixed audio track of a peer
+type mixListener struct {
+	track   *webrtc.TrackLocalStaticSample
+	sender  *webrtc.RTPSender
+	encoder *mixEncoder
+}
+
+func newMCU() *mcu {
+	return &mcu{
+		mixer:     newMixer(),
+		listeners: make(map[*Peer]*mixListener),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// SetMixedAudio switches the peer between receiving one audio track mixed by
+// the server and the audio tracks of every other participant. Video is
+// forwarded either way. The subscriber is renegotiated when the mode changes.
+func (p *Peer) SetMixedAudio(mixed bool) error {
+	r := p.room
+	r.mux.Lock()
+	changed, err := r.setMixedLocked(p, mixed)
+	r.mux.Unlock()
+
+	if changed {
+		p.negotiate()
+	}
+	return err
+}
+
+// StopMixing switches every peer of the room receiving mixed audio back to
+// forwarded tracks, e.g. once the room's media is end-to-end encrypted and
+// can't be mixed. Those peers are told with an sfu_audio_mode message.
+func StopMixing(roomID uint) {
+	roomsMux.Lock()
+	room, exists := rooms[roomID]
+	roomsMux.Unlock()
+	if !exists {
+		return
+	}
+
+	room.mux.Lock()
+	var changed []*Peer
+	for _, peer := range room.peers {
+		if ok, _ := room.setMixedLocked(peer, false); ok {
+			changed = append(changed, peer)
+			room.signal(peer.UserID, MsgAudioMode, map[string]interface{}{"mixed": false})
+		}
+	}
+	room.mux.Unlock()
+
+	for _, peer := range changed {
+		peer.negotiate()
+	}
+}
+
+// setMixedLocked moves the peer between the room's MCU and its audio
+// forwarders. It reports whether the peer's subscriptions changed. The caller
+// must hold r.mux.
+func (r *Room) setMixedLocked(p *Peer, mixed bool) (bool, error) {
+	p.mux.Lock()
+	if p.closed || p.mixed == mixed {
+		p.mux.Unlock()
+		return false, nil
+	}
+	p.mixed = mixed
+	p.mux.Unlock()
+
+	if !mixed {
+		r.removeListenerLocked(p)
+		for _, f := range r.tracks {
+			if f.owner != p && f.remote.Kind() == webrtc.RTPCodecTypeAudio {
+				p.subscribe(f)
+			}
+		}
+		log.Printf("User %d receives forwarded audio in SFU room %d", p.UserID, r.ID)
+		return true, nil
+	}
+
+	m := r.mcu.Load()
+	if m == nil {
+		m = newMCU()
+		r.mcu.Store(m)
+		go m.mixLoop()
+	}
+	if err := m.add(p); err != nil {
+		p.mux.Lock()
+		p.mixed = false
+		p.mux.Unlock()
+		r.removeListenerLocked(p)
+		return false, err
+	}
+	for _, f := range r.tracks {
+		if f.remote.Kind() == webrtc.RTPCodecTypeAudio {
+			p.unsubscribe(f)
+		}
+	}
+	log.Printf("User %d receives mixed audio in SFU room %d", p.UserID, r.ID)
+	return true, nil
+}
+
+// removeListenerLocked takes the peer off the room's MCU, which stops once
+// nobody listens to it. The caller must hold r.mux.
+func (r *Room) removeListenerLocked(p *Peer) {
+	m := r.mcu.Load()
+	if m == nil {
+		return
+	}
+	if m.remove(p) == 0 {
+		r.mcu.Store(nil)
+		m.finish()
+	}
+}
+
+// add gives the peer a mixed audio track on its subscriber connection
+func (m *mcu) add(p *Peer) error {
+	track, err := webrtc.NewTrackLocalStaticSample(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: mixSampleRate, Channels: 2},
+		"mixed-audio", MixedStreamID)
+	if err != nil {
+		return fmt.Errorf("failed to create mixed track: %w", err)
+	}
+	encoder, err := newMixEncoder()
+	if err != nil {
+		return err
+	}
+
+	p.mux.Lock()
+	sender, err := p.subscriber.AddTrack(track)
+	p.mux.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to add mixed track: %w", err)
+	}
+
+	// Read RTCP so the interceptors see the receiver reports
+	go func() {
+		for {
+			if _, _, err := sender.ReadRTCP(); err != nil {
+				return
+			}
+		}
+	}()
+
+	m.mux.Lock()
+	m.listeners[p] = &mixListener{track: track, sender: sender, encoder: encoder}
+	m.mux.Unlock()
+	return nil
+}
+
+// remove drops the mixed track of the peer and returns how many listeners
+// are left
+func (m *mcu) remove(p *Peer) int {
+	m.mux.Lock()
+	l, ok := m.listeners[p]
+	delete(m.listeners, p)
+	n := len(m.listeners)
+	m.mux.Unlock()
+
+	if ok {
+		p.mux.Lock()
+		if !p.closed {
+			if err := p.subscriber.RemoveTrack(l.sender); err != nil {
+				log.Printf("Failed to remove mixed track of user %d: %v", p.UserID, err)
+			}
+		}
+		p.mux.Unlock()
+	}
+	return n
+}
+
+// mixLoop sends one frame of the mix per interval to every listener until
+// the MCU stops
+func (m *mcu) mixLoop() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(mixInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.mixFrame()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// mixFrame encodes the next frame of the mix for every listener, leaving out
+// their own voice
+func (m *mcu) mixFrame() {
+	frame := m.mixer.next()
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for p, l := range m.listeners {
+		data, err := l.encoder.encode(frame.without(p.UserID))
+		if err != nil {
+			log.Printf("Failed to encode mix for user %d: %v", p.UserID, err)
+			continue
+		}
+		if err := l.track.WriteSample(media.Sample{Data: data, Duration: mixInterval}); err != nil {
+			log.Printf("Failed to send mix to user %d: %v", p.UserID, err)
+		}
+	}
+}
+
+// finish stops the mix loop
+func (m *mcu) finish() {
+	close(m.stop)
+	<-m.done
+}
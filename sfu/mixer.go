@@ -0,0 +1,192 @@
+package sfu
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/jj11hh/opus"
+	"github.com/pion/webrtc/v4"
+)
+
+// mixer decodes the audio tracks of a room and sums them into 20ms mono
+// frames. Recordings, broadcasts and the room's MCU each have their own.
+type mixer struct {
+	mux    sync.Mutex
+	tracks map[*forwarder]*mixTrack
+}
+
+// mixTrack is the decoding state of one published audio track. Tracks that
+// can't be decoded have no decoder and are left out of the mix.
+type mixTrack struct {
+	userID  uint
+	decoder *opus.Decoder
+
+	// pending holds Opus payloads not yet decoded; pcm holds decoded
+	// samples not yet mixed
+	pending [][]byte
+	pcm     []int16
+}
+
+// mixedFrame is one frame of a mix along with each user's part of it, so
+// listeners can be given the mix without their own voice
+type mixedFrame struct {
+	sum    []int32
+	voices map[uint][]int32
+}
+
+func newMixer() *mixer {
+	return &mixer{tracks: make(map[*forwarder]*mixTrack)}
+}
+
+// write queues the payload of an audio packet for the next frame, creating
+// the track's decoder on its first packet
+func (m *mixer) write(f *forwarder, payload []byte) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	t, ok := m.tracks[f]
+	if !ok {
+		t = newMixTrack(f)
+		m.tracks[f] = t
+	}
+	if t.decoder != nil && len(payload) > 0 {
+		t.pending = append(t.pending, payload)
+	}
+}
+
+// newMixTrack creates the decoder of a track. Only Opus tracks are mixed.
+func newMixTrack(f *forwarder) *mixTrack {
+	t := &mixTrack{userID: f.owner.UserID}
+	if !strings.EqualFold(f.remote.Codec().MimeType, webrtc.MimeTypeOpus) {
+		return t
+	}
+
+	var err error
+	opusMux.Lock()
+	t.decoder, err = opus.NewDecoder(mixSampleRate, 1)
+	opusMux.Unlock()
+	if err != nil {
+		log.Printf("Failed to create Opus decoder, user %d left out of the mix: %v", t.userID, err)
+	}
+	return t
+}
+
+// removeTrack drops a track that ended
+func (m *mixer) removeTrack(f *forwarder) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	delete(m.tracks, f)
+}
+
+// next sums the next frame of every track. Tracks without a full frame
+// decoded yet are silent in this one.
+func (m *mixer) next() *mixedFrame {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	opusMux.Lock()
+	defer opusMux.Unlock()
+
+	frame := &mixedFrame{
+		sum:    make([]int32, mixFrameSize),
+		voices: make(map[uint][]int32),
+	}
+	for _, t := range m.tracks {
+		if t.decoder == nil {
+			continue
+		}
+		t.decodePending()
+		if len(t.pcm) < mixFrameSize {
+			continue
+		}
+		voice := frame.voices[t.userID]
+		if voice == nil {
+			voice = make([]int32, mixFrameSize)
+			frame.voices[t.userID] = voice
+		}
+		for i := range frame.sum {
+			frame.sum[i] += int32(t.pcm[i])
+			voice[i] += int32(t.pcm[i])
+		}
+		t.pcm = t.pcm[mixFrameSize:]
+	}
+	return frame
+}
+
+// without returns the mix minus the voice of the user
+func (f *mixedFrame) without(userID uint) []int32 {
+	voice, ok := f.voices[userID]
+	if !ok {
+		return f.sum
+	}
+	sum := make([]int32, len(f.sum))
+	for i := range sum {
+		sum[i] = f.sum[i] - voice[i]
+	}
+	return sum
+}
+
+// decodePending decodes the queued payloads of a track, keeping at most
+// maxMixBacklog frames. The caller must hold opusMux.
+func (t *mixTrack) decodePending() {
+	buf := make([]int16, maxOpusFrameSize)
+	for _, payload := range t.pending {
+		n, err := t.decoder.Decode(payload, buf)
+		if err != nil {
+			continue
+		}
+		t.pcm = append(t.pcm, buf[:n]...)
+	}
+	t.pending = t.pending[:0]
+
+	if max := maxMixBacklog * mixFrameSize; len(t.pcm) > max {
+		t.pcm = t.pcm[len(t.pcm)-max:]
+	}
+}
+
+// clip converts a summed frame to 16-bit samples, saturating on overflow
+func clip(sum []int32) []int16 {
+	frame := make([]int16, len(sum))
+	for i, v := range sum {
+		switch {
+		case v > 32767:
+			frame[i] = 32767
+		case v < -32768:
+			frame[i] = -32768
+		default:
+			frame[i] = int16(v)
+		}
+	}
+	return frame
+}
+
+// mixEncoder encodes mixed frames to Opus
+type mixEncoder struct {
+	encoder *opus.Encoder
+}
+
+func newMixEncoder() (*mixEncoder, error) {
+	opusMux.Lock()
+	encoder, err := opus.NewEncoder(mixSampleRate, 1, opus.AppVoIP)
+	opusMux.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Opus encoder: %w", err)
+	}
+	return &mixEncoder{encoder: encoder}, nil
+}
+
+// encode returns the Opus packet of a summed frame
+func (e *mixEncoder) encode(sum []int32) ([]byte, error) {
+	frame := clip(sum)
+	data := make([]byte, 1500)
+
+	opusMux.Lock()
+	defer opusMux.Unlock()
+	n, err := e.encoder.Encode(frame, data)
+	if err != nil {
+		return nil, err
+	}
+	return data[:n], nil
+}
@@ -0,0 +1,352 @@
+package sfu
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v4"
+)
+
+// Peer is a participant's pair of transports to the SFU
+type Peer struct {
+	UserID uint
+	room   *Room
+
+	publisher  *webrtc.PeerConnection
+	subscriber *webrtc.PeerConnection
+
+	// mux guards the publisher connection, the subscriber negotiation state
+	// and whether the peer receives mixed audio
+	mux         sync.Mutex
+	closed      bool
+	negotiating bool
+	pending     bool
+	mixed       bool
+	senders     map[*forwarder]*webrtc.RTPSender
+	queued      map[string][]webrtc.ICECandidateInit
+}
+
+// forwarder relays the RTP of one published track to the subscribers
+type forwarder struct {
+	owner  *Peer
+	remote *webrtc.TrackRemote
+	local  *webrtc.TrackLocalStaticRTP
+}
+
+// newPeer creates the publisher and subscriber connections of a user
+func newPeer(api *webrtc.API, room *Room, userID uint) (*Peer, error) {
+	p := &Peer{
+		UserID:  userID,
+		room:    room,
+		senders: make(map[*forwarder]*webrtc.RTPSender),
+		queued:  make(map[string][]webrtc.ICECandidateInit),
+	}
+
+	var err error
+	if p.publisher, err = p.newPublisher(api); err != nil {
+		return nil, err
+	}
+	if p.subscriber, err = api.NewPeerConnection(webrtc.Configuration{}); err != nil {
+		p.publisher.Close()
+		return nil, fmt.Errorf("failed to create subscriber connection: %w", err)
+	}
+
+	p.subscriber.OnICECandidate(p.trickle(TargetSubscriber))
+	return p, nil
+}
+
+// newPublisher creates a connection receiving the tracks the user publishes
+func (p *Peer) newPublisher(api *webrtc.API) (*webrtc.PeerConnection, error) {
+	pc, err := api.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create publisher connection: %w", err)
+	}
+	pc.OnTrack(p.forward)
+	pc.OnICECandidate(p.trickle(TargetPublisher))
+	return pc, nil
+}
+
+// getPublisher returns the current publisher connection
+func (p *Peer) getPublisher() *webrtc.PeerConnection {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.publisher
+}
+
+// trickle sends the server's ICE candidates for a transport to the client
+func (p *Peer) trickle(target string) func(*webrtc.ICECandidate) {
+	return func(c *webrtc.ICECandidate) {
+		if c == nil {
+			return
+		}
+		p.room.signal(p.UserID, MsgCandidate, Candidate{Target: target, Candidate: c.ToJSON()})
+	}
+}
+
+// Publish applies the client's offer for the tracks it publishes and returns
+// the server's answer. Clients offer again to add or remove tracks.
+func (p *Peer) Publish(offer webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+	if offer.Type != webrtc.SDPTypeOffer {
+		return webrtc.SessionDescription{}, errors.New("publish expects an SDP offer")
+	}
+	publisher := p.getPublisher()
+	if err := publisher.SetRemoteDescription(offer); err != nil {
+		return webrtc.SessionDescription{}, err
+	}
+	answer, err := publisher.CreateAnswer(nil)
+	if err != nil {
+		return webrtc.SessionDescription{}, err
+	}
+	if err := publisher.SetLocalDescription(answer); err != nil {
+		return webrtc.SessionDescription{}, err
+	}
+
+	p.mux.Lock()
+	p.flushCandidatesLocked(TargetPublisher, publisher)
+	p.mux.Unlock()
+	return answer, nil
+}
+
+// Unpublish replaces the publisher connection, ending every track the user
+// publishes. The client has to publish again on a new connection.
+func (p *Peer) Unpublish() error {
+	api, err := API()
+	if err != nil {
+		return err
+	}
+	publisher, err := p.newPublisher(api)
+	if err != nil {
+		return err
+	}
+
+	p.mux.Lock()
+	if p.closed {
+		p.mux.Unlock()
+		return publisher.Close()
+	}
+	old := p.publisher
+	p.publisher = publisher
+	delete(p.queued, TargetPublisher)
+	p.mux.Unlock()
+
+	log.Printf("User %d stopped publishing in SFU room %d", p.UserID, p.room.ID)
+	return old.Close()
+}
+
+// Answer applies the client's answer to the last subscription offer and
+// starts the next renegotiation if tracks changed in the meantime
+func (p *Peer) Answer(answer webrtc.SessionDescription) error {
+	if answer.Type != webrtc.SDPTypeAnswer {
+		return errors.New("subscription expects an SDP answer")
+	}
+
+	p.mux.Lock()
+	if !p.negotiating {
+		p.mux.Unlock()
+		return errors.New("no subscription offer is pending")
+	}
+	if err := p.subscriber.SetRemoteDescription(answer); err != nil {
+		p.mux.Unlock()
+		return err
+	}
+	p.negotiating = false
+	p.flushCandidatesLocked(TargetSubscriber, p.subscriber)
+	renegotiate := p.pending
+	p.pending = false
+	p.mux.Unlock()
+
+	if renegotiate {
+		p.negotiate()
+	}
+	return nil
+}
+
+// AddCandidate adds a client ICE candidate to one of the transports. Candidates
+// arriving before the transport's remote description are queued.
+func (p *Peer) AddCandidate(c Candidate) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	var pc *webrtc.PeerConnection
+	switch c.Target {
+	case TargetPublisher:
+		pc = p.publisher
+	case TargetSubscriber:
+		pc = p.subscriber
+	default:
+		return fmt.Errorf("unknown transport %q", c.Target)
+	}
+	if pc.RemoteDescription() == nil {
+		p.queued[c.Target] = append(p.queued[c.Target], c.Candidate)
+		return nil
+	}
+	return pc.AddICECandidate(c.Candidate)
+}
+
+// flushCandidatesLocked adds the candidates queued for a transport. The
+// caller must hold p.mux.
+func (p *Peer) flushCandidatesLocked(target string, pc *webrtc.PeerConnection) {
+	for _, c := range p.queued[target] {
+		if err := pc.AddICECandidate(c); err != nil {
+			log.Printf("Failed to add ICE candidate for user %d: %v", p.UserID, err)
+		}
+	}
+	delete(p.queued, target)
+}
+
+// negotiate offers the current set of subscribed tracks to the client. While
+// an offer is outstanding the renegotiation is deferred until it is answered.
+func (p *Peer) negotiate() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.closed {
+		return
+	}
+	if p.negotiating {
+		p.pending = true
+		return
+	}
+
+	offer, err := p.subscriber.CreateOffer(nil)
+	if err != nil {
+		log.Printf("Failed to create subscription offer for user %d: %v", p.UserID, err)
+		return
+	}
+	if err := p.subscriber.SetLocalDescription(offer); err != nil {
+		log.Printf("Failed to set subscription offer for user %d: %v", p.UserID, err)
+		return
+	}
+	p.negotiating = true
+	p.room.signal(p.UserID, MsgSubscribeOffer, offer)
+}
+
+// subscribe adds a forwarded track to the subscriber connection. Peers
+// receiving mixed audio skip audio tracks. The caller renegotiates when it
+// returns true.
+func (p *Peer) subscribe(f *forwarder) bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.closed || (p.mixed && f.remote.Kind() == webrtc.RTPCodecTypeAudio) {
+		return false
+	}
+	sender, err := p.subscriber.AddTrack(f.local)
+	if err != nil {
+		log.Printf("Failed to subscribe user %d to a track of user %d: %v", p.UserID, f.owner.UserID, err)
+		return false
+	}
+	p.senders[f] = sender
+
+	// Forward keyframe requests from the subscriber to the publisher
+	go func() {
+		for {
+			packets, _, err := sender.ReadRTCP()
+			if err != nil {
+				return
+			}
+			for _, packet := range packets {
+				switch packet.(type) {
+				case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+					f.requestKeyframe()
+				}
+			}
+		}
+	}()
+	f.requestKeyframe()
+	return true
+}
+
+// unsubscribe removes a forwarded track from the subscriber connection. The
+// caller renegotiates when it returns true.
+func (p *Peer) unsubscribe(f *forwarder) bool {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	sender, ok := p.senders[f]
+	if !ok {
+		return false
+	}
+	delete(p.senders, f)
+	if p.closed {
+		return false
+	}
+	if err := p.subscriber.RemoveTrack(sender); err != nil {
+		log.Printf("Failed to unsubscribe user %d from a track: %v", p.UserID, err)
+	}
+	return true
+}
+
+// forward relays a track published by the client until it ends
+func (p *Peer) forward(remote *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+	local, err := webrtc.NewTrackLocalStaticRTP(remote.Codec().RTPCodecCapability, remote.ID(), streamID(p.UserID))
+	if err != nil {
+		log.Printf("Failed to create forwarded track for user %d: %v", p.UserID, err)
+		return
+	}
+
+	f := &forwarder{owner: p, remote: remote, local: local}
+	p.room.addTrack(f)
+	defer p.room.removeTrack(f)
+
+	log.Printf("User %d published %s track %s in SFU room %d", p.UserID, remote.Kind(), remote.ID(), p.room.ID)
+
+	for {
+		packet, _, err := remote.ReadRTP()
+		if err != nil {
+			return
+		}
+		if remote.Kind() == webrtc.RTPCodecTypeAudio {
+			if rec := p.room.recording.Load(); rec != nil {
+				rec.write(f, packet)
+			}
+			if b := p.room.broadcast.Load(); b != nil {
+				b.mixer.write(f, packet.Payload)
+			}
+			if m := p.room.mcu.Load(); m != nil {
+				m.mixer.write(f, packet.Payload)
+			}
+		}
+		if err := local.WriteRTP(packet); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			return
+		}
+	}
+}
+
+// requestKeyframe asks the publisher of a video track for a new keyframe
+func (f *forwarder) requestKeyframe() {
+	if f.remote.Kind() != webrtc.RTPCodecTypeVideo {
+		return
+	}
+	f.owner.getPublisher().WriteRTCP([]rtcp.Packet{
+		&rtcp.PictureLossIndication{MediaSSRC: uint32(f.remote.SSRC())},
+	})
+}
+
+// close tears down both transports. Tracks published by the peer end and are
+// removed from the other subscribers.
+func (p *Peer) close() {
+	p.mux.Lock()
+	if p.closed {
+		p.mux.Unlock()
+		return
+	}
+	p.closed = true
+	publisher := p.publisher
+	p.mux.Unlock()
+
+	p.room.mux.Lock()
+	p.room.removeListenerLocked(p)
+	p.room.mux.Unlock()
+
+	if err := publisher.Close(); err != nil {
+		log.Printf("Failed to close publisher of user %d: %v", p.UserID, err)
+	}
+	if err := p.subscriber.Close(); err != nil {
+		log.Printf("Failed to close subscriber of user %d: %v", p.UserID, err)
+	}
+}
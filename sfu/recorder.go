@@ -0,0 +1,387 @@
+package sfu
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+// Signaling message types announcing recordings to participants
+const (
+	MsgRecordingStarted = "recording_started"
+	MsgRecordingStopped = "recording_stopped"
+)
+
+const (
+	// mixSampleRate and mixFrameSize describe the 20ms mono frames of the
+	// room mix
+	mixSampleRate = 48000
+	mixFrameSize  = 960
+	mixInterval   = 20 * time.Millisecond
+
+	// maxMixBacklog bounds the decoded audio kept per track, in frames, so a
+	// track running ahead of the mixer can't delay it indefinitely
+	maxMixBacklog = 10
+
+	// maxOpusFrameSize is the number of samples in the longest Opus frame
+	maxOpusFrameSize = 5760
+)
+
+var (
+	ErrNoRoom           = errors.New("no live SFU room")
+	ErrAlreadyRecording = errors.New("room is already being recorded")
+	ErrNotRecording     = errors.New("room is not being recorded")
+
+	// opusMux serializes codec calls: all Opus encoders and decoders share
+	// one WebAssembly instance of libopus
+	opusMux sync.Mutex
+)
+
+// RecordingSink stores the files of a recording and is told when it ends
+type RecordingSink interface {
+	// Create opens a new file of the recording
+	Create(name string) (io.WriteCloser, error)
+	// Finish is called once the recording stopped and all its files are closed
+	Finish(result RecordingResult)
+}
+
+// RecordedFile is an Ogg/Opus file written by a recording. UserID is 0 for
+// the room mix.
+type RecordedFile struct {
+	UserID uint
+	Name   string
+	Size   int64
+}
+
+// RecordingResult describes a finished recording
+type RecordingResult struct {
+	ID       uint
+	Duration time.Duration
+	Files    []RecordedFile
+}
+
+// recorder writes every audio track of a room to its own Ogg/Opus file and
+// mixes them into one more
+type recorder struct {
+	ID      uint
+	sink    RecordingSink
+	started time.Time
+
+	mux     sync.Mutex
+	stopped bool
+	tracks  map[*forwarder]*trackRecording
+	counts  map[uint]int
+	files   []RecordedFile
+	mixer   *mixer
+	mix     *mixRecording
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// trackRecording is the recording of one published audio track
+type trackRecording struct {
+	userID uint
+	name   string
+	out    *countingWriter
+	ogg    *oggwriter.OggWriter
+}
+
+// mixRecording is the Ogg/Opus file of the room mix
+type mixRecording struct {
+	out       *countingWriter
+	ogg       *oggwriter.OggWriter
+	encoder   *mixEncoder
+	timestamp uint32
+	seq       uint16
+}
+
+// countingWriter counts the bytes written to a file
+type countingWriter struct {
+	io.WriteCloser
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// StartRecording starts recording the live SFU room. Participants are told
+// with a recording_started notice.
+func StartRecording(roomID, recordingID uint, sink RecordingSink) error {
+	roomsMux.Lock()
+	room, exists := rooms[roomID]
+	roomsMux.Unlock()
+	if !exists {
+		return ErrNoRoom
+	}
+
+	room.mux.Lock()
+	defer room.mux.Unlock()
+
+	if room.recording.Load() != nil {
+		return ErrAlreadyRecording
+	}
+
+	rec, err := newRecorder(recordingID, sink)
+	if err != nil {
+		return err
+	}
+	room.recording.Store(rec)
+	go rec.mixLoop()
+
+	for userID := range room.peers {
+		room.signal(userID, MsgRecordingStarted, rec.notice())
+	}
+
+	log.Printf("Recording %d of SFU room %d started", recordingID, roomID)
+	return nil
+}
+
+// StopRecording stops the room's recording, waits until its files are
+// written and tells the participants with a recording_stopped notice
+func StopRecording(roomID uint) (RecordingResult, error) {
+	roomsMux.Lock()
+	room, exists := rooms[roomID]
+	roomsMux.Unlock()
+	if !exists {
+		return RecordingResult{}, ErrNotRecording
+	}
+
+	rec := room.recording.Swap(nil)
+	if rec == nil {
+		return RecordingResult{}, ErrNotRecording
+	}
+	result := rec.finish()
+
+	room.mux.Lock()
+	for userID := range room.peers {
+		room.signal(userID, MsgRecordingStopped, rec.notice())
+	}
+	room.mux.Unlock()
+
+	log.Printf("Recording %d of SFU room %d stopped after %s", rec.ID, roomID, result.Duration)
+	return result, nil
+}
+
+// StopAllRecordings stops every recording in progress, on shutdown
+func StopAllRecordings() {
+	roomsMux.Lock()
+	var recs []*recorder
+	for _, room := range rooms {
+		if rec := room.recording.Swap(nil); rec != nil {
+			recs = append(recs, rec)
+		}
+	}
+	roomsMux.Unlock()
+
+	for _, rec := range recs {
+		rec.finish()
+	}
+}
+
+// newRecorder opens the mix file of a recording
+func newRecorder(id uint, sink RecordingSink) (*recorder, error) {
+	rec := &recorder{
+		ID:      id,
+		sink:    sink,
+		started: time.Now(),
+		tracks:  make(map[*forwarder]*trackRecording),
+		counts:  make(map[uint]int),
+		mixer:   newMixer(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	out, err := sink.Create("mixed.ogg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mix file: %w", err)
+	}
+	mix := &mixRecording{out: &countingWriter{WriteCloser: out}}
+	if mix.ogg, err = oggwriter.NewWith(mix.out, mixSampleRate, 1); err != nil {
+		out.Close()
+		return nil, fmt.Errorf("failed to write mix file: %w", err)
+	}
+	if mix.encoder, err = newMixEncoder(); err != nil {
+		mix.ogg.Close()
+		return nil, err
+	}
+	rec.mix = mix
+	return rec, nil
+}
+
+// notice is the payload of the recording notices
+func (r *recorder) notice() map[string]interface{} {
+	return map[string]interface{}{
+		"recording_id": r.ID,
+		"started_at":   r.started.Unix(),
+	}
+}
+
+// write records an RTP packet of an audio track, opening the track's file on
+// its first packet
+func (r *recorder) write(f *forwarder, packet *rtp.Packet) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.stopped {
+		return
+	}
+	t, ok := r.tracks[f]
+	if !ok {
+		t = r.openTrackLocked(f)
+		r.tracks[f] = t
+	}
+	if t == nil {
+		return
+	}
+
+	if err := t.ogg.WriteRTP(packet); err != nil {
+		log.Printf("Failed to record packet of user %d: %v", t.userID, err)
+	}
+	r.mixer.write(f, packet.Payload)
+}
+
+// openTrackLocked creates the file of a newly recorded track. It returns nil
+// for tracks that can't be recorded. The caller must hold r.mux.
+func (r *recorder) openTrackLocked(f *forwarder) *trackRecording {
+	codec := f.remote.Codec()
+	if !strings.EqualFold(codec.MimeType, webrtc.MimeTypeOpus) {
+		return nil
+	}
+
+	userID := f.owner.UserID
+	r.counts[userID]++
+	t := &trackRecording{
+		userID: userID,
+		name:   fmt.Sprintf("user-%d-%d.ogg", userID, r.counts[userID]),
+	}
+
+	out, err := r.sink.Create(t.name)
+	if err != nil {
+		log.Printf("Failed to create recording of user %d: %v", userID, err)
+		return nil
+	}
+	t.out = &countingWriter{WriteCloser: out}
+
+	channels := codec.Channels
+	if channels == 0 {
+		channels = 2
+	}
+	if t.ogg, err = oggwriter.NewWith(t.out, codec.ClockRate, channels); err != nil {
+		out.Close()
+		log.Printf("Failed to write recording of user %d: %v", userID, err)
+		return nil
+	}
+	return t
+}
+
+// removeTrack closes the file of a track that ended
+func (r *recorder) removeTrack(f *forwarder) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if t := r.tracks[f]; t != nil {
+		r.closeTrackLocked(t)
+	}
+	delete(r.tracks, f)
+	r.mixer.removeTrack(f)
+}
+
+// closeTrackLocked finishes a track's file. The caller must hold r.mux.
+func (r *recorder) closeTrackLocked(t *trackRecording) {
+	if err := t.ogg.Close(); err != nil {
+		log.Printf("Failed to close recording of user %d: %v", t.userID, err)
+		return
+	}
+	r.files = append(r.files, RecordedFile{UserID: t.userID, Name: t.name, Size: t.out.n})
+}
+
+// mixLoop writes one frame of the room mix per interval until the recording stops
+func (r *recorder) mixLoop() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(mixInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.mixFrame()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// mixFrame appends the next frame of the tracks' mix to the mix file.
+// Silence is written when nobody is talking so the mix keeps real time.
+func (r *recorder) mixFrame() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.stopped {
+		return
+	}
+
+	data, err := r.mix.encoder.encode(r.mixer.next().sum)
+	if err != nil {
+		log.Printf("Failed to encode mix of recording %d: %v", r.ID, err)
+		return
+	}
+	r.mix.seq++
+	err = r.mix.ogg.WriteRTP(&rtp.Packet{
+		Header:  rtp.Header{SequenceNumber: r.mix.seq, Timestamp: r.mix.timestamp},
+		Payload: data,
+	})
+	if err != nil {
+		log.Printf("Failed to write mix of recording %d: %v", r.ID, err)
+	}
+	r.mix.timestamp += mixFrameSize
+}
+
+// finish stops the recording, closes all its files and hands the result to
+// the sink
+func (r *recorder) finish() RecordingResult {
+	r.mux.Lock()
+	if r.stopped {
+		r.mux.Unlock()
+		return RecordingResult{ID: r.ID}
+	}
+	r.stopped = true
+	r.mux.Unlock()
+
+	close(r.stop)
+	<-r.done
+
+	r.mux.Lock()
+	for _, t := range r.tracks {
+		if t != nil {
+			r.closeTrackLocked(t)
+		}
+	}
+	r.tracks = nil
+	if err := r.mix.ogg.Close(); err != nil {
+		log.Printf("Failed to close mix of recording %d: %v", r.ID, err)
+	} else {
+		r.files = append(r.files, RecordedFile{Name: "mixed.ogg", Size: r.mix.out.n})
+	}
+	result := RecordingResult{
+		ID:       r.ID,
+		Duration: time.Since(r.started),
+		Files:    r.files,
+	}
+	r.mux.Unlock()
+
+	r.sink.Finish(result)
+	return result
+}
@@ -0,0 +1,259 @@
+package sfu
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Aloys-y/chat-go/config"
+	"github.com/pion/interceptor"
+	"github.com/pion/webrtc/v4"
+)
+
+// Signaling message types the SFU sends to clients
+const (
+	MsgSubscribeOffer = "sfu_subscribe_offer"
+	MsgCandidate      = "sfu_candidate"
+)
+
+// Transports of a peer: clients offer their tracks on the publisher and the
+// server offers the other participants' tracks on the subscriber
+const (
+	TargetPublisher  = "publisher"
+	TargetSubscriber = "subscriber"
+)
+
+// SignalFunc delivers a server-originated signaling message to a user
+type SignalFunc func(userID uint, msgType string, payload interface{})
+
+// Candidate is a trickled ICE candidate for one of a peer's transports
+type Candidate struct {
+	Target    string                  `json:"target"`
+	Candidate webrtc.ICECandidateInit `json:"candidate"`
+}
+
+// Room forwards every track published by a participant to all the others
+type Room struct {
+	ID     uint
+	signal SignalFunc
+
+	mux    sync.Mutex
+	peers  map[uint]*Peer
+	tracks []*forwarder
+
+	// recording is the recorder of the room while it is being recorded
+	recording atomic.Pointer[recorder]
+	// broadcast mixes the room for listeners while it is being broadcast
+	broadcast atomic.Pointer[broadcaster]
+	// mcu mixes the room's audio while some peers receive it mixed
+	mcu atomic.Pointer[mcu]
+}
+
+var (
+	rooms    = make(map[uint]*Room)
+	roomsMux sync.Mutex
+
+	apiOnce sync.Once
+	api     *webrtc.API
+	apiErr  error
+)
+
+// API builds the WebRTC API shared by all server-side peer connections from
+// the SFU config
+func API() (*webrtc.API, error) {
+	apiOnce.Do(func() {
+		cfg := config.AppConfig.SFU
+
+		m := &webrtc.MediaEngine{}
+		if apiErr = m.RegisterDefaultCodecs(); apiErr != nil {
+			return
+		}
+		i := &interceptor.Registry{}
+		if apiErr = webrtc.RegisterDefaultInterceptors(m, i); apiErr != nil {
+			return
+		}
+
+		s := webrtc.SettingEngine{}
+		if cfg.PublicIP != "" {
+			s.SetNAT1To1IPs([]string{cfg.PublicIP}, webrtc.ICECandidateTypeHost)
+		}
+		if cfg.UDPMinPort != 0 && cfg.UDPMaxPort != 0 {
+			if apiErr = s.SetEphemeralUDPPortRange(cfg.UDPMinPort, cfg.UDPMaxPort); apiErr != nil {
+				apiErr = fmt.Errorf("invalid SFU port range: %w", apiErr)
+				return
+			}
+		}
+
+		api = webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(i), webrtc.WithSettingEngine(s))
+	})
+	return api, apiErr
+}
+
+// streamID is the media stream ID of the tracks forwarded for a user, so
+// subscribers can tell whose tracks they receive
+func streamID(userID uint) string {
+	return strconv.FormatUint(uint64(userID), 10)
+}
+
+// Join adds the user to the SFU room, creating the room on first use, and
+// subscribes them to the tracks already published. A previous peer of the
+// user in the room is replaced.
+func Join(roomID, userID uint, signal SignalFunc) (*Peer, error) {
+	api, err := API()
+	if err != nil {
+		return nil, err
+	}
+
+	roomsMux.Lock()
+	room, exists := rooms[roomID]
+	if !exists {
+		room = &Room{
+			ID:     roomID,
+			signal: signal,
+			peers:  make(map[uint]*Peer),
+		}
+		rooms[roomID] = room
+	}
+	room.mux.Lock()
+
+	old := room.peers[userID]
+	peer, err := newPeer(api, room, userID)
+	if err != nil {
+		if len(room.peers) == 0 {
+			delete(rooms, roomID)
+		}
+		room.mux.Unlock()
+		roomsMux.Unlock()
+		return nil, err
+	}
+	room.peers[userID] = peer
+
+	subscribed := false
+	for _, f := range room.tracks {
+		if f.owner.UserID != userID && peer.subscribe(f) {
+			subscribed = true
+		}
+	}
+	room.mux.Unlock()
+	roomsMux.Unlock()
+
+	if old != nil {
+		old.close()
+	}
+	if rec := room.recording.Load(); rec != nil {
+		room.signal(userID, MsgRecordingStarted, rec.notice())
+	}
+	if b := room.broadcast.Load(); b != nil {
+		room.signal(userID, MsgBroadcastStarted, b.notice())
+	}
+	if subscribed {
+		peer.negotiate()
+	}
+
+	log.Printf("User %d joined SFU room %d", userID, roomID)
+	return peer, nil
+}
+
+// Leave closes the user's peer in the SFU room. Its tracks stop being
+// forwarded and the room is dropped once empty, which ends its recording and
+// broadcast.
+func Leave(roomID, userID uint) {
+	roomsMux.Lock()
+	room, exists := rooms[roomID]
+	if !exists {
+		roomsMux.Unlock()
+		return
+	}
+	room.mux.Lock()
+	peer := room.peers[userID]
+	delete(room.peers, userID)
+	var rec *recorder
+	var b *broadcaster
+	if len(room.peers) == 0 {
+		delete(rooms, roomID)
+		rec = room.recording.Swap(nil)
+		b = room.broadcast.Swap(nil)
+	}
+	room.mux.Unlock()
+	roomsMux.Unlock()
+
+	if peer != nil {
+		peer.close()
+		log.Printf("User %d left SFU room %d", userID, roomID)
+	}
+
+	// The last participant left: end the recording and the broadcast
+	if rec != nil {
+		rec.finish()
+		log.Printf("Recording %d of SFU room %d stopped, room is empty", rec.ID, roomID)
+	}
+	if b != nil {
+		b.finish()
+		log.Printf("Broadcast of SFU room %d stopped, room is empty", roomID)
+	}
+}
+
+// GetPeer returns the user's peer in the SFU room, or nil
+func GetPeer(roomID, userID uint) *Peer {
+	roomsMux.Lock()
+	room, exists := rooms[roomID]
+	roomsMux.Unlock()
+
+	if !exists {
+		return nil
+	}
+
+	room.mux.Lock()
+	defer room.mux.Unlock()
+	return room.peers[userID]
+}
+
+// addTrack starts forwarding a newly published track to every other peer
+func (r *Room) addTrack(f *forwarder) {
+	r.mux.Lock()
+	r.tracks = append(r.tracks, f)
+	var subscribers []*Peer
+	for _, peer := range r.peers {
+		if peer != f.owner && peer.subscribe(f) {
+			subscribers = append(subscribers, peer)
+		}
+	}
+	r.mux.Unlock()
+
+	for _, peer := range subscribers {
+		peer.negotiate()
+	}
+}
+
+// removeTrack stops forwarding a track that ended
+func (r *Room) removeTrack(f *forwarder) {
+	r.mux.Lock()
+	for i, t := range r.tracks {
+		if t == f {
+			r.tracks = append(r.tracks[:i], r.tracks[i+1:]...)
+			break
+		}
+	}
+	var subscribers []*Peer
+	if rec := r.recording.Load(); rec != nil {
+		rec.removeTrack(f)
+	}
+	if b := r.broadcast.Load(); b != nil {
+		b.mixer.removeTrack(f)
+	}
+	if m := r.mcu.Load(); m != nil {
+		m.mixer.removeTrack(f)
+	}
+	for _, peer := range r.peers {
+		if peer.unsubscribe(f) {
+			subscribers = append(subscribers, peer)
+		}
+	}
+	r.mux.Unlock()
+
+	for _, peer := range subscribers {
+		peer.negotiate()
+	}
+}
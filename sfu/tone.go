@@ -0,0 +1,50 @@
+package sfu
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/jj11hh/opus"
+)
+
+// MinToneFrequency and MaxToneFrequency bound the tones ToneFrames
+// generates, in Hz
+const (
+	MinToneFrequency = 20
+	MaxToneFrequency = 20000
+)
+
+// toneAmplitude is the peak of generated tones, about -12 dBFS
+const toneAmplitude = 8192
+
+// ToneFrames encodes one second of a sine tone into 20ms Opus frames. A whole
+// number of periods fits in a second, so the frames can be played in a loop.
+func ToneFrames(frequency int) ([][]byte, error) {
+	if frequency < MinToneFrequency || frequency > MaxToneFrequency {
+		return nil, fmt.Errorf("tone frequency must be between %d and %d Hz", MinToneFrequency, MaxToneFrequency)
+	}
+
+	opusMux.Lock()
+	defer opusMux.Unlock()
+
+	encoder, err := opus.NewEncoder(mixSampleRate, 1, opus.AppAudio)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tone encoder: %w", err)
+	}
+
+	frames := make([][]byte, 0, mixSampleRate/mixFrameSize)
+	pcm := make([]int16, mixFrameSize)
+	for start := 0; start < mixSampleRate; start += mixFrameSize {
+		for i := range pcm {
+			t := float64(start+i) / mixSampleRate
+			pcm[i] = int16(toneAmplitude * math.Sin(2*math.Pi*float64(frequency)*t))
+		}
+		data := make([]byte, 1500)
+		n, err := encoder.Encode(pcm, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode tone: %w", err)
+		}
+		frames = append(frames, data[:n])
+	}
+	return frames, nil
+}
@@ -0,0 +1,162 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// speakingInterval is the minimum time between two speaking updates of a
+// participant; faster changes are coalesced into the latest state
+const speakingInterval = 300 * time.Millisecond
+
+// handleMuteState records whether the client's microphone is muted and tells
+// the room with participant_updated
+func (c *Client) handleMuteState(msg Message) error {
+	room, err := c.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	var state struct {
+		Muted *bool `json:"muted"`
+	}
+	if err := json.Unmarshal(msg.Payload, &state); err != nil {
+		return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal mute state: %v", err)
+	}
+	if state.Muted == nil {
+		return newProtocolError(ErrCodeInvalidPayload, "muted is required")
+	}
+
+	room.Mux.Lock()
+	defer room.Mux.Unlock()
+
+	p, ok := room.Participants[c.UserID]
+	if !ok {
+		return newProtocolError(ErrCodeNotInRoom, "client %d not in room %d", c.UserID, room.ID)
+	}
+	if p.Muted == *state.Muted {
+		return nil
+	}
+
+	p.Muted = *state.Muted
+	if p.Muted {
+		p.Speaking = false
+	}
+	room.updateParticipantLocked(p)
+	return nil
+}
+
+// handleSpeaking records whether the client is talking. Updates arriving
+// within speakingInterval of the previous one are held back and only the
+// latest state is applied when the interval ends.
+func (c *Client) handleSpeaking(msg Message) error {
+	if _, err := c.currentRoom(); err != nil {
+		return err
+	}
+
+	var state struct {
+		Speaking bool `json:"speaking"`
+	}
+	if err := json.Unmarshal(msg.Payload, &state); err != nil {
+		return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal speaking state: %v", err)
+	}
+
+	c.speakingMux.Lock()
+	defer c.speakingMux.Unlock()
+
+	if c.speakingTimer != nil {
+		c.speakingPending = state.Speaking
+		return nil
+	}
+	if wait := speakingInterval - time.Since(c.speakingAt); wait > 0 {
+		c.speakingPending = state.Speaking
+		c.speakingTimer = time.AfterFunc(wait, func() { c.do(c.flushSpeaking) })
+		return nil
+	}
+
+	c.speakingAt = time.Now()
+	c.setSpeaking(state.Speaking)
+	return nil
+}
+
+// flushSpeaking applies the speaking state held back by handleSpeaking
+func (c *Client) flushSpeaking() {
+	c.speakingMux.Lock()
+	defer c.speakingMux.Unlock()
+
+	c.speakingTimer = nil
+	c.speakingAt = time.Now()
+	c.setSpeaking(c.speakingPending)
+}
+
+// setSpeaking updates the participant's speaking state and broadcasts it. A
+// muted participant never counts as speaking. Speaking changes are too
+// frequent to version, so the speaking message carries no version.
+func (c *Client) setSpeaking(speaking bool) {
+	room, err := c.currentRoom()
+	if err != nil {
+		return
+	}
+
+	room.Mux.Lock()
+	defer room.Mux.Unlock()
+
+	p, ok := room.Participants[c.UserID]
+	if !ok {
+		return
+	}
+	if p.Muted {
+		speaking = false
+	}
+	if p.Speaking == speaking {
+		return
+	}
+
+	p.Speaking = speaking
+	room.storeParticipantLocked(p)
+
+	msg := Message{Type: "speaking", UserID: c.UserID, RoomID: room.ID}
+	msg.Payload, _ = json.Marshal(map[string]interface{}{
+		"user_id":  c.UserID,
+		"speaking": speaking,
+	})
+	room.publishLocked(delivery{Message: msg, Upsert: p.copy()})
+}
+
+// handleRequestMute lets a moderator ask another participant to mute. The
+// request is recorded and relayed to the participant as mute_requested; the
+// participant's client mutes and reports it with mute_state.
+func (c *Client) handleRequestMute(msg Message) error {
+	room, err := c.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		UserID uint `json:"user_id"`
+	}
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal mute request: %v", err)
+	}
+	if req.UserID == 0 {
+		return newProtocolError(ErrCodeInvalidPayload, "user_id is required")
+	}
+
+	room.Mux.RLock()
+	moderator := room.isModerator(c.UserID)
+	_, present := room.Participants[req.UserID]
+	room.Mux.RUnlock()
+
+	if !moderator {
+		return newProtocolError(ErrCodeForbidden, "only moderators can ask others to mute")
+	}
+	if !present {
+		return newProtocolError(ErrCodeTargetNotFound, "user %d is not in room %d", req.UserID, room.ID)
+	}
+
+	moderate(req.UserID, moderation{RoomID: room.ID, Action: actionRequestMute, ModeratorID: c.UserID})
+
+	log.Printf("Client %d asked user %d to mute in room %d", c.UserID, req.UserID, room.ID)
+	return nil
+}
@@ -0,0 +1,259 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Aloys-y/chat-go/auth"
+	"github.com/Aloys-y/chat-go/calls"
+	"github.com/Aloys-y/chat-go/config"
+	"github.com/Aloys-y/chat-go/models"
+)
+
+// defaultRingTimeout is how long a call rings before it is missed
+const defaultRingTimeout = 30 * time.Second
+
+// Reasons reported in call_ended
+const (
+	CallEndedDeclined    = "declined"
+	CallEndedCancelled   = "cancelled"
+	CallEndedTimeout     = "timeout"
+	CallEndedUnavailable = "unavailable"
+	CallEndedHangup      = "hangup"
+)
+
+// ringTimeout returns how long a call rings before it is missed
+func ringTimeout() time.Duration {
+	d, err := time.ParseDuration(config.AppConfig.Signaling.RingTimeout)
+	if err != nil || d <= 0 {
+		return defaultRingTimeout
+	}
+	return d
+}
+
+// callRequest is the payload of call_invite, call_accept, call_decline and
+// call_cancel
+type callRequest struct {
+	CallID   uint `json:"call_id"`
+	CalleeID uint `json:"callee_id"`
+	Video    bool `json:"video"`
+}
+
+// callError maps a call failure to a protocol error
+func callError(callID uint, err error) error {
+	switch {
+	case errors.Is(err, calls.ErrCallNotFound):
+		return newProtocolError(ErrCodeTargetNotFound, "call %d does not exist", callID)
+	case errors.Is(err, calls.ErrNotRinging):
+		return newProtocolError(ErrCodeCallNotRinging, "call %d is no longer ringing", callID)
+	default:
+		return err
+	}
+}
+
+// handleCallInvite rings every connected device of the callee. The call is
+// missed when nobody answers within the ring timeout.
+func (c *Client) handleCallInvite(msg Message) error {
+	var req callRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal call invite: %v", err)
+	}
+	if req.CalleeID == 0 || req.CalleeID == c.UserID {
+		return newProtocolError(ErrCodeInvalidPayload, "callee_id must be another user")
+	}
+	if _, err := auth.GetUserByID(req.CalleeID); err != nil {
+		return newProtocolError(ErrCodeTargetNotFound, "user %d does not exist", req.CalleeID)
+	}
+
+	call, err := calls.Start(c.UserID, req.CalleeID, req.Video)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	nodes, err := presence.UserNodes(ctx, req.CalleeID)
+	if err != nil {
+		log.Printf("Failed to look up nodes of user %d: %v", req.CalleeID, err)
+	}
+	if len(nodes) == 0 {
+		endRinging(call, models.CallMissed, CallEndedUnavailable)
+		return nil
+	}
+
+	ringing := Message{Type: "call_ringing", UserID: c.UserID}
+	ringing.Payload, _ = json.Marshal(map[string]interface{}{
+		"call_id":   call.ID,
+		"callee_id": call.CalleeID,
+		"video":     call.Video,
+	})
+	c.sendMessage(ringing)
+
+	invite := Message{Type: "call_invite", UserID: c.UserID}
+	invite.Payload, _ = json.Marshal(map[string]interface{}{
+		"call_id":     call.ID,
+		"caller_id":   c.UserID,
+		"caller_name": c.displayName(),
+		"video":       call.Video,
+		"expires_at":  call.StartedAt.Add(ringTimeout()),
+	})
+	sendToDevices(call.CalleeID, invite)
+
+	time.AfterFunc(ringTimeout(), func() {
+		endRinging(call, models.CallMissed, CallEndedTimeout)
+	})
+
+	log.Printf("Client %d calling %d (call %d)", c.UserID, call.CalleeID, call.ID)
+	return nil
+}
+
+// handleCallAccept answers a ringing call on one of the callee's devices.
+// Both users are told the ad-hoc room to join.
+func (c *Client) handleCallAccept(msg Message) error {
+	call, err := c.loadCall(msg, false)
+	if err != nil {
+		return err
+	}
+
+	answered, room, err := calls.Answer(call.ID)
+	if err != nil {
+		return callError(call.ID, err)
+	}
+	call = answered
+
+	accepted := Message{Type: "call_accepted", UserID: c.UserID}
+	accepted.Payload, _ = json.Marshal(map[string]interface{}{
+		"call_id": call.ID,
+		"room_id": room.ID,
+		"video":   call.Video,
+	})
+	sendToDevices(call.CallerID, accepted)
+	sendToDevices(call.CalleeID, accepted)
+
+	log.Printf("Call %d answered by %d in room %d", call.ID, c.UserID, room.ID)
+	return nil
+}
+
+// handleCallDecline lets the callee turn a ringing call down
+func (c *Client) handleCallDecline(msg Message) error {
+	call, err := c.loadCall(msg, false)
+	if err != nil {
+		return err
+	}
+	if err := calls.Finish(call.ID, models.CallDeclined); err != nil {
+		return callError(call.ID, err)
+	}
+	notifyCallEnded(call, CallEndedDeclined)
+	return nil
+}
+
+// handleCallCancel lets the caller hang up before the call is answered
+func (c *Client) handleCallCancel(msg Message) error {
+	call, err := c.loadCall(msg, true)
+	if err != nil {
+		return err
+	}
+	if err := calls.Finish(call.ID, models.CallMissed); err != nil {
+		return callError(call.ID, err)
+	}
+	notifyCallEnded(call, CallEndedCancelled)
+	return nil
+}
+
+// loadCall loads the call named in the message, which the client must have
+// placed (asCaller) or received
+func (c *Client) loadCall(msg Message, asCaller bool) (*models.CallLog, error) {
+	var req callRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return nil, newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal %s: %v", msg.Type, err)
+	}
+	if req.CallID == 0 {
+		return nil, newProtocolError(ErrCodeInvalidPayload, "call_id is required")
+	}
+
+	call, err := calls.Get(req.CallID)
+	if err != nil {
+		return nil, callError(req.CallID, err)
+	}
+	if (asCaller && call.CallerID != c.UserID) || (!asCaller && call.CalleeID != c.UserID) {
+		return nil, newProtocolError(ErrCodeForbidden, "call %d is not yours to %s", call.ID, msg.Type)
+	}
+	return call, nil
+}
+
+// endRinging ends the call as missed unless it was answered or ended already
+func endRinging(call *models.CallLog, status, reason string) {
+	if err := calls.Finish(call.ID, status); err != nil {
+		if !errors.Is(err, calls.ErrNotRinging) {
+			log.Printf("Failed to end call %d: %v", call.ID, err)
+		}
+		return
+	}
+	notifyCallEnded(call, reason)
+}
+
+// notifyCallEnded tells every device of both users that the call is over
+func notifyCallEnded(call *models.CallLog, reason string) {
+	ended := Message{Type: "call_ended"}
+	ended.Payload, _ = json.Marshal(map[string]interface{}{
+		"call_id": call.ID,
+		"reason":  reason,
+	})
+	sendToDevices(call.CallerID, ended)
+	sendToDevices(call.CalleeID, ended)
+
+	log.Printf("Call %d ended: %s", call.ID, reason)
+}
+
+// hangUp ends the answered call whose room the user left, removing the other
+// user from the room as well
+func hangUp(callID, roomID uint) {
+	ended, err := calls.End(callID)
+	if err != nil {
+		log.Printf("Failed to end call %d: %v", callID, err)
+	}
+	if !ended {
+		return
+	}
+
+	call, err := calls.Get(callID)
+	if err != nil {
+		log.Printf("Failed to load call %d: %v", callID, err)
+		return
+	}
+	notifyCallEnded(call, CallEndedHangup)
+	CloseRoom(roomID, RemovalCallEnded)
+}
+
+// displayName returns the name the client's user is shown with
+func (c *Client) displayName() string {
+	if c.User == nil {
+		return ""
+	}
+	return c.User.DisplayName
+}
+
+// sendToDevices delivers a message to every session of the user, on all nodes
+func sendToDevices(userID uint, msg Message) {
+	deliverToDevices(userID, msg)
+	publish(userTopic(userID), delivery{Message: msg, AllDevices: true})
+}
+
+// deliverToDevices sends a message to the user's sessions on this node
+func deliverToDevices(userID uint, msg Message) {
+	sessionsMux.Lock()
+	var devices []*Client
+	for _, c := range sessions {
+		if c.UserID == userID {
+			devices = append(devices, c)
+		}
+	}
+	sessionsMux.Unlock()
+
+	for _, c := range devices {
+		c.sendMessage(msg)
+	}
+}
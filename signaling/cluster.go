@@ -0,0 +1,380 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Aloys-y/chat-go/calls"
+	"github.com/Aloys-y/chat-go/cluster"
+	"github.com/Aloys-y/chat-go/config"
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// clusterTimeout bounds every broker and presence call
+	clusterTimeout = 2 * time.Second
+
+	defaultHeartbeatInterval = 10 * time.Second
+)
+
+var (
+	// nodeID identifies this chat-go instance in the cluster
+	nodeID = cluster.NewNodeID()
+
+	broker   cluster.Broker
+	presence cluster.Presence = cluster.NewMemoryPresence()
+
+	stopHeartbeat chan struct{}
+
+	// userSessions counts the local sessions of each user. userNodesMux also
+	// keeps the presence updates of a user's first and last session in order.
+	userSessions = make(map[uint]int)
+	userNodesMux sync.Mutex
+)
+
+// delivery is what nodes exchange through the broker. Upsert and RemoveID
+// carry participant changes that remote nodes apply to their copy of the room;
+// Evict removes the receiving node's clients from a room instead of
+// delivering a message; Policy replaces the room's video policy, Codecs its
+// codec policy and Locked its lock; Moderate applies a moderator's action to the receiving node's
+// client. Lobby announces a lobby change and Admit lets waiting users in,
+// both without a message. AllDevices sends a user's message to every session
+// of the user rather than the latest one.
+type delivery struct {
+	NodeID     string              `json:"node_id"`
+	ExceptID   uint                `json:"except_id,omitempty"`
+	Upsert     *ParticipantState   `json:"upsert,omitempty"`
+	RemoveID   uint                `json:"remove_id,omitempty"`
+	Evict      *eviction           `json:"evict,omitempty"`
+	Moderate   *moderation         `json:"moderate,omitempty"`
+	Policy     *models.VideoPolicy `json:"policy,omitempty"`
+	Codecs     *models.CodecPolicy `json:"codecs,omitempty"`
+	Locked     *bool               `json:"locked,omitempty"`
+	Lobby      bool                `json:"lobby,omitempty"`
+	Admit      []uint              `json:"admit,omitempty"`
+	AllDevices bool                `json:"all_devices,omitempty"`
+	Message    Message             `json:"message"`
+}
+
+func init() {
+	// Single-node defaults until InitCluster is called
+	broker = cluster.NewMemoryBroker(handleDelivery)
+}
+
+// InitCluster sets up the broker and presence registry from the cluster config
+func InitCluster() error {
+	cfg := config.AppConfig.Cluster
+
+	if cfg.NodeID != "" {
+		nodeID = cfg.NodeID
+	}
+
+	switch cfg.Broker {
+	case "", "memory":
+		broker.Close()
+		broker = cluster.NewMemoryBroker(handleDelivery)
+		presence = cluster.NewMemoryPresence()
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+		defer cancel()
+		if err := client.Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+		broker.Close()
+		broker = cluster.NewRedisBroker(client, handleDelivery)
+		presence = cluster.NewRedisPresence(client)
+	default:
+		return fmt.Errorf("unknown cluster broker %q", cfg.Broker)
+	}
+
+	interval, err := time.ParseDuration(cfg.HeartbeatInterval)
+	if err != nil || interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	heartbeat(interval)
+	stopHeartbeat = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				heartbeat(interval)
+			case <-stop:
+				return
+			}
+		}
+	}(stopHeartbeat)
+
+	log.Printf("Cluster node %s started with %s broker", nodeID, cfg.Broker)
+	return nil
+}
+
+// CloseCluster removes this node from the presence registry and closes the broker
+func CloseCluster() {
+	if stopHeartbeat != nil {
+		close(stopHeartbeat)
+		stopHeartbeat = nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := presence.RemoveNode(ctx, nodeID); err != nil {
+		log.Printf("Failed to remove node %s from presence: %v", nodeID, err)
+	}
+	broker.Close()
+}
+
+// heartbeat keeps this node alive in the presence registry for three
+// intervals. The call session intervals of this node are kept open alongside,
+// and those of nodes whose heartbeat expired are closed.
+func heartbeat(interval time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := presence.Heartbeat(ctx, nodeID, 3*interval); err != nil {
+		log.Printf("Failed to send cluster heartbeat: %v", err)
+	}
+
+	now := time.Now()
+	if err := calls.TouchIntervals(nodeID, now); err != nil {
+		log.Printf("Failed to refresh call participants of node %s: %v", nodeID, err)
+	}
+	if err := calls.CloseStaleIntervals(now.Add(-3 * interval)); err != nil {
+		log.Printf("Failed to close call participants of stopped nodes: %v", err)
+	}
+}
+
+func roomTopic(roomID uint) string {
+	return fmt.Sprintf("room:%d", roomID)
+}
+
+func userTopic(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// subscribe starts receiving a topic from the other nodes
+func subscribe(topic string) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := broker.Subscribe(ctx, topic); err != nil {
+		log.Printf("Failed to subscribe to %s: %v", topic, err)
+	}
+}
+
+// unsubscribe stops receiving a topic from the other nodes
+func unsubscribe(topic string) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := broker.Unsubscribe(ctx, topic); err != nil {
+		log.Printf("Failed to unsubscribe from %s: %v", topic, err)
+	}
+}
+
+// publish sends a delivery to the other nodes subscribed to topic
+func publish(topic string, d delivery) {
+	d.NodeID = nodeID
+	data, err := json.Marshal(d)
+	if err != nil {
+		log.Printf("Failed to marshal delivery: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := broker.Publish(ctx, topic, data); err != nil {
+		log.Printf("Failed to publish to %s: %v", topic, err)
+	}
+}
+
+// handleDelivery receives deliveries published by other nodes
+func handleDelivery(topic string, data []byte) {
+	var d delivery
+	if err := json.Unmarshal(data, &d); err != nil {
+		log.Printf("Failed to unmarshal delivery on %s: %v", topic, err)
+		return
+	}
+	if d.NodeID == nodeID {
+		return
+	}
+
+	kind, idStr, _ := strings.Cut(topic, ":")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		log.Printf("Invalid topic %s", topic)
+		return
+	}
+
+	switch kind {
+	case "room":
+		if d.Evict != nil {
+			evictRoom(uint(id), d.Evict.Reason)
+			return
+		}
+		if len(d.Admit) > 0 {
+			admitLocal(uint(id), d.Admit)
+			return
+		}
+		if d.Lobby {
+			notifyLobby(uint(id))
+			return
+		}
+		deliverRemoteRoom(uint(id), d)
+	case "user":
+		if d.AllDevices {
+			deliverToDevices(uint(id), d.Message)
+			return
+		}
+		clientsMux.RLock()
+		client := clients[uint(id)]
+		clientsMux.RUnlock()
+		if client == nil {
+			return
+		}
+		if d.Evict != nil {
+			client.evict(d.Evict.RoomID, d.Evict.Reason)
+			return
+		}
+		if d.Moderate != nil {
+			client.applyModeration(*d.Moderate)
+			return
+		}
+		client.trackSignal(d.Message)
+		client.sendMessage(d.Message)
+	}
+}
+
+// deliverRemoteRoom applies a delivery from another node to the local copy of
+// the room and passes its message on to the local clients
+func deliverRemoteRoom(roomID uint, d delivery) {
+	roomsMux.RLock()
+	room, exists := rooms[roomID]
+	roomsMux.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	room.Mux.Lock()
+	defer room.Mux.Unlock()
+
+	if p := d.Upsert; p != nil {
+		if _, local := room.Clients[p.UserID]; !local {
+			cp := *p
+			room.Participants[p.UserID] = &cp
+		}
+	}
+	if d.RemoveID != 0 {
+		if _, local := room.Clients[d.RemoveID]; !local {
+			delete(room.Participants, d.RemoveID)
+		}
+	}
+	if d.Policy != nil {
+		room.VideoPolicy = *d.Policy
+	}
+	if d.Codecs != nil {
+		room.CodecPolicy = *d.Codecs
+	}
+	if d.Locked != nil {
+		room.Locked = *d.Locked
+	}
+	if d.Message.Version > room.Version {
+		room.Version = d.Message.Version
+	}
+
+	room.deliverLocked(d.Message, d.ExceptID)
+}
+
+// registerUserNode counts a new session of the user. The first one subscribes
+// to the user's direct topic and records that the user is connected to this
+// node.
+func registerUserNode(userID uint) {
+	userNodesMux.Lock()
+	defer userNodesMux.Unlock()
+
+	userSessions[userID]++
+	if userSessions[userID] > 1 {
+		return
+	}
+	subscribe(userTopic(userID))
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := presence.AddUserNode(ctx, userID, nodeID); err != nil {
+		log.Printf("Failed to record presence of user %d: %v", userID, err)
+	}
+}
+
+// unregisterUserNode undoes registerUserNode once the user's last session on
+// this node ended
+func unregisterUserNode(userID uint) {
+	userNodesMux.Lock()
+	defer userNodesMux.Unlock()
+
+	userSessions[userID]--
+	if userSessions[userID] > 0 {
+		return
+	}
+	delete(userSessions, userID)
+	unsubscribe(userTopic(userID))
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := presence.RemoveUserNode(ctx, userID, nodeID); err != nil {
+		log.Printf("Failed to remove presence of user %d: %v", userID, err)
+	}
+}
+
+// NotifyRoom sends a message to every participant of the live room, on all nodes
+func NotifyRoom(roomID uint, msgType string, payload interface{}) {
+	msg := Message{Type: msgType, RoomID: roomID}
+	msg.Payload, _ = json.Marshal(payload)
+
+	d := delivery{Message: msg}
+	publish(roomTopic(roomID), d)
+	deliverRemoteRoom(roomID, d)
+}
+
+// LiveParticipants lists the users in the live room, on all nodes
+func LiveParticipants(roomID uint) ([]uint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+
+	members, err := presence.RoomMembers(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make([]uint, 0, len(members))
+	for userID := range members {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+	return userIDs, nil
+}
+
+// sendToUser delivers a message to the user's session on this node, or
+// through the broker to the node holding it
+func sendToUser(userID uint, msg Message) {
+	clientsMux.RLock()
+	client := clients[userID]
+	clientsMux.RUnlock()
+
+	if client != nil {
+		client.trackSignal(msg)
+		client.sendMessage(msg)
+		return
+	}
+	publish(userTopic(userID), delivery{Message: msg})
+}
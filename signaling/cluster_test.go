@@ -0,0 +1,90 @@
+package signaling
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aloys-y/chat-go/cluster"
+	"github.com/gorilla/websocket"
+)
+
+// newTestClient creates a session of the user on a connection to a local
+// WebSocket server, the way handleWebSocket does
+func newTestClient(t *testing.T, userID uint, resumeToken string) *Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	c := &Client{
+		Conn:        conn,
+		UserID:      userID,
+		SendChan:    make(chan []byte, sendChanSize),
+		ResumeToken: resumeToken,
+		inbox:       make(chan []byte),
+		done:        make(chan struct{}),
+		wake:        make(chan struct{}, 1),
+	}
+	c.register()
+	go c.run()
+	t.Cleanup(c.close)
+	return c
+}
+
+func TestUserNodeOutlivesOneSession(t *testing.T) {
+	ctx := context.Background()
+	old := presence
+	presence = cluster.NewMemoryPresence()
+	t.Cleanup(func() { presence = old })
+	if err := presence.Heartbeat(ctx, nodeID, time.Minute); err != nil {
+		t.Fatalf("Heartbeat() failed: %v", err)
+	}
+
+	userNodes := func() []string {
+		nodes, err := presence.UserNodes(ctx, 7)
+		if err != nil {
+			t.Fatalf("UserNodes() failed: %v", err)
+		}
+		return nodes
+	}
+
+	// The user is connected from two devices; the newest one hangs up
+	phone := newTestClient(t, 7, "phone")
+	laptop := newTestClient(t, 7, "laptop")
+	laptop.close()
+
+	if got := userNodes(); !reflect.DeepEqual(got, []string{nodeID}) {
+		t.Fatalf("UserNodes() = %v after one session closed, want [%s]", got, nodeID)
+	}
+	clientsMux.RLock()
+	current := clients[7]
+	clientsMux.RUnlock()
+	if current != phone {
+		t.Errorf("direct messages don't go to the remaining session")
+	}
+
+	phone.close()
+	if got := userNodes(); len(got) != 0 {
+		t.Errorf("UserNodes() = %v after every session closed, want none", got)
+	}
+}
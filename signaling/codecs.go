@@ -0,0 +1,156 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/pion/sdp/v3"
+)
+
+// SetCodecPolicy applies a room's new codec policy to the live room on all
+// nodes and tells the participants with codec_policy
+func SetCodecPolicy(roomID uint, policy models.CodecPolicy) {
+	msg := Message{Type: "codec_policy", RoomID: roomID}
+	msg.Payload, _ = json.Marshal(policy)
+
+	d := delivery{Message: msg, Codecs: &policy}
+	publish(roomTopic(roomID), d)
+	deliverRemoteRoom(roomID, d)
+
+	log.Printf("Codec policy of room %d updated", roomID)
+}
+
+// checkOffer inspects the media sections a client offers to send against the
+// room's codec policy. Every sending section needs one of the preferred
+// codecs of its kind, and Opus must be set up as the policy asks.
+func checkOffer(offer string, policy models.CodecPolicy) error {
+	var desc sdp.SessionDescription
+	if err := desc.Unmarshal([]byte(offer)); err != nil {
+		return newProtocolError(ErrCodeInvalidPayload, "invalid SDP: %v", err)
+	}
+
+	for _, media := range desc.MediaDescriptions {
+		kind := media.MediaName.Media
+		if kind != "audio" && kind != "video" || !isSending(media) {
+			continue
+		}
+
+		codecs, params := offeredCodecs(media)
+		if preferred := preferredCodecs(policy, kind); len(preferred) > 0 && !offersAny(codecs, preferred) {
+			return newProtocolError(ErrCodePolicyViolation, "this room only accepts %s", strings.Join(preferred, ", "))
+		}
+		if kind != "audio" {
+			continue
+		}
+
+		if err := checkAudioBandwidth(media, policy); err != nil {
+			return err
+		}
+		for pt, codec := range codecs {
+			if strings.EqualFold(codec, "audio/opus") {
+				if err := checkOpus(params[pt], policy); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isSending reports whether the media section sends media, which is the
+// default when no direction is given
+func isSending(media *sdp.MediaDescription) bool {
+	for _, a := range media.Attributes {
+		switch a.Key {
+		case "recvonly", "inactive":
+			return false
+		}
+	}
+	return true
+}
+
+// offeredCodecs returns the MIME type and format parameters of each payload
+// type of the media section
+func offeredCodecs(media *sdp.MediaDescription) (map[string]string, map[string]map[string]string) {
+	codecs := make(map[string]string)
+	params := make(map[string]map[string]string)
+	for _, a := range media.Attributes {
+		pt, value, ok := strings.Cut(a.Value, " ")
+		if !ok {
+			continue
+		}
+		switch a.Key {
+		case "rtpmap":
+			name, _, _ := strings.Cut(value, "/")
+			codecs[pt] = media.MediaName.Media + "/" + name
+		case "fmtp":
+			fmtp := make(map[string]string)
+			for _, param := range strings.Split(value, ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+				fmtp[strings.ToLower(k)] = v
+			}
+			params[pt] = fmtp
+		}
+	}
+	return codecs, params
+}
+
+// preferredCodecs returns the codecs of the kind the policy prefers
+func preferredCodecs(policy models.CodecPolicy, kind string) []string {
+	var preferred []string
+	for _, codec := range policy.PreferredCodecs {
+		if strings.HasPrefix(codec, kind+"/") {
+			preferred = append(preferred, codec)
+		}
+	}
+	return preferred
+}
+
+// offersAny reports whether any offered codec is among the wanted ones
+func offersAny(codecs map[string]string, wanted []string) bool {
+	for _, codec := range codecs {
+		for _, w := range wanted {
+			if strings.EqualFold(codec, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkAudioBandwidth rejects an audio section whose bandwidth exceeds the
+// policy's audio bitrate limit
+func checkAudioBandwidth(media *sdp.MediaDescription, policy models.CodecPolicy) error {
+	limit := uint64(policy.MaxAudioBitrateKbps)
+	if limit == 0 {
+		return nil
+	}
+	for _, b := range media.Bandwidth {
+		if b.Type == "AS" && b.Bandwidth > limit || b.Type == "TIAS" && b.Bandwidth > limit*1000 {
+			return newProtocolError(ErrCodePolicyViolation, "audio is limited to %d kbps in this room", limit)
+		}
+	}
+	return nil
+}
+
+// checkOpus checks the Opus format parameters against the policy
+func checkOpus(fmtp map[string]string, policy models.CodecPolicy) error {
+	if limit := policy.MaxAudioBitrateKbps; limit > 0 {
+		if bps, err := strconv.Atoi(fmtp["maxaveragebitrate"]); err == nil && bps > limit*1000 {
+			return newProtocolError(ErrCodePolicyViolation, "audio is limited to %d kbps in this room", limit)
+		}
+	}
+	if !policy.Stereo && (fmtp["stereo"] == "1" || fmtp["sprop-stereo"] == "1") {
+		return newProtocolError(ErrCodePolicyViolation, "stereo audio is not allowed in this room")
+	}
+	if policy.OpusDTX && fmtp["usedtx"] != "1" {
+		return newProtocolError(ErrCodePolicyViolation, "this room requires Opus DTX (usedtx=1)")
+	}
+	if policy.OpusFEC && fmtp["useinbandfec"] != "1" {
+		return newProtocolError(ErrCodePolicyViolation, "this room requires Opus FEC (useinbandfec=1)")
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// Reasons the media keys of a room are rotated
+const (
+	RotateJoined = "joined"
+	RotateLeft   = "left"
+)
+
+const (
+	// maxE2EEPublicKeyLength bounds the public keys clients advertise
+	maxE2EEPublicKeyLength = 1024
+	// maxE2EEKeyLength bounds the encrypted media keys clients exchange
+	maxE2EEKeyLength = 4096
+)
+
+// e2eeKey is a participant's media key, encrypted by the sender for one
+// recipient. The server relays it without being able to read it.
+type e2eeKey struct {
+	Epoch      uint64 `json:"epoch"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// setE2EEPublicKey remembers the public key the client advertised when
+// joining; an empty key means the client doesn't encrypt its media
+func (c *Client) setE2EEPublicKey(key string) {
+	c.e2eeMux.Lock()
+	defer c.e2eeMux.Unlock()
+	c.e2eePublicKey = key
+}
+
+// e2eeKeyOf returns the client's advertised public key
+func (c *Client) e2eeKeyOf() string {
+	c.e2eeMux.Lock()
+	defer c.e2eeMux.Unlock()
+	return c.e2eePublicKey
+}
+
+// encryptedLocked reports whether media in the room is end-to-end encrypted,
+// because the room requires it or a participant encrypts. The caller must
+// hold room.Mux.
+func (r *Room) encryptedLocked() bool {
+	if r.E2EERequired {
+		return true
+	}
+	for _, p := range r.Participants {
+		if p.E2EEPublicKey != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// rotateKeysLocked tells the participants of an encrypted room to replace
+// their media keys after userID joined or left, so a newcomer can't decrypt
+// earlier media and a leaver can't decrypt later media. The room version
+// numbers the new keys. The caller must hold room.Mux.
+func (r *Room) rotateKeysLocked(userID uint, reason string) {
+	if !r.encryptedLocked() {
+		return
+	}
+
+	// The epoch is the version of the join or leave queued just before
+	r.queueLocked(roomUpdate{build: func(version uint64) delivery {
+		msg := Message{Type: "e2ee_rotate", RoomID: r.ID}
+		msg.Payload, _ = json.Marshal(map[string]interface{}{
+			"epoch":   version,
+			"reason":  reason,
+			"user_id": userID,
+		})
+		log.Printf("Rotating media keys of room %d at epoch %d, user %d %s", r.ID, version, userID, reason)
+		return delivery{Message: msg}
+	}})
+}
+
+// RoomEncrypted reports whether media in the live room is end-to-end
+// encrypted, so the server can't decode it
+func RoomEncrypted(roomID uint) bool {
+	roomsMux.RLock()
+	room, exists := rooms[roomID]
+	roomsMux.RUnlock()
+
+	if !exists {
+		return false
+	}
+
+	room.Mux.RLock()
+	defer room.Mux.RUnlock()
+	return room.encryptedLocked()
+}
+
+// participantPublicKey returns the public key a participant of the room
+// advertised, or "" when it doesn't encrypt or isn't in the room
+func participantPublicKey(roomID, userID uint) string {
+	roomsMux.RLock()
+	room, exists := rooms[roomID]
+	roomsMux.RUnlock()
+
+	if !exists {
+		return ""
+	}
+
+	room.Mux.RLock()
+	defer room.Mux.RUnlock()
+	if p, ok := room.Participants[userID]; ok {
+		return p.E2EEPublicKey
+	}
+	return ""
+}
+
+// handleE2EEKey relays the client's encrypted media key to target_id. Both
+// must have advertised a public key when joining.
+func (c *Client) handleE2EEKey(msg Message) error {
+	if c.RoomID == 0 {
+		return newProtocolError(ErrCodeNotInRoom, "client %d not in any room", c.UserID)
+	}
+	if c.e2eeKeyOf() == "" {
+		return newProtocolError(ErrCodeE2EERequired, "join with an e2ee_public_key to exchange media keys")
+	}
+	if msg.TargetID == 0 {
+		return newProtocolError(ErrCodeInvalidPayload, "target_id is required")
+	}
+
+	var key e2eeKey
+	if err := json.Unmarshal(msg.Payload, &key); err != nil {
+		return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal key: %v", err)
+	}
+	if key.Ciphertext == "" || len(key.Ciphertext) > maxE2EEKeyLength {
+		return newProtocolError(ErrCodeInvalidPayload, "ciphertext must be 1 to %d characters", maxE2EEKeyLength)
+	}
+
+	if !isRoomParticipant(c.RoomID, msg.TargetID) {
+		return newProtocolError(ErrCodeTargetNotFound, "user %d is not in room %d", msg.TargetID, c.RoomID)
+	}
+	if participantPublicKey(c.RoomID, msg.TargetID) == "" {
+		return newProtocolError(ErrCodeE2EERequired, "user %d does not encrypt its media", msg.TargetID)
+	}
+
+	relay := Message{Type: "e2ee_key", UserID: c.UserID, RoomID: c.RoomID}
+	relay.Payload, _ = json.Marshal(key)
+	sendToUser(msg.TargetID, relay)
+	return nil
+}
@@ -0,0 +1,370 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/Aloys-y/chat-go/membership"
+	"github.com/Aloys-y/chat-go/models"
+)
+
+// LobbyEntry is a user waiting to enter a full or locked room
+type LobbyEntry struct {
+	UserID       uint      `json:"user_id"`
+	UserName     string    `json:"user_name"`
+	WaitingSince time.Time `json:"waiting_since"`
+}
+
+// mustWaitLocked reports whether a user joining the room has to wait in the
+// lobby: the room is locked or full, or others are already queued. The
+// moderator never waits. The caller must hold room.Mux.
+func (r *Room) mustWaitLocked(userID uint, lobby []LobbyEntry) bool {
+	if r.isModerator(userID) {
+		return false
+	}
+	return r.Locked || r.freeSeatsLocked() == 0 || len(lobby) > 0
+}
+
+// freeSeatsLocked returns how many more participants fit in the room, or -1
+// when it has no limit. The caller must hold room.Mux.
+func (r *Room) freeSeatsLocked() int {
+	if r.MaxParticipants <= 0 {
+		return -1
+	}
+	if free := r.MaxParticipants - len(r.Participants); free > 0 {
+		return free
+	}
+	return 0
+}
+
+// roomLobby returns the users waiting to enter the room on all nodes, in the
+// order they arrived. It reads the presence registry, so callers load the
+// lobby before locking the room.
+func roomLobby(roomID uint) []LobbyEntry {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+
+	members, err := presence.LobbyMembers(ctx, roomID)
+	if err != nil {
+		log.Printf("Failed to load lobby of room %d: %v", roomID, err)
+		return nil
+	}
+
+	lobby := make([]LobbyEntry, 0, len(members))
+	for _, data := range members {
+		var e LobbyEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			continue
+		}
+		lobby = append(lobby, e)
+	}
+	sort.Slice(lobby, func(i, j int) bool {
+		if !lobby[i].WaitingSince.Equal(lobby[j].WaitingSince) {
+			return lobby[i].WaitingSince.Before(lobby[j].WaitingSince)
+		}
+		return lobby[i].UserID < lobby[j].UserID
+	})
+	return lobby
+}
+
+// waitLocked puts the client in the room's lobby until a moderator admits it
+// or a seat frees up. The caller must hold room.Mux.
+func (r *Room) waitLocked(c *Client, info *models.Room) {
+	entry := LobbyEntry{
+		UserID:       c.UserID,
+		WaitingSince: time.Now(),
+	}
+	if c.User != nil {
+		entry.UserName = c.User.DisplayName
+	}
+
+	c.RoomID = 0
+	c.lobby = info
+	r.Waiting[c.UserID] = c
+
+	data, _ := json.Marshal(entry)
+	userID := c.UserID
+	r.queueLocked(roomUpdate{store: func(ctx context.Context) error {
+		return presence.PutLobbyMember(ctx, r.ID, userID, nodeID, data)
+	}})
+
+	r.lobbyChangedLocked()
+	log.Printf("Client %d waiting in the lobby of room %d", c.UserID, r.ID)
+}
+
+// removeWaiterLocked takes the client out of the room's lobby. The caller
+// must hold room.Mux.
+func (r *Room) removeWaiterLocked(userID uint) bool {
+	if _, ok := r.Waiting[userID]; !ok {
+		return false
+	}
+	delete(r.Waiting, userID)
+
+	r.queueLocked(roomUpdate{store: func(ctx context.Context) error {
+		return presence.RemoveLobbyMember(ctx, r.ID, userID)
+	}})
+	return true
+}
+
+// lobbyChangedLocked tells every node hosting the room, this one included,
+// that its lobby changed once the changes queued so far are stored. The
+// caller must hold room.Mux.
+func (r *Room) lobbyChangedLocked() {
+	r.queueLocked(roomUpdate{
+		build: func(uint64) delivery { return delivery{Lobby: true} },
+		lobby: true,
+	})
+}
+
+// notifyLobbyLocked sends the local waiting clients their queue position and
+// the local moderators the waiting list. The caller must hold room.Mux.
+func (r *Room) notifyLobbyLocked(lobby []LobbyEntry) {
+	reason := "full"
+	if r.Locked {
+		reason = "locked"
+	}
+	for i, e := range lobby {
+		c := r.Waiting[e.UserID]
+		if c == nil {
+			continue
+		}
+		position := Message{Type: "lobby_position", RoomID: r.ID}
+		position.Payload, _ = json.Marshal(map[string]interface{}{
+			"room_id":  r.ID,
+			"position": i + 1,
+			"waiting":  len(lobby),
+			"reason":   reason,
+		})
+		c.sendMessage(position)
+	}
+
+	for userID, c := range r.Clients {
+		if r.isModerator(userID) {
+			c.sendMessage(lobbyMessage(r.ID, lobby))
+		}
+	}
+}
+
+// lobbyMessage lists the waiting users for moderators
+func lobbyMessage(roomID uint, lobby []LobbyEntry) Message {
+	msg := Message{Type: "lobby_updated", RoomID: roomID}
+	msg.Payload, _ = json.Marshal(map[string]interface{}{
+		"room_id": roomID,
+		"waiting": lobby,
+	})
+	return msg
+}
+
+// vacanciesLocked picks the waiting users of the lobby to let in now that
+// seats may have freed up, unless the room is locked. The caller must hold
+// room.Mux.
+func (r *Room) vacanciesLocked(lobby []LobbyEntry) []uint {
+	if r.Locked {
+		return nil
+	}
+	free := r.freeSeatsLocked()
+	if free == 0 {
+		return nil
+	}
+
+	if free > 0 && free < len(lobby) {
+		lobby = lobby[:free]
+	}
+	userIDs := make([]uint, 0, len(lobby))
+	for _, e := range lobby {
+		userIDs = append(userIDs, e.UserID)
+	}
+	return userIDs
+}
+
+// admit lets waiting users into the room, on whichever nodes hold them
+func admit(roomID uint, userIDs []uint) {
+	publish(roomTopic(roomID), delivery{Admit: userIDs})
+	admitLocal(roomID, userIDs)
+}
+
+// admitLocal moves the local clients among userIDs from the lobby into the
+// room
+func admitLocal(roomID uint, userIDs []uint) {
+	roomsMux.RLock()
+	room, exists := rooms[roomID]
+	roomsMux.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	room.Mux.Lock()
+	var admitted []*Client
+	for _, userID := range userIDs {
+		c := room.Waiting[userID]
+		if c != nil && room.removeWaiterLocked(userID) {
+			admitted = append(admitted, c)
+		}
+	}
+	if len(admitted) > 0 {
+		room.lobbyChangedLocked()
+	}
+	room.Mux.Unlock()
+
+	for _, c := range admitted {
+		c.enterFromLobby(roomID)
+	}
+}
+
+// enterFromLobby joins the room the client was admitted to, on the session's
+// goroutine, unless the client has left its lobby in the meantime
+func (c *Client) enterFromLobby(roomID uint) {
+	c.do(func() {
+		info := c.lobby
+		if info == nil || info.ID != roomID {
+			return
+		}
+		c.lobby = nil
+		c.RoomID = roomID
+		c.joinRoom(info, true)
+	})
+}
+
+// notifyLobby passes a lobby change from another node on to the local clients
+func notifyLobby(roomID uint) {
+	roomsMux.RLock()
+	room, exists := rooms[roomID]
+	roomsMux.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	lobby := roomLobby(roomID)
+	room.Mux.Lock()
+	defer room.Mux.Unlock()
+	room.notifyLobbyLocked(lobby)
+}
+
+// leaveLobby takes the client out of the lobby it is waiting in, if any
+func (c *Client) leaveLobby() {
+	info := c.lobby
+	if info == nil {
+		return
+	}
+	c.lobby = nil
+
+	roomsMux.RLock()
+	room, exists := rooms[info.ID]
+	roomsMux.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	room.Mux.Lock()
+	if room.removeWaiterLocked(c.UserID) {
+		room.lobbyChangedLocked()
+	}
+	room.Mux.Unlock()
+
+	dropIfEmpty(room)
+	log.Printf("Client %d left the lobby of room %d", c.UserID, info.ID)
+}
+
+// handleAdmit lets one waiting user (admit) or as many as fit (admit_all)
+// into the room. Moderator only.
+func (c *Client) handleAdmit(msg Message) error {
+	room, err := c.currentRoom()
+	if err != nil {
+		return err
+	}
+	if !room.isModerator(c.UserID) {
+		return newProtocolError(ErrCodeForbidden, "only the moderator can admit users to room %d", room.ID)
+	}
+
+	var req struct {
+		UserID uint `json:"user_id"`
+	}
+	if msg.Type == "admit" {
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal admit: %v", err)
+		}
+		if req.UserID == 0 {
+			return newProtocolError(ErrCodeInvalidPayload, "user_id is required")
+		}
+	}
+
+	lobby := roomLobby(room.ID)
+	room.Mux.RLock()
+	free := room.freeSeatsLocked()
+	room.Mux.RUnlock()
+
+	var userIDs []uint
+	for _, e := range lobby {
+		if msg.Type == "admit_all" || e.UserID == req.UserID {
+			userIDs = append(userIDs, e.UserID)
+		}
+	}
+	if msg.Type == "admit" && len(userIDs) == 0 {
+		return newProtocolError(ErrCodeTargetNotFound, "user %d is not waiting for room %d", req.UserID, room.ID)
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+	if free == 0 {
+		return newProtocolError(ErrCodeRoomFull, "room %d is full", room.ID)
+	}
+	if free > 0 && free < len(userIDs) {
+		userIDs = userIDs[:free]
+	}
+
+	admit(room.ID, userIDs)
+	return nil
+}
+
+// handleLockRoom locks the room, sending everyone who joins after to the
+// lobby, or unlocks it and lets the waiting users in as far as seats allow.
+// Moderator only.
+func (c *Client) handleLockRoom(msg Message) error {
+	room, err := c.currentRoom()
+	if err != nil {
+		return err
+	}
+	if !room.isModerator(c.UserID) {
+		return newProtocolError(ErrCodeForbidden, "only the moderator can lock room %d", room.ID)
+	}
+
+	var req struct {
+		Locked *bool `json:"locked"`
+	}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal lock room: %v", err)
+		}
+	}
+	locked := req.Locked == nil || *req.Locked
+
+	if err := membership.SetLocked(room.ID, locked); err != nil {
+		return err
+	}
+
+	lobby := roomLobby(room.ID)
+	room.Mux.Lock()
+	room.Locked = locked
+	lockMsg := Message{Type: "room_locked", UserID: c.UserID, RoomID: room.ID}
+	lockMsg.Payload, _ = json.Marshal(map[string]interface{}{
+		"locked":       locked,
+		"moderator_id": c.UserID,
+	})
+	room.publishLocked(delivery{Message: lockMsg, Locked: &locked})
+	room.lobbyChangedLocked()
+	userIDs := room.vacanciesLocked(lobby)
+	room.Mux.Unlock()
+
+	if len(userIDs) > 0 {
+		admit(room.ID, userIDs)
+	}
+
+	log.Printf("Room %d locked=%t by %d", room.ID, locked, c.UserID)
+	return nil
+}
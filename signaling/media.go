@@ -0,0 +1,214 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/pion/sdp/v3"
+)
+
+// Kinds of media tracks described in track_info
+const (
+	TrackKindAudio  = "audio"
+	TrackKindVideo  = "video"
+	TrackKindScreen = "screen"
+)
+
+// maxTracksPerParticipant bounds the tracks a participant may describe
+const maxTracksPerParticipant = 8
+
+// TrackInfo describes a media track a participant sends. Enabled is false
+// while the track is paused, e.g. with the camera turned off.
+type TrackInfo struct {
+	TrackID        string `json:"track_id"`
+	Kind           string `json:"kind"`
+	Codec          string `json:"codec,omitempty"`
+	Width          int    `json:"width,omitempty"`
+	Height         int    `json:"height,omitempty"`
+	FrameRate      int    `json:"frame_rate,omitempty"`
+	MaxBitrateKbps int    `json:"max_bitrate_kbps,omitempty"`
+	Enabled        bool   `json:"enabled"`
+}
+
+// checkTracks validates the tracks a participant describes against the
+// room's video policy
+func checkTracks(tracks []TrackInfo, policy models.VideoPolicy) error {
+	if len(tracks) > maxTracksPerParticipant {
+		return newProtocolError(ErrCodeInvalidPayload, "at most %d tracks can be described", maxTracksPerParticipant)
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range tracks {
+		if t.TrackID == "" {
+			return newProtocolError(ErrCodeInvalidPayload, "track_id is required")
+		}
+		if seen[t.TrackID] {
+			return newProtocolError(ErrCodeInvalidPayload, "track %q is described twice", t.TrackID)
+		}
+		seen[t.TrackID] = true
+
+		switch t.Kind {
+		case TrackKindAudio:
+		case TrackKindVideo, TrackKindScreen:
+			if err := checkVideoTrack(t, policy); err != nil {
+				return err
+			}
+		default:
+			return newProtocolError(ErrCodeInvalidPayload, "unknown track kind %q", t.Kind)
+		}
+	}
+	return nil
+}
+
+// checkVideoTrack checks a camera or screen track against the room's video
+// policy. Screen tracks are allowed while camera video is disabled, but are
+// held to the same resolution and bitrate limits.
+func checkVideoTrack(t TrackInfo, policy models.VideoPolicy) error {
+	if t.Kind == TrackKindVideo && policy.Disabled {
+		return newProtocolError(ErrCodePolicyViolation, "video is disabled in this room")
+	}
+	if policy.MaxWidth > 0 && t.Width > policy.MaxWidth ||
+		policy.MaxHeight > 0 && t.Height > policy.MaxHeight {
+		return newProtocolError(ErrCodePolicyViolation, "track %q is %dx%d, the room allows at most %dx%d",
+			t.TrackID, t.Width, t.Height, policy.MaxWidth, policy.MaxHeight)
+	}
+	if policy.MaxBitrateKbps > 0 && (t.MaxBitrateKbps == 0 || t.MaxBitrateKbps > policy.MaxBitrateKbps) {
+		return newProtocolError(ErrCodePolicyViolation, "track %q must be limited to %d kbps",
+			t.TrackID, policy.MaxBitrateKbps)
+	}
+	return nil
+}
+
+// checkOfferedVideo checks the video sections a client offers to send to the
+// SFU against the room's video policy, as checkTracks does for track_info.
+// Each section's track takes its kind and resolution from the client's
+// track_info, so video not described as a screen track counts as camera
+// video, and its bitrate limit from the section's bandwidth when given.
+func checkOfferedVideo(offer string, described []TrackInfo, policy models.VideoPolicy) error {
+	var desc sdp.SessionDescription
+	if err := desc.Unmarshal([]byte(offer)); err != nil {
+		return newProtocolError(ErrCodeInvalidPayload, "invalid SDP: %v", err)
+	}
+
+	tracks := make(map[string]TrackInfo, len(described))
+	for _, t := range described {
+		tracks[t.TrackID] = t
+	}
+
+	for _, media := range desc.MediaDescriptions {
+		if media.MediaName.Media != "video" || !isSending(media) {
+			continue
+		}
+
+		t, ok := tracks[offeredTrackID(media)]
+		if !ok || t.Kind != TrackKindScreen {
+			t.Kind = TrackKindVideo
+		}
+		if t.TrackID == "" {
+			t.TrackID = offeredTrackID(media)
+		}
+		if kbps := sectionBitrateKbps(media); kbps > 0 {
+			t.MaxBitrateKbps = kbps
+		}
+		if err := checkVideoTrack(t, policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// offeredTrackID returns the track ID a media section carries in its msid
+// attribute, or its mid when it has none
+func offeredTrackID(media *sdp.MediaDescription) string {
+	if msid, ok := media.Attribute("msid"); ok {
+		if fields := strings.Fields(msid); len(fields) == 2 {
+			return fields[1]
+		}
+	}
+	mid, _ := media.Attribute("mid")
+	return mid
+}
+
+// sectionBitrateKbps returns the bandwidth limit of a media section in kbps,
+// or 0 when it sets none
+func sectionBitrateKbps(media *sdp.MediaDescription) int {
+	for _, b := range media.Bandwidth {
+		switch b.Type {
+		case "AS":
+			return int(b.Bandwidth)
+		case "TIAS":
+			return int(b.Bandwidth / 1000)
+		}
+	}
+	return 0
+}
+
+// hasVideo reports whether any enabled camera track is described
+func hasVideo(tracks []TrackInfo) bool {
+	for _, t := range tracks {
+		if t.Kind == TrackKindVideo && t.Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// hasScreen reports whether any enabled screen track is described
+func hasScreen(tracks []TrackInfo) bool {
+	for _, t := range tracks {
+		if t.Kind == TrackKindScreen && t.Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// handleTrackInfo replaces the description of the tracks the client sends
+// and tells the room with participant_updated
+func (c *Client) handleTrackInfo(msg Message) error {
+	room, err := c.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	var info struct {
+		Tracks []TrackInfo `json:"tracks"`
+	}
+	if err := json.Unmarshal(msg.Payload, &info); err != nil {
+		return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal track info: %v", err)
+	}
+
+	room.Mux.Lock()
+	defer room.Mux.Unlock()
+
+	p, ok := room.Participants[c.UserID]
+	if !ok {
+		return newProtocolError(ErrCodeNotInRoom, "client %d not in room %d", c.UserID, room.ID)
+	}
+	if err := checkTracks(info.Tracks, room.VideoPolicy); err != nil {
+		return err
+	}
+	if hasScreen(info.Tracks) && !p.ScreenSharing {
+		return newProtocolError(ErrCodeForbidden, "request the floor with screen_share_start first")
+	}
+
+	p.Tracks = info.Tracks
+	p.VideoEnabled = hasVideo(info.Tracks)
+	room.updateParticipantLocked(p)
+	return nil
+}
+
+// SetVideoPolicy applies a room's new video policy to the live room on all
+// nodes and tells the participants with video_policy
+func SetVideoPolicy(roomID uint, policy models.VideoPolicy) {
+	msg := Message{Type: "video_policy", RoomID: roomID}
+	msg.Payload, _ = json.Marshal(policy)
+
+	d := delivery{Message: msg, Policy: &policy}
+	publish(roomTopic(roomID), d)
+	deliverRemoteRoom(roomID, d)
+
+	log.Printf("Video policy of room %d updated", roomID)
+}
@@ -0,0 +1,46 @@
+package signaling
+
+import (
+	"encoding/json"
+)
+
+// NotifyDevicesChanged tells the user's sessions and the users they
+// exchanged direct messages with that the user's device list changed
+func NotifyDevicesChanged(userID uint, deviceIDs []uint, contacts []uint) {
+	if deviceIDs == nil {
+		deviceIDs = []uint{}
+	}
+	msg := Message{Type: "devices_changed"}
+	msg.Payload, _ = json.Marshal(map[string]interface{}{
+		"user_id":    userID,
+		"device_ids": deviceIDs,
+	})
+
+	sendToDevices(userID, msg)
+	for _, peerID := range contacts {
+		sendToDevices(peerID, msg)
+	}
+}
+
+// NotifyEncryptedMessage tells the recipient's sessions that messages for
+// some of its devices are waiting to be fetched
+func NotifyEncryptedMessage(recipientID, senderID, senderDeviceID uint, deviceIDs []uint) {
+	msg := Message{Type: "encrypted_message"}
+	msg.Payload, _ = json.Marshal(map[string]interface{}{
+		"sender_id":        senderID,
+		"sender_device_id": senderDeviceID,
+		"device_ids":       deviceIDs,
+	})
+	sendToDevices(recipientID, msg)
+}
+
+// NotifyPrekeysLow asks the owner of a device to upload more one-time
+// prekeys
+func NotifyPrekeysLow(userID, deviceID uint, remaining int64) {
+	msg := Message{Type: "prekeys_low"}
+	msg.Payload, _ = json.Marshal(map[string]interface{}{
+		"device_id": deviceID,
+		"remaining": remaining,
+	})
+	sendToDevices(userID, msg)
+}
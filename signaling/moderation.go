@@ -0,0 +1,209 @@
+package signaling
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/Aloys-y/chat-go/membership"
+)
+
+// Reasons reported in removed_from_room
+const (
+	RemovalLeft        = "left"
+	RemovalKicked      = "kicked"
+	RemovalBanned      = "banned"
+	RemovalRoomDeleted = "room_deleted"
+	RemovalCallEnded   = "call_ended"
+)
+
+// eviction asks the nodes holding a room's connections to drop them from it
+type eviction struct {
+	RoomID uint   `json:"room_id"`
+	Reason string `json:"reason"`
+}
+
+// Moderator actions applied to a participant on the node holding their connection
+const (
+	actionRevokeScreenShare = "revoke_screen_share"
+	actionRequestMute       = "request_mute"
+	actionInviteToStage     = "invite_to_stage"
+	actionMoveToAudience    = "move_to_audience"
+	actionMoveToRoom        = "move_to_room"
+)
+
+// moderation is a moderator's action on a participant. TargetRoomID is the
+// room a participant is moved to.
+type moderation struct {
+	RoomID       uint   `json:"room_id"`
+	Action       string `json:"action"`
+	ModeratorID  uint   `json:"moderator_id"`
+	TargetRoomID uint   `json:"target_room_id,omitempty"`
+}
+
+// admissionError maps a membership refusal to a protocol error
+func admissionError(roomID uint, err error) error {
+	switch {
+	case errors.Is(err, membership.ErrRoomNotFound):
+		return newProtocolError(ErrCodeRoomNotFound, "room %d does not exist", roomID)
+	case errors.Is(err, membership.ErrNotMember):
+		return newProtocolError(ErrCodeForbidden, "room %d is private", roomID)
+	case errors.Is(err, membership.ErrBanned):
+		return newProtocolError(ErrCodeBanned, "banned from room %d", roomID)
+	default:
+		return err
+	}
+}
+
+// RemoveFromRoom takes the user out of the live room, on whichever node holds
+// their connection, after their membership was revoked
+func RemoveFromRoom(roomID, userID uint, reason string) {
+	clientsMux.RLock()
+	client := clients[userID]
+	clientsMux.RUnlock()
+
+	if client != nil {
+		client.evict(roomID, reason)
+		return
+	}
+	publish(userTopic(userID), delivery{Evict: &eviction{RoomID: roomID, Reason: reason}})
+}
+
+// CloseRoom removes every participant from the live room on all nodes
+func CloseRoom(roomID uint, reason string) {
+	publish(roomTopic(roomID), delivery{Evict: &eviction{RoomID: roomID, Reason: reason}})
+	evictRoom(roomID, reason)
+}
+
+// evictRoom removes every local client from the room
+func evictRoom(roomID uint, reason string) {
+	roomsMux.RLock()
+	room, exists := rooms[roomID]
+	roomsMux.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	room.Mux.RLock()
+	local := make([]*Client, 0, len(room.Clients)+len(room.Waiting))
+	for _, client := range room.Clients {
+		local = append(local, client)
+	}
+	for _, client := range room.Waiting {
+		local = append(local, client)
+	}
+	room.Mux.RUnlock()
+
+	for _, client := range local {
+		client.evict(roomID, reason)
+	}
+}
+
+// evict tells the client why it is being removed from the room, or from its
+// lobby, and leaves it on the session's goroutine
+func (c *Client) evict(roomID uint, reason string) {
+	c.do(func() {
+		waiting := c.lobby != nil && c.lobby.ID == roomID
+		if c.RoomID != roomID && !waiting {
+			return
+		}
+
+		msg := Message{Type: "removed_from_room", RoomID: roomID}
+		msg.Payload, _ = json.Marshal(map[string]interface{}{
+			"room_id": roomID,
+			"reason":  reason,
+		})
+		c.sendMessage(msg)
+		if waiting {
+			c.leaveLobby()
+		} else {
+			c.leaveRoom(roomID)
+		}
+
+		log.Printf("Client %d removed from room %d: %s", c.UserID, roomID, reason)
+	})
+}
+
+// MoveToRoom moves the user's live session from one room to another without
+// closing their connection, on whichever node holds it
+func MoveToRoom(userID, fromRoomID, toRoomID, movedBy uint) {
+	moderate(userID, moderation{
+		RoomID:       fromRoomID,
+		Action:       actionMoveToRoom,
+		ModeratorID:  movedBy,
+		TargetRoomID: toRoomID,
+	})
+}
+
+// moveToRoom leaves the client's room and joins another one, telling the
+// client with moved_to_room before the new room_state. It runs on the
+// session's goroutine.
+func (c *Client) moveToRoom(roomID uint) {
+	room, err := membership.GetRoom(roomID)
+	if err != nil {
+		log.Printf("Failed to move client %d to room %d: %v", c.UserID, roomID, err)
+		return
+	}
+
+	from := c.RoomID
+	c.leaveRoom(from)
+
+	moved := Message{Type: "moved_to_room", RoomID: roomID}
+	moved.Payload, _ = json.Marshal(map[string]interface{}{
+		"from_room_id": from,
+		"room_id":      roomID,
+	})
+	c.sendMessage(moved)
+
+	c.RoomID = roomID
+	c.joinRoom(room, true)
+}
+
+// moderate records a moderator's action and applies it to the participant, on
+// whichever node holds their connection
+func moderate(userID uint, m moderation) {
+	if err := membership.RecordAction(m.RoomID, m.ModeratorID, userID, m.Action); err != nil {
+		log.Printf("Failed to record %s of user %d in room %d: %v", m.Action, userID, m.RoomID, err)
+	}
+
+	clientsMux.RLock()
+	client := clients[userID]
+	clientsMux.RUnlock()
+
+	if client != nil {
+		client.applyModeration(m)
+		return
+	}
+	publish(userTopic(userID), delivery{Moderate: &m})
+}
+
+// applyModeration carries out a moderator's action on the client, on the
+// session's goroutine
+func (c *Client) applyModeration(m moderation) {
+	c.do(func() {
+		if c.RoomID != m.RoomID {
+			return
+		}
+
+		switch m.Action {
+		case actionRevokeScreenShare:
+			c.stopScreenShare(m.ModeratorID)
+		case actionRequestMute:
+			requested := Message{Type: "mute_requested", UserID: c.UserID, RoomID: m.RoomID}
+			requested.Payload, _ = json.Marshal(map[string]interface{}{
+				"moderator_id": m.ModeratorID,
+			})
+			c.sendMessage(requested)
+			log.Printf("Client %d in room %d asked to mute by %d", c.UserID, m.RoomID, m.ModeratorID)
+		case actionInviteToStage:
+			c.setRole(RoleSpeaker)
+		case actionMoveToAudience:
+			c.setRole(RoleListener)
+		case actionMoveToRoom:
+			c.moveToRoom(m.TargetRoomID)
+		default:
+			log.Printf("Unknown moderator action %q for client %d", m.Action, c.UserID)
+		}
+	})
+}
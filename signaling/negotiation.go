@@ -0,0 +1,317 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/Aloys-y/chat-go/models"
+)
+
+// Roles of the two peers of a mesh connection in perfect negotiation. When
+// both send an offer at once, the impolite peer ignores the other's offer and
+// the polite peer rolls its own back.
+const (
+	NegotiationPolite   = "polite"
+	NegotiationImpolite = "impolite"
+)
+
+// States of an offer a client received
+const (
+	NegotiationOffered = "offered"
+	NegotiationStable  = "stable"
+	NegotiationFailed  = "failed"
+)
+
+const (
+	// negotiationTimeout is how long an offer may go unanswered before the
+	// pair is asked to negotiate again
+	negotiationTimeout = 10 * time.Second
+	// maxNegotiationAttempts bounds the retries of a stuck negotiation
+	maxNegotiationAttempts = 3
+)
+
+// negotiation tracks the offers a client received from one peer. It is kept
+// on the node of the answering client, which sees both the offer arrive and
+// the answer leave.
+type negotiation struct {
+	State    string
+	Attempts int
+	timer    *time.Timer
+}
+
+// isPolite reports whether userID is the polite peer of its connection to
+// peerID. The higher user ID is polite, so both nodes agree without talking.
+func isPolite(userID, peerID uint) bool {
+	return userID > peerID
+}
+
+// negotiationRole returns userID's role on its connection to peerID
+func negotiationRole(userID, peerID uint) string {
+	if isPolite(userID, peerID) {
+		return NegotiationPolite
+	}
+	return NegotiationImpolite
+}
+
+// impolitePeer returns the peer of the pair that starts negotiations the
+// server asks for
+func impolitePeer(a, b uint) uint {
+	if isPolite(a, b) {
+		return b
+	}
+	return a
+}
+
+// peerRolesLocked returns userID's role toward every other participant of a
+// mesh room, or nil in SFU rooms. The caller must hold room.Mux.
+func (r *Room) peerRolesLocked(userID uint) map[uint]string {
+	if r.MediaMode == models.MediaModeSFU {
+		return nil
+	}
+	roles := make(map[uint]string, len(r.Participants))
+	for peerID := range r.Participants {
+		if peerID != userID {
+			roles[peerID] = negotiationRole(userID, peerID)
+		}
+	}
+	return roles
+}
+
+// joinedRolesLocked returns the role every other participant of a mesh room
+// has toward userID, for user_joined. The caller must hold room.Mux.
+func (r *Room) joinedRolesLocked(userID uint) map[uint]string {
+	if r.MediaMode == models.MediaModeSFU {
+		return nil
+	}
+	roles := make(map[uint]string, len(r.Participants))
+	for peerID := range r.Participants {
+		if peerID != userID {
+			roles[peerID] = negotiationRole(peerID, userID)
+		}
+	}
+	return roles
+}
+
+// trackSignal notes the offers relayed to the client so unanswered ones are
+// noticed
+func (c *Client) trackSignal(msg Message) {
+	if msg.Type != "sdp_offer" || msg.UserID == 0 {
+		return
+	}
+
+	c.negotiationMux.Lock()
+	defer c.negotiationMux.Unlock()
+
+	n := c.negotiationLocked(msg.UserID)
+	if n.State == NegotiationFailed {
+		// The peers are trying again on their own
+		n.Attempts = 0
+	}
+	n.State = NegotiationOffered
+	if n.timer != nil {
+		n.timer.Stop()
+	}
+	peerID := msg.UserID
+	n.timer = time.AfterFunc(negotiationTimeout, func() {
+		c.do(func() { c.negotiationStuck(peerID) })
+	})
+}
+
+// trackSent notes the offers and answers the client sends to a peer
+func (c *Client) trackSent(msg Message) {
+	if msg.TargetID == 0 {
+		return
+	}
+
+	c.negotiationMux.Lock()
+	defer c.negotiationMux.Unlock()
+
+	n, ok := c.negotiations[msg.TargetID]
+	if !ok || n.State != NegotiationOffered {
+		return
+	}
+	switch msg.Type {
+	case "sdp_answer":
+		c.settleLocked(msg.TargetID, NegotiationStable)
+	case "sdp_offer":
+		// Glare: the impolite peer ignores the polite peer's offer, which
+		// will be rolled back and never answered
+		if !isPolite(c.UserID, msg.TargetID) {
+			c.settleLocked(msg.TargetID, NegotiationStable)
+		}
+	}
+}
+
+// negotiationLocked returns the negotiation with the peer, creating it. The
+// caller must hold c.negotiationMux.
+func (c *Client) negotiationLocked(peerID uint) *negotiation {
+	if c.negotiations == nil {
+		c.negotiations = make(map[uint]*negotiation)
+	}
+	n, ok := c.negotiations[peerID]
+	if !ok {
+		n = &negotiation{State: NegotiationStable}
+		c.negotiations[peerID] = n
+	}
+	return n
+}
+
+// settleLocked ends the pending offer of the peer. The caller must hold
+// c.negotiationMux.
+func (c *Client) settleLocked(peerID uint, state string) {
+	n, ok := c.negotiations[peerID]
+	if !ok {
+		return
+	}
+	if n.timer != nil {
+		n.timer.Stop()
+		n.timer = nil
+	}
+	n.State = state
+	if state == NegotiationStable {
+		n.Attempts = 0
+	}
+}
+
+// resetNegotiations forgets the client's negotiations when it leaves its room
+func (c *Client) resetNegotiations() {
+	c.negotiationMux.Lock()
+	defer c.negotiationMux.Unlock()
+
+	for _, n := range c.negotiations {
+		if n.timer != nil {
+			n.timer.Stop()
+		}
+	}
+	c.negotiations = nil
+}
+
+// negotiationStuck runs when the peer's offer went unanswered. The offering
+// peer is asked to negotiate again, with an ICE restart after the first
+// retry, until the attempts run out and both peers are told it failed.
+func (c *Client) negotiationStuck(peerID uint) {
+	roomID := c.RoomID
+
+	c.negotiationMux.Lock()
+	n, ok := c.negotiations[peerID]
+	if !ok || n.State != NegotiationOffered {
+		c.negotiationMux.Unlock()
+		return
+	}
+	if roomID == 0 || !isRoomParticipant(roomID, peerID) {
+		c.settleLocked(peerID, NegotiationStable)
+		delete(c.negotiations, peerID)
+		c.negotiationMux.Unlock()
+		return
+	}
+
+	n.Attempts++
+	attempt := n.Attempts
+	if attempt > maxNegotiationAttempts {
+		c.settleLocked(peerID, NegotiationFailed)
+	} else {
+		// Keep watching until the retry is answered
+		n.timer = time.AfterFunc(negotiationTimeout, func() {
+			c.do(func() { c.negotiationStuck(peerID) })
+		})
+	}
+	c.negotiationMux.Unlock()
+
+	if attempt > maxNegotiationAttempts {
+		log.Printf("Negotiation between %d and %d in room %d failed after %d attempts",
+			peerID, c.UserID, roomID, maxNegotiationAttempts)
+		for _, pair := range [][2]uint{{c.UserID, peerID}, {peerID, c.UserID}} {
+			failed := Message{Type: "negotiation_failed", RoomID: roomID}
+			failed.Payload, _ = json.Marshal(map[string]interface{}{
+				"peer_id": pair[1],
+			})
+			sendToUser(pair[0], failed)
+		}
+		return
+	}
+
+	log.Printf("Offer from %d to %d in room %d unanswered, retry %d", peerID, c.UserID, roomID, attempt)
+	askToNegotiate(roomID, c.UserID, peerID, "renegotiate", map[string]interface{}{
+		"attempt":     attempt,
+		"ice_restart": attempt > 1,
+	})
+}
+
+// offeringPeer returns the peer of the pair that sends the offers the server
+// asks for: the speaker of a speaker and a listener in a stage room, since
+// listeners can't offer, and the impolite peer otherwise
+func offeringPeer(roomID, a, b uint) uint {
+	speakerA, speakerB := isSpeaker(roomID, a), isSpeaker(roomID, b)
+	if speakerA != speakerB {
+		if speakerA {
+			return a
+		}
+		return b
+	}
+	return impolitePeer(a, b)
+}
+
+// askToNegotiate sends a message of the given type to the offering peer of
+// the pair, naming the other peer in peer_id
+func askToNegotiate(roomID, a, b uint, msgType string, fields map[string]interface{}) {
+	initiator, peerID := offeringPeer(roomID, a, b), a
+	if initiator == a {
+		peerID = b
+	}
+
+	payload := map[string]interface{}{"peer_id": peerID}
+	for k, v := range fields {
+		payload[k] = v
+	}
+	msg := Message{Type: msgType, RoomID: roomID}
+	msg.Payload, _ = json.Marshal(payload)
+	sendToUser(initiator, msg)
+}
+
+// handleICERestart asks for an ICE restart of the client's connection to
+// target_id, after the client saw it fail. The offering peer of the pair
+// gets ice_restart and sends the restart offer.
+func (c *Client) handleICERestart(msg Message) error {
+	if c.RoomID == 0 {
+		return newProtocolError(ErrCodeNotInRoom, "client %d not in any room", c.UserID)
+	}
+	if roomMediaMode(c.RoomID) == models.MediaModeSFU {
+		return newProtocolError(ErrCodeWrongMediaMode, "room %d uses the SFU, restart with sfu_publish", c.RoomID)
+	}
+	if msg.TargetID == 0 {
+		return newProtocolError(ErrCodeInvalidPayload, "target_id is required")
+	}
+	if !isRoomParticipant(c.RoomID, msg.TargetID) {
+		return newProtocolError(ErrCodeTargetNotFound, "user %d is not in room %d", msg.TargetID, c.RoomID)
+	}
+
+	askToNegotiate(c.RoomID, c.UserID, msg.TargetID, "ice_restart", nil)
+	return nil
+}
+
+// restartICE asks for ICE restarts of all the client's mesh connections once
+// it resumed its session, since its network likely changed
+func (c *Client) restartICE() {
+	room, err := c.currentRoom()
+	if err != nil {
+		return
+	}
+
+	room.Mux.RLock()
+	if room.MediaMode == models.MediaModeSFU {
+		room.Mux.RUnlock()
+		return
+	}
+	peers := make([]uint, 0, len(room.Participants))
+	for peerID := range room.Participants {
+		if peerID != c.UserID {
+			peers = append(peers, peerID)
+		}
+	}
+	room.Mux.RUnlock()
+
+	for _, peerID := range peers {
+		askToNegotiate(room.ID, c.UserID, peerID, "ice_restart", nil)
+	}
+}
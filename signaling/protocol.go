@@ -0,0 +1,153 @@
+package signaling
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Aloys-y/chat-go/ice"
+	"github.com/gorilla/websocket"
+)
+
+// ProtocolVersion is the newest signaling protocol version spoken by the server
+const ProtocolVersion = 1
+
+// supportedProtocolVersions lists every protocol version the server accepts
+var supportedProtocolVersions = []int{1}
+
+// Error codes sent in error frames
+const (
+	ErrCodeInvalidJSON         = "invalid_json"
+	ErrCodeInvalidPayload      = "invalid_payload"
+	ErrCodeUnknownType         = "unknown_type"
+	ErrCodeNotInRoom           = "not_in_room"
+	ErrCodeTargetNotFound      = "target_not_found"
+	ErrCodeRoomNotFound        = "room_not_found"
+	ErrCodeForbidden           = "forbidden"
+	ErrCodeBanned              = "banned"
+	ErrCodeUnsupportedProtocol = "unsupported_protocol_version"
+	ErrCodeResumeFailed        = "resume_failed"
+	ErrCodeWrongMediaMode      = "wrong_media_mode"
+	ErrCodeNegotiationFailed   = "negotiation_failed"
+	ErrCodePolicyViolation     = "policy_violation"
+	ErrCodeFloorTaken          = "floor_taken"
+	ErrCodeNotSpeaker          = "not_a_speaker"
+	ErrCodeRoomFull            = "room_full"
+	ErrCodeCallNotRinging      = "call_not_ringing"
+	ErrCodeE2EERequired        = "e2ee_required"
+	ErrCodeInternal            = "internal_error"
+)
+
+//go:embed schema/messages.schema.json
+var messageSchema []byte
+
+// ProtocolError is a signaling failure reported to the client in an error frame
+type ProtocolError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// newProtocolError creates a ProtocolError with a formatted message
+func newProtocolError(code, format string, args ...interface{}) *ProtocolError {
+	return &ProtocolError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// negotiateProtocol picks the highest version offered by the client that the
+// server supports. Clients that don't offer any version get ProtocolVersion.
+func negotiateProtocol(offered string) (int, error) {
+	if offered == "" {
+		return ProtocolVersion, nil
+	}
+
+	best := 0
+	for _, v := range strings.Split(offered, ",") {
+		version, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		for _, supported := range supportedProtocolVersions {
+			if version == supported && version > best {
+				best = version
+			}
+		}
+	}
+
+	if best == 0 {
+		return 0, newProtocolError(ErrCodeUnsupportedProtocol,
+			"none of the offered versions %q are supported", offered)
+	}
+	return best, nil
+}
+
+// handleSchema serves the JSON Schema describing all signaling messages
+func handleSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(messageSchema)
+}
+
+// sendWelcome tells the client which protocol version was negotiated and
+// how to resume the session
+func (c *Client) sendWelcome() {
+	msg := Message{Type: "welcome", UserID: c.UserID}
+	msg.Payload, _ = json.Marshal(map[string]interface{}{
+		"protocol_version":    c.ProtocolVersion,
+		"supported_versions":  supportedProtocolVersions,
+		"resume_token":        c.ResumeToken,
+		"resume_grace_period": int(resumeGracePeriod().Seconds()),
+	})
+	c.sendMessage(msg)
+}
+
+// sendICEConfig pushes the ICE servers the client should use, including
+// ephemeral TURN credentials minted for the user
+func (c *Client) sendICEConfig() {
+	msg := Message{Type: "ice_config", UserID: c.UserID}
+	msg.Payload, _ = json.Marshal(ice.ConfigForUser(c.UserID))
+	c.sendMessage(msg)
+}
+
+// sendAck acknowledges a successfully handled request
+func (c *Client) sendAck(req Message) {
+	msg := Message{Type: "ack", RequestID: req.RequestID}
+	msg.Payload, _ = json.Marshal(map[string]interface{}{
+		"type": req.Type,
+	})
+	c.sendMessage(msg)
+}
+
+// errorMessage builds the error frame answering req
+func errorMessage(req Message, err error) Message {
+	perr, ok := err.(*ProtocolError)
+	if !ok {
+		perr = &ProtocolError{Code: ErrCodeInternal, Message: err.Error()}
+	}
+
+	msg := Message{Type: "error", RequestID: req.RequestID}
+	msg.Payload, _ = json.Marshal(map[string]interface{}{
+		"code":    perr.Code,
+		"message": perr.Message,
+		"type":    req.Type,
+	})
+	return msg
+}
+
+// sendError reports a failed request to the client
+func (c *Client) sendError(req Message, err error) {
+	c.sendMessage(errorMessage(req, err))
+}
+
+// rejectConnection reports err on a connection that was never registered as
+// a client and closes it
+func rejectConnection(conn *websocket.Conn, err error) {
+	conn.WriteJSON(errorMessage(Message{Type: "connect"}, err))
+	conn.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()))
+	conn.Close()
+}
@@ -0,0 +1,107 @@
+package signaling
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/Aloys-y/chat-go/calls"
+	"github.com/Aloys-y/chat-go/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// statsInterval is the shortest time between two stats reports of a client
+// that are recorded. Reports arriving sooner are dropped.
+const statsInterval = 2 * time.Second
+
+// Connection quality reported by clients, published on /metrics of the
+// WebSocket server
+var (
+	callRTT = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "chatgo_call_rtt_seconds",
+		Help:    "Round-trip time reported by call participants.",
+		Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.15, 0.2, 0.3, 0.5, 1},
+	}, []string{"media_mode"})
+	callJitter = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "chatgo_call_jitter_seconds",
+		Help:    "Jitter of the media received by call participants.",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.02, 0.03, 0.05, 0.1},
+	}, []string{"media_mode"})
+	callPacketLoss = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "chatgo_call_packet_loss_ratio",
+		Help:    "Fraction of packets lost on the media received by call participants.",
+		Buckets: []float64{0, 0.001, 0.005, 0.01, 0.02, 0.05, 0.1, 0.2},
+	}, []string{"media_mode"})
+	callBitrate = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "chatgo_call_bitrate_bits_per_second",
+		Help:    "Media bitrate sent and received by call participants.",
+		Buckets: prometheus.ExponentialBuckets(32000, 2, 8),
+	}, []string{"media_mode", "direction"})
+)
+
+// statsReport is the payload of stats_report, a summary of the client's
+// RTCPeerConnection.getStats() over all its connections
+type statsReport struct {
+	RTTMs        float64 `json:"rtt_ms"`
+	JitterMs     float64 `json:"jitter_ms"`
+	PacketLoss   float64 `json:"packet_loss"`
+	InboundKbps  float64 `json:"inbound_kbps"`
+	OutboundKbps float64 `json:"outbound_kbps"`
+}
+
+// validate checks that the metrics are in range
+func (r *statsReport) validate() error {
+	for _, v := range []float64{r.RTTMs, r.JitterMs, r.PacketLoss, r.InboundKbps, r.OutboundKbps} {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return newProtocolError(ErrCodeInvalidPayload, "stats must be non-negative numbers")
+		}
+	}
+	if r.PacketLoss > 1 {
+		return newProtocolError(ErrCodeInvalidPayload, "packet_loss must be a fraction between 0 and 1")
+	}
+	return nil
+}
+
+// handleStatsReport records the connection quality the client measured in its
+// room's call session and in the metrics
+func (c *Client) handleStatsReport(msg Message) error {
+	room, err := c.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	var report statsReport
+	if err := json.Unmarshal(msg.Payload, &report); err != nil {
+		return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal stats report: %v", err)
+	}
+	if err := report.validate(); err != nil {
+		return err
+	}
+
+	if time.Since(c.statsAt) < statsInterval {
+		return nil
+	}
+	c.statsAt = time.Now()
+
+	room.Mux.RLock()
+	sessionID, mediaMode := room.SessionID, room.MediaMode
+	room.Mux.RUnlock()
+
+	callRTT.WithLabelValues(mediaMode).Observe(report.RTTMs / 1000)
+	callJitter.WithLabelValues(mediaMode).Observe(report.JitterMs / 1000)
+	callPacketLoss.WithLabelValues(mediaMode).Observe(report.PacketLoss)
+	callBitrate.WithLabelValues(mediaMode, "inbound").Observe(report.InboundKbps * 1000)
+	callBitrate.WithLabelValues(mediaMode, "outbound").Observe(report.OutboundKbps * 1000)
+
+	if sessionID == 0 {
+		return nil
+	}
+	return calls.RecordQuality(sessionID, c.UserID, models.QualitySample{
+		RTTMs:        report.RTTMs,
+		JitterMs:     report.JitterMs,
+		PacketLoss:   report.PacketLoss,
+		InboundKbps:  report.InboundKbps,
+		OutboundKbps: report.OutboundKbps,
+	})
+}
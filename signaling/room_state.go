@@ -0,0 +1,397 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/Aloys-y/chat-go/models"
+)
+
+// ParticipantState represents the live state of a participant in a room
+type ParticipantState struct {
+	UserID        uint        `json:"user_id"`
+	UserName      string      `json:"user_name"`
+	UserUsername  string      `json:"user_username"`
+	Muted         bool        `json:"muted"`
+	Speaking      bool        `json:"speaking"`
+	Role          string      `json:"role,omitempty"`
+	HandRaised    bool        `json:"hand_raised,omitempty"`
+	VideoEnabled  bool        `json:"video_enabled"`
+	ScreenSharing bool        `json:"screen_sharing"`
+	Tracks        []TrackInfo `json:"tracks,omitempty"`
+	JoinedAt      time.Time   `json:"joined_at"`
+	// E2EEPublicKey is what others encrypt their media keys to; empty when
+	// the participant doesn't encrypt its media
+	E2EEPublicKey string `json:"e2ee_public_key,omitempty"`
+	// Bot marks server-side participants playing audio
+	Bot bool `json:"bot,omitempty"`
+}
+
+// RoomState is the snapshot sent to a client when it joins a room
+type RoomState struct {
+	RoomID          uint                `json:"room_id"`
+	Version         uint64              `json:"version"`
+	MediaMode       string              `json:"media_mode"`
+	VideoPolicy     models.VideoPolicy  `json:"video_policy"`
+	CodecPolicy     models.CodecPolicy  `json:"codec_policy"`
+	E2EERequired    bool                `json:"e2ee_required"`
+	StageMode       bool                `json:"stage_mode"`
+	MaxParticipants int                 `json:"max_participants"`
+	Locked          bool                `json:"locked"`
+	Participants    []*ParticipantState `json:"participants"`
+	Presenters      []uint              `json:"presenters"`
+	// PeerRoles is the joiner's negotiation role toward each participant of
+	// a mesh room
+	PeerRoles map[uint]string `json:"peer_roles,omitempty"`
+}
+
+// isRoomParticipant reports whether userID is in the live room, on any node
+func isRoomParticipant(roomID, userID uint) bool {
+	roomsMux.RLock()
+	room, exists := rooms[roomID]
+	roomsMux.RUnlock()
+
+	if !exists {
+		return false
+	}
+
+	room.Mux.RLock()
+	defer room.Mux.RUnlock()
+	_, ok := room.Participants[userID]
+	return ok
+}
+
+// currentRoom returns the live room the client is in
+func (c *Client) currentRoom() (*Room, error) {
+	if c.RoomID == 0 {
+		return nil, newProtocolError(ErrCodeNotInRoom, "client %d not in any room", c.UserID)
+	}
+
+	roomsMux.RLock()
+	room, exists := rooms[c.RoomID]
+	roomsMux.RUnlock()
+	if !exists {
+		return nil, newProtocolError(ErrCodeNotInRoom, "client %d not in any room", c.UserID)
+	}
+	return room, nil
+}
+
+// isModerator reports whether the user may moderate the room. Only the room
+// owner moderates.
+func (r *Room) isModerator(userID uint) bool {
+	return userID == r.OwnerID
+}
+
+// roomMediaMode returns the media mode of the live room
+func roomMediaMode(roomID uint) string {
+	roomsMux.RLock()
+	room, exists := rooms[roomID]
+	roomsMux.RUnlock()
+
+	if !exists {
+		return ""
+	}
+
+	room.Mux.RLock()
+	defer room.Mux.RUnlock()
+	return room.MediaMode
+}
+
+// roomCodecPolicy returns the codec policy of the live room
+func roomCodecPolicy(roomID uint) models.CodecPolicy {
+	roomsMux.RLock()
+	room, exists := rooms[roomID]
+	roomsMux.RUnlock()
+
+	if !exists {
+		return models.CodecPolicy{}
+	}
+
+	room.Mux.RLock()
+	defer room.Mux.RUnlock()
+	return room.CodecPolicy
+}
+
+// newRoom creates an empty live room with the settings of the stored room
+func newRoom(info *models.Room) *Room {
+	mediaMode := info.MediaMode
+	if mediaMode == "" {
+		mediaMode = models.MediaModeMesh
+	}
+	return &Room{
+		ID:              info.ID,
+		OwnerID:         info.OwnerID,
+		MediaMode:       mediaMode,
+		VideoPolicy:     info.VideoPolicy,
+		CodecPolicy:     info.CodecPolicy,
+		E2EERequired:    info.E2EERequired,
+		StageMode:       info.StageMode,
+		MaxParticipants: info.MaxParticipants,
+		Locked:          info.Locked,
+		CallID:          callID(info),
+		Clients:         make(map[uint]*Client),
+		Participants:    make(map[uint]*ParticipantState),
+		Waiting:         make(map[uint]*Client),
+	}
+}
+
+// callID returns the direct call the stored room was created for, or 0
+func callID(info *models.Room) uint {
+	if info.CallID == nil {
+		return 0
+	}
+	return *info.CallID
+}
+
+// addParticipantLocked registers the client as a participant, also in the
+// presence registry. The caller must hold room.Mux.
+func (r *Room) addParticipantLocked(c *Client) *ParticipantState {
+	p := &ParticipantState{
+		UserID:        c.UserID,
+		JoinedAt:      time.Now(),
+		E2EEPublicKey: c.e2eeKeyOf(),
+	}
+	if c.User != nil {
+		p.UserName = c.User.DisplayName
+		p.UserUsername = c.User.Username
+		p.Bot = c.User.IsBot
+	}
+	if r.StageMode {
+		p.Role = RoleListener
+		if r.isModerator(c.UserID) {
+			p.Role = RoleSpeaker
+		}
+	}
+
+	r.Clients[c.UserID] = c
+	r.Participants[c.UserID] = p
+	r.storeParticipantLocked(p)
+	return p
+}
+
+// removeParticipantLocked removes the participant, also from the presence
+// registry. The caller must hold room.Mux.
+func (r *Room) removeParticipantLocked(userID uint) bool {
+	if _, ok := r.Clients[userID]; !ok {
+		return false
+	}
+
+	delete(r.Clients, userID)
+	delete(r.Participants, userID)
+
+	r.queueLocked(roomUpdate{store: func(ctx context.Context) error {
+		return presence.RemoveRoomMember(ctx, r.ID, userID)
+	}})
+	return true
+}
+
+// copy returns a copy of the participant state that later changes to p
+// don't affect
+func (p *ParticipantState) copy() *ParticipantState {
+	cp := *p
+	cp.Tracks = append([]TrackInfo(nil), p.Tracks...)
+	return &cp
+}
+
+// storeParticipantLocked saves a local participant's state in the presence
+// registry so other nodes can include it in their snapshots. The caller must
+// hold room.Mux.
+func (r *Room) storeParticipantLocked(p *ParticipantState) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("Failed to marshal participant: %v", err)
+		return
+	}
+
+	userID := p.UserID
+	r.queueLocked(roomUpdate{store: func(ctx context.Context) error {
+		return presence.PutRoomMember(ctx, r.ID, userID, nodeID, data)
+	}})
+}
+
+// updateParticipantLocked publishes a change to a local participant's state
+// as participant_updated. The caller must hold room.Mux.
+func (r *Room) updateParticipantLocked(p *ParticipantState) {
+	r.storeParticipantLocked(p)
+
+	msg := Message{
+		Type:   "participant_updated",
+		UserID: p.UserID,
+		RoomID: r.ID,
+	}
+	msg.Payload, _ = json.Marshal(p)
+	r.publishVersionedLocked(delivery{Message: msg, Upsert: p.copy()})
+}
+
+// loadRemoteLocked fills a newly created local room with the participants
+// hosted on other nodes. The caller must hold room.Mux.
+func (r *Room) loadRemoteLocked() {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+
+	members, err := presence.RoomMembers(ctx, r.ID)
+	if err != nil {
+		log.Printf("Failed to load members of room %d: %v", r.ID, err)
+		return
+	}
+	for userID, data := range members {
+		var p ParticipantState
+		if err := json.Unmarshal(data, &p); err != nil {
+			continue
+		}
+		r.Participants[userID] = &p
+	}
+
+	if v, err := presence.RoomVersion(ctx, r.ID); err == nil {
+		r.Version = v
+	}
+}
+
+// snapshotLocked returns a copy of the current room state. The caller must
+// hold room.Mux.
+func (r *Room) snapshotLocked() RoomState {
+	state := RoomState{
+		RoomID:          r.ID,
+		Version:         r.Version,
+		MediaMode:       r.MediaMode,
+		VideoPolicy:     r.VideoPolicy,
+		CodecPolicy:     r.CodecPolicy,
+		E2EERequired:    r.E2EERequired,
+		StageMode:       r.StageMode,
+		MaxParticipants: r.MaxParticipants,
+		Locked:          r.Locked,
+		Participants:    make([]*ParticipantState, 0, len(r.Participants)),
+		Presenters:      r.presentersLocked(),
+	}
+	for _, p := range r.Participants {
+		state.Participants = append(state.Participants, p.copy())
+	}
+	return state
+}
+
+// broadcastLocked sends a message to every participant in the room except
+// exceptID (0 sends to everyone), on this node and on the others. The caller
+// must hold room.Mux.
+func (r *Room) broadcastLocked(msg Message, exceptID uint) {
+	r.publishLocked(delivery{Message: msg, ExceptID: exceptID})
+}
+
+// publishLocked delivers d's message to the local clients and publishes d to
+// the other nodes hosting participants of the room, after the changes queued
+// before it. The caller must hold room.Mux.
+func (r *Room) publishLocked(d delivery) {
+	r.queueLocked(roomUpdate{build: func(uint64) delivery { return d }})
+}
+
+// publishVersionedLocked is publishLocked for a change of the room state,
+// whose message is numbered with the next room version. The caller must
+// hold room.Mux.
+func (r *Room) publishVersionedLocked(d delivery) {
+	r.queueLocked(roomUpdate{
+		versioned: true,
+		build: func(version uint64) delivery {
+			d.Message.Version = version
+			return d
+		},
+	})
+}
+
+// roomUpdate is a change to a room carried out after the room is unlocked:
+// a write to the presence registry, a delivery to the room, or both
+type roomUpdate struct {
+	// store writes the change to the presence registry
+	store func(ctx context.Context) error
+	// versioned updates are numbered with the next room version, which the
+	// presence registry allocates so versions stay ordered across nodes
+	versioned bool
+	// build returns the delivery of the update given the room version.
+	// Deliveries without a message type only go to the other nodes.
+	build func(version uint64) delivery
+	// lobby sends the local clients the lobby once the update is stored
+	lobby bool
+}
+
+// queueLocked queues an update, starting to carry out the room's updates if
+// nobody is. The caller must hold room.Mux.
+func (r *Room) queueLocked(u roomUpdate) {
+	r.pending = append(r.pending, u)
+	if !r.flushing {
+		r.flushing = true
+		go r.flush()
+	}
+}
+
+// flush carries out the room's queued updates in order. Presence and broker
+// calls are made without holding room.Mux, so a slow presence registry only
+// delays the room's updates, not the handlers changing it.
+func (r *Room) flush() {
+	for {
+		r.Mux.Lock()
+		if len(r.pending) == 0 {
+			r.flushing = false
+			r.Mux.Unlock()
+			break
+		}
+		u := r.pending[0]
+		r.pending = r.pending[1:]
+		r.Mux.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+		if u.store != nil {
+			if err := u.store(ctx); err != nil {
+				log.Printf("Failed to update room %d in presence: %v", r.ID, err)
+			}
+		}
+		var version uint64
+		if u.versioned {
+			var err error
+			if version, err = presence.NextRoomVersion(ctx, r.ID); err != nil {
+				log.Printf("Failed to allocate version for room %d: %v", r.ID, err)
+			}
+		}
+		cancel()
+		var lobby []LobbyEntry
+		if u.lobby {
+			lobby = roomLobby(r.ID)
+		}
+
+		r.Mux.Lock()
+		if u.versioned {
+			if version <= r.Version {
+				version = r.Version + 1
+			}
+			r.Version = version
+		}
+		var d delivery
+		if u.build != nil {
+			d = u.build(r.Version)
+			if d.Message.Type != "" {
+				r.deliverLocked(d.Message, d.ExceptID)
+			}
+		}
+		if u.lobby {
+			r.notifyLobbyLocked(lobby)
+		}
+		r.Mux.Unlock()
+
+		if u.build != nil {
+			publish(roomTopic(r.ID), d)
+		}
+	}
+
+	// The room was kept while it had updates left to carry out
+	dropIfEmpty(r)
+}
+
+// deliverLocked sends a message to the local clients in the room except
+// exceptID. The caller must hold room.Mux.
+func (r *Room) deliverLocked(msg Message, exceptID uint) {
+	for _, client := range r.Clients {
+		if client.UserID == exceptID {
+			continue
+		}
+		client.sendMessage(msg)
+	}
+}
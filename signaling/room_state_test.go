@@ -0,0 +1,92 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Aloys-y/chat-go/cluster"
+	"github.com/Aloys-y/chat-go/models"
+)
+
+// slowPresence holds back room writes until release is closed
+type slowPresence struct {
+	cluster.Presence
+	release chan struct{}
+}
+
+func (p *slowPresence) PutRoomMember(ctx context.Context, roomID, userID uint, nodeID string, state []byte) error {
+	<-p.release
+	return p.Presence.PutRoomMember(ctx, roomID, userID, nodeID, state)
+}
+
+func (p *slowPresence) NextRoomVersion(ctx context.Context, roomID uint) (uint64, error) {
+	<-p.release
+	return p.Presence.NextRoomVersion(ctx, roomID)
+}
+
+func TestRoomUpdatesDontHoldTheRoom(t *testing.T) {
+	ctx := context.Background()
+	mem := cluster.NewMemoryPresence()
+	if err := mem.Heartbeat(ctx, nodeID, time.Minute); err != nil {
+		t.Fatalf("Heartbeat() failed: %v", err)
+	}
+	slow := &slowPresence{Presence: mem, release: make(chan struct{})}
+	old := presence
+	presence = slow
+	t.Cleanup(func() { presence = old })
+
+	info := &models.Room{}
+	info.ID = 1
+	room := newRoom(info)
+	p := &ParticipantState{UserID: 7}
+	room.Mux.Lock()
+	room.Participants[7] = p
+	room.updateParticipantLocked(p)
+	p.Muted = true
+	room.updateParticipantLocked(p)
+	room.Mux.Unlock()
+
+	// Other handlers get the room while presence hasn't answered
+	locked := make(chan struct{})
+	go func() {
+		room.Mux.Lock()
+		room.Mux.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("room stayed locked while presence was slow")
+	}
+
+	// Once presence answers, the updates are stored in order
+	close(slow.release)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		room.Mux.RLock()
+		done := !room.flushing
+		version := room.Version
+		room.Mux.RUnlock()
+		if done {
+			if version != 2 {
+				t.Errorf("room version = %d, want 2", version)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("room updates were never carried out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	members, err := mem.RoomMembers(ctx, 1)
+	if err != nil {
+		t.Fatalf("RoomMembers() failed: %v", err)
+	}
+	var stored ParticipantState
+	if err := json.Unmarshal(members[7], &stored); err != nil || !stored.Muted {
+		t.Errorf("stored participant %s, want the latest, muted state", members[7])
+	}
+}
@@ -0,0 +1,181 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+
+	"github.com/Aloys-y/chat-go/config"
+)
+
+// defaultMaxPresenters is how many participants may share their screen at once
+const defaultMaxPresenters = 1
+
+// maxPresenters returns how many participants of a room may share their
+// screen at the same time
+func maxPresenters() int {
+	if n := config.AppConfig.Signaling.MaxPresenters; n > 0 {
+		return n
+	}
+	return defaultMaxPresenters
+}
+
+// presentersLocked returns the participants sharing their screen, as far as
+// this node knows. The floor itself is claimed in the presence registry. The
+// caller must hold room.Mux.
+func (r *Room) presentersLocked() []uint {
+	presenters := []uint{}
+	for _, p := range r.Participants {
+		if p.ScreenSharing {
+			presenters = append(presenters, p.UserID)
+		}
+	}
+	sort.Slice(presenters, func(i, j int) bool { return presenters[i] < presenters[j] })
+	return presenters
+}
+
+// handleScreenShareStart grants the client the screen-share floor if fewer
+// than maxPresenters participants hold it, on any node
+func (c *Client) handleScreenShareStart(msg Message) error {
+	room, err := c.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	room.Mux.RLock()
+	err = room.checkPresenterLocked(c.UserID)
+	sharing := err == nil && room.Participants[c.UserID].ScreenSharing
+	room.Mux.RUnlock()
+	if err != nil || sharing {
+		return err
+	}
+
+	// The floor is claimed in presence so presenters on different nodes
+	// can't both take it
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	granted, presenters, err := presence.ClaimFloor(ctx, room.ID, c.UserID, nodeID, maxPresenters())
+	cancel()
+	if err != nil {
+		return err
+	}
+	if !granted {
+		return newProtocolError(ErrCodeFloorTaken, "users %v are presenting", presenters)
+	}
+
+	room.Mux.Lock()
+	if err := room.checkPresenterLocked(c.UserID); err != nil {
+		room.Mux.Unlock()
+		releaseFloor(room.ID, c.UserID)
+		return err
+	}
+	p := room.Participants[c.UserID]
+	p.ScreenSharing = true
+	room.updateParticipantLocked(p)
+	room.Mux.Unlock()
+
+	log.Printf("Client %d started sharing its screen in room %d", c.UserID, room.ID)
+	return nil
+}
+
+// checkPresenterLocked checks that the user is in the room and may share
+// their screen. The caller must hold room.Mux.
+func (r *Room) checkPresenterLocked(userID uint) error {
+	p, ok := r.Participants[userID]
+	if !ok {
+		return newProtocolError(ErrCodeNotInRoom, "client %d not in room %d", userID, r.ID)
+	}
+	if r.StageMode && p.Role != RoleSpeaker {
+		return newProtocolError(ErrCodeNotSpeaker, "only speakers can share their screen in room %d", r.ID)
+	}
+	return nil
+}
+
+// releaseFloor gives up the user's screen-share floor in presence. It is
+// called without holding room.Mux.
+func releaseFloor(roomID, userID uint) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := presence.ReleaseFloor(ctx, roomID, userID); err != nil {
+		log.Printf("Failed to release screen-share floor of user %d in room %d: %v", userID, roomID, err)
+	}
+}
+
+// handleScreenShareStop releases the client's screen-share floor or, with a
+// user_id, lets a moderator revoke another presenter's floor
+func (c *Client) handleScreenShareStop(msg Message) error {
+	room, err := c.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		UserID uint `json:"user_id"`
+	}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal screen share stop: %v", err)
+		}
+	}
+	if req.UserID == 0 || req.UserID == c.UserID {
+		c.stopScreenShare(0)
+		return nil
+	}
+
+	room.Mux.RLock()
+	moderator := room.isModerator(c.UserID)
+	target, ok := room.Participants[req.UserID]
+	presenting := ok && target.ScreenSharing
+	room.Mux.RUnlock()
+
+	if !moderator {
+		return newProtocolError(ErrCodeForbidden, "only moderators can stop another user's screen share")
+	}
+	if !presenting {
+		return newProtocolError(ErrCodeTargetNotFound, "user %d is not presenting in room %d", req.UserID, room.ID)
+	}
+
+	moderate(req.UserID, moderation{RoomID: room.ID, Action: actionRevokeScreenShare, ModeratorID: c.UserID})
+	return nil
+}
+
+// stopScreenShare releases the client's screen-share floor and drops its
+// screen tracks. A non-zero revokedBy is the moderator who revoked the floor;
+// the client is told with screen_share_revoked.
+func (c *Client) stopScreenShare(revokedBy uint) {
+	room, err := c.currentRoom()
+	if err != nil {
+		return
+	}
+
+	room.Mux.Lock()
+	p, ok := room.Participants[c.UserID]
+	if !ok || !p.ScreenSharing {
+		room.Mux.Unlock()
+		return
+	}
+
+	tracks := make([]TrackInfo, 0, len(p.Tracks))
+	for _, t := range p.Tracks {
+		if t.Kind != TrackKindScreen {
+			tracks = append(tracks, t)
+		}
+	}
+	p.Tracks = tracks
+	p.ScreenSharing = false
+	room.updateParticipantLocked(p)
+	room.Mux.Unlock()
+
+	releaseFloor(room.ID, c.UserID)
+
+	if revokedBy != 0 {
+		revoked := Message{Type: "screen_share_revoked", UserID: c.UserID, RoomID: room.ID}
+		revoked.Payload, _ = json.Marshal(map[string]interface{}{
+			"moderator_id": revokedBy,
+		})
+		c.sendMessage(revoked)
+		log.Printf("Screen share of client %d in room %d revoked by %d", c.UserID, room.ID, revokedBy)
+		return
+	}
+	log.Printf("Client %d stopped sharing its screen in room %d", c.UserID, room.ID)
+}
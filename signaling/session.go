@@ -0,0 +1,286 @@
+package signaling
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Aloys-y/chat-go/config"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// sendChanSize is the capacity of a connection's outgoing queue
+	sendChanSize = 256
+
+	defaultResumeGracePeriod = 30 * time.Second
+	defaultEventBufferSize   = 256
+)
+
+var (
+	// sessions maps resume tokens to their client sessions
+	sessions    = make(map[string]*Client)
+	sessionsMux sync.Mutex
+)
+
+// bufferedEvent is a sequenced frame kept for replay after a reconnect
+type bufferedEvent struct {
+	Seq  uint64
+	Data []byte
+}
+
+// resumeGracePeriod returns how long a disconnected session stays in its room
+func resumeGracePeriod() time.Duration {
+	d, err := time.ParseDuration(config.AppConfig.Signaling.ResumeGracePeriod)
+	if err != nil || d <= 0 {
+		return defaultResumeGracePeriod
+	}
+	return d
+}
+
+// eventBufferSize returns how many outgoing frames are kept per session
+func eventBufferSize() int {
+	if n := config.AppConfig.Signaling.EventBufferSize; n > 0 {
+		return n
+	}
+	return defaultEventBufferSize
+}
+
+// newResumeToken generates a random session resume token
+func newResumeToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// registerSession makes the client resumable through its resume token
+func registerSession(c *Client) {
+	sessionsMux.Lock()
+	sessions[c.ResumeToken] = c
+	sessionsMux.Unlock()
+}
+
+// unregisterSession forgets the client's resume token
+func unregisterSession(c *Client) {
+	sessionsMux.Lock()
+	if sessions[c.ResumeToken] == c {
+		delete(sessions, c.ResumeToken)
+	}
+	sessionsMux.Unlock()
+}
+
+// userSession returns one of the user's sessions on this node, or nil
+func userSession(userID uint) *Client {
+	sessionsMux.Lock()
+	defer sessionsMux.Unlock()
+
+	for _, c := range sessions {
+		if c.UserID == userID {
+			return c
+		}
+	}
+	return nil
+}
+
+// resumeSession attaches conn to the session identified by token and replays
+// the frames after lastSeq. A session that can't be resumed is ended so the
+// user leaves its room before starting over.
+func resumeSession(token string, userID uint, conn *websocket.Conn, lastSeq uint64) error {
+	sessionsMux.Lock()
+	c, ok := sessions[token]
+	sessionsMux.Unlock()
+
+	if !ok || c.UserID != userID {
+		return newProtocolError(ErrCodeResumeFailed, "unknown resume token")
+	}
+
+	// The session's goroutine takes over the connection, so the room it
+	// reports and restarts ICE in can't change meanwhile
+	result := make(chan error, 1)
+	if !c.do(func() { result <- c.resume(conn, lastSeq) }) {
+		return newProtocolError(ErrCodeResumeFailed, "session has ended")
+	}
+	if err := <-result; err != nil {
+		c.close()
+		return err
+	}
+	return nil
+}
+
+// run handles the client's frames and queued actions one at a time until the
+// session ends
+func (c *Client) run() {
+	defer close(c.done)
+
+	for {
+		select {
+		case data := <-c.inbox:
+			c.handleMessage(data)
+		case <-c.wake:
+			c.actionsMux.Lock()
+			actions := c.actions
+			ended := c.ended
+			c.actions = nil
+			c.actionsMux.Unlock()
+
+			for _, action := range actions {
+				action()
+			}
+			if ended {
+				return
+			}
+		}
+	}
+}
+
+// do queues an action for the session's goroutine, after the ones queued
+// before it. It reports false once the session has ended.
+func (c *Client) do(action func()) bool {
+	c.actionsMux.Lock()
+	defer c.actionsMux.Unlock()
+
+	if c.ended {
+		return false
+	}
+	c.queueLocked(action)
+	return true
+}
+
+// end queues the last action of the session; its goroutine stops after it
+func (c *Client) end(action func()) {
+	c.actionsMux.Lock()
+	defer c.actionsMux.Unlock()
+
+	if c.ended {
+		return
+	}
+	c.queueLocked(action)
+	c.ended = true
+}
+
+// queueLocked appends the action and wakes the session's goroutine. The
+// caller must hold c.actionsMux.
+func (c *Client) queueLocked(action func()) {
+	c.actions = append(c.actions, action)
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// bufferEventLocked appends a frame to the replay buffer, dropping the oldest
+// one when full. The caller must hold c.connMux.
+func (c *Client) bufferEventLocked(ev bufferedEvent) {
+	if len(c.events) >= eventBufferSize() {
+		c.events = c.events[1:]
+	}
+	c.events = append(c.events, ev)
+}
+
+// resume replaces the client's connection with conn and queues every frame
+// the client missed
+func (c *Client) resume(conn *websocket.Conn, lastSeq uint64) error {
+	c.connMux.Lock()
+
+	if c.closed {
+		c.connMux.Unlock()
+		return newProtocolError(ErrCodeResumeFailed, "session has ended")
+	}
+	if lastSeq > c.seq {
+		c.connMux.Unlock()
+		return newProtocolError(ErrCodeResumeFailed, "last_seq %d is ahead of the session (%d)", lastSeq, c.seq)
+	}
+
+	// Collect the frames the client hasn't seen
+	var missed [][]byte
+	if lastSeq < c.seq {
+		if len(c.events) == 0 || c.events[0].Seq > lastSeq+1 {
+			c.connMux.Unlock()
+			return newProtocolError(ErrCodeResumeFailed, "events after seq %d are no longer buffered", lastSeq)
+		}
+		for _, ev := range c.events {
+			if ev.Seq > lastSeq {
+				missed = append(missed, ev.Data)
+			}
+		}
+	}
+
+	// The old connection may still look alive if the server hasn't noticed
+	// it dropped; take over from it
+	oldConn := c.Conn
+	if c.detached {
+		c.graceTimer.Stop()
+	} else {
+		close(c.SendChan)
+	}
+
+	c.detached = false
+	c.Conn = conn
+	c.SendChan = make(chan []byte, len(missed)+sendChanSize)
+
+	resumed := Message{Type: "resumed", UserID: c.UserID, RoomID: c.RoomID}
+	resumed.Payload, _ = json.Marshal(map[string]interface{}{
+		"last_seq": c.seq,
+		"replayed": len(missed),
+	})
+	if data, err := json.Marshal(resumed); err == nil {
+		c.SendChan <- data
+	}
+	for _, data := range missed {
+		c.SendChan <- data
+	}
+	sendChan := c.SendChan
+	c.connMux.Unlock()
+
+	if oldConn != conn {
+		oldConn.Close()
+	}
+
+	go c.readPump(conn)
+	go c.writePump(conn, sendChan)
+
+	// The network probably changed under the media connections too
+	c.restartICE()
+
+	log.Printf("Client %d resumed session, replayed %d events", c.UserID, len(missed))
+	return nil
+}
+
+// disconnect handles the loss of conn. A resumable disconnect keeps the
+// session in its room for the grace period; otherwise the session ends.
+func (c *Client) disconnect(conn *websocket.Conn, resumable bool) {
+	c.connMux.Lock()
+	if c.closed || c.detached || c.Conn != conn {
+		// Already handled, or the session moved to a newer connection
+		c.connMux.Unlock()
+		conn.Close()
+		return
+	}
+
+	if !resumable {
+		c.connMux.Unlock()
+		c.close()
+		return
+	}
+
+	grace := resumeGracePeriod()
+	c.detached = true
+	close(c.SendChan)
+	c.graceTimer = time.AfterFunc(grace, c.expire)
+	c.connMux.Unlock()
+
+	conn.Close()
+	log.Printf("Client %d connection lost, holding session for %s", c.UserID, grace)
+}
+
+// expire ends a session whose grace period ran out without a resume
+func (c *Client) expire() {
+	if c.closeSession(true) {
+		log.Printf("Client %d session expired", c.UserID)
+	}
+}
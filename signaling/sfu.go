@@ -0,0 +1,111 @@
+package signaling
+
+import (
+	"encoding/json"
+
+	"github.com/Aloys-y/chat-go/sfu"
+	"github.com/pion/webrtc/v4"
+)
+
+// sfuSignal delivers the SFU's subscription offers and ICE candidates to the
+// user's session
+func sfuSignal(roomID uint) sfu.SignalFunc {
+	return func(userID uint, msgType string, payload interface{}) {
+		msg := Message{Type: msgType, UserID: userID, RoomID: roomID}
+		msg.Payload, _ = json.Marshal(payload)
+		sendToUser(userID, msg)
+	}
+}
+
+// handleSFUMessage handles publish/subscribe negotiation with the SFU: the
+// client offers its own tracks with sfu_publish and answers the server's
+// sfu_subscribe_offer for the other participants' tracks. With sfu_audio_mode
+// it chooses between every participant's audio track and one mix.
+func (c *Client) handleSFUMessage(msg Message) error {
+	if c.RoomID == 0 {
+		return newProtocolError(ErrCodeNotInRoom, "client %d not in any room", c.UserID)
+	}
+
+	peer := sfu.GetPeer(c.RoomID, c.UserID)
+	if peer == nil {
+		return newProtocolError(ErrCodeWrongMediaMode, "room %d does not use the SFU", c.RoomID)
+	}
+
+	switch msg.Type {
+	case "sfu_publish":
+		var offer webrtc.SessionDescription
+		if err := json.Unmarshal(msg.Payload, &offer); err != nil {
+			return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal offer: %v", err)
+		}
+		if !isSpeaker(c.RoomID, c.UserID) {
+			return newProtocolError(ErrCodeNotSpeaker, "only speakers can publish in room %d", c.RoomID)
+		}
+		if err := c.checkPublishedVideo(offer.SDP); err != nil {
+			return err
+		}
+		if err := checkOffer(offer.SDP, roomCodecPolicy(c.RoomID)); err != nil {
+			return err
+		}
+		answer, err := peer.Publish(offer)
+		if err != nil {
+			return newProtocolError(ErrCodeNegotiationFailed, "publish failed: %v", err)
+		}
+
+		reply := Message{Type: "sfu_publish_answer", UserID: c.UserID, RoomID: c.RoomID}
+		reply.Payload, _ = json.Marshal(answer)
+		c.sendMessage(reply)
+	case "sfu_subscribe_answer":
+		var answer webrtc.SessionDescription
+		if err := json.Unmarshal(msg.Payload, &answer); err != nil {
+			return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal answer: %v", err)
+		}
+		if err := peer.Answer(answer); err != nil {
+			return newProtocolError(ErrCodeNegotiationFailed, "subscribe failed: %v", err)
+		}
+	case "sfu_candidate":
+		var candidate sfu.Candidate
+		if err := json.Unmarshal(msg.Payload, &candidate); err != nil {
+			return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal candidate: %v", err)
+		}
+		if err := peer.AddCandidate(candidate); err != nil {
+			return newProtocolError(ErrCodeInvalidPayload, "invalid candidate: %v", err)
+		}
+	case sfu.MsgAudioMode:
+		var mode struct {
+			Mixed bool `json:"mixed"`
+		}
+		if err := json.Unmarshal(msg.Payload, &mode); err != nil {
+			return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal audio mode: %v", err)
+		}
+		if mode.Mixed && RoomEncrypted(c.RoomID) {
+			return newProtocolError(ErrCodePolicyViolation, "end-to-end encrypted audio can't be mixed")
+		}
+		if err := peer.SetMixedAudio(mode.Mixed); err != nil {
+			return newProtocolError(ErrCodeNegotiationFailed, "failed to switch audio mode: %v", err)
+		}
+
+		reply := Message{Type: sfu.MsgAudioMode, UserID: c.UserID, RoomID: c.RoomID}
+		reply.Payload, _ = json.Marshal(mode)
+		c.sendMessage(reply)
+	}
+	return nil
+}
+
+// checkPublishedVideo checks the video the client offers to publish against
+// the room's video policy and the client's track_info
+func (c *Client) checkPublishedVideo(offer string) error {
+	room, err := c.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	room.Mux.RLock()
+	var described []TrackInfo
+	if p, ok := room.Participants[c.UserID]; ok {
+		described = p.Tracks
+	}
+	policy := room.VideoPolicy
+	room.Mux.RUnlock()
+
+	return checkOfferedVideo(offer, described, policy)
+}
@@ -0,0 +1,173 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/Aloys-y/chat-go/sfu"
+)
+
+// Roles of participants in a stage room: speakers send media, listeners only
+// receive it
+const (
+	RoleSpeaker  = "speaker"
+	RoleListener = "listener"
+)
+
+// isSpeaker reports whether the user may send media in the live room. Every
+// participant may outside of stage rooms.
+func isSpeaker(roomID, userID uint) bool {
+	roomsMux.RLock()
+	room, exists := rooms[roomID]
+	roomsMux.RUnlock()
+
+	if !exists {
+		return false
+	}
+
+	room.Mux.RLock()
+	defer room.Mux.RUnlock()
+	if !room.StageMode {
+		return true
+	}
+	p, ok := room.Participants[userID]
+	return ok && p.Role == RoleSpeaker
+}
+
+// handleRaiseHand raises or lowers a listener's hand to ask for the stage
+func (c *Client) handleRaiseHand(msg Message) error {
+	room, err := c.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Raised *bool `json:"raised"`
+	}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal raise hand: %v", err)
+		}
+	}
+	raised := req.Raised == nil || *req.Raised
+
+	room.Mux.Lock()
+	defer room.Mux.Unlock()
+
+	if !room.StageMode {
+		return newProtocolError(ErrCodeForbidden, "room %d is not a stage", room.ID)
+	}
+	p, ok := room.Participants[c.UserID]
+	if !ok {
+		return newProtocolError(ErrCodeNotInRoom, "client %d not in room %d", c.UserID, room.ID)
+	}
+	if p.Role == RoleSpeaker {
+		return newProtocolError(ErrCodeForbidden, "speakers cannot raise their hand")
+	}
+	if p.HandRaised == raised {
+		return nil
+	}
+
+	p.HandRaised = raised
+	room.updateParticipantLocked(p)
+	return nil
+}
+
+// handleStageMove lets a moderator bring a listener on stage with
+// invite_to_stage or send a speaker back to the audience with
+// move_to_audience. Speakers may also leave the stage themselves.
+func (c *Client) handleStageMove(msg Message) error {
+	room, err := c.currentRoom()
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		UserID uint `json:"user_id"`
+	}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal %s: %v", msg.Type, err)
+		}
+	}
+	if req.UserID == 0 {
+		req.UserID = c.UserID
+	}
+
+	action, role := actionInviteToStage, RoleSpeaker
+	if msg.Type == "move_to_audience" {
+		action, role = actionMoveToAudience, RoleListener
+	}
+
+	room.Mux.RLock()
+	stage := room.StageMode
+	moderator := room.isModerator(c.UserID)
+	target, present := room.Participants[req.UserID]
+	currentRole := ""
+	if present {
+		currentRole = target.Role
+	}
+	room.Mux.RUnlock()
+
+	if !stage {
+		return newProtocolError(ErrCodeForbidden, "room %d is not a stage", room.ID)
+	}
+	if !present {
+		return newProtocolError(ErrCodeTargetNotFound, "user %d is not in room %d", req.UserID, room.ID)
+	}
+	if req.UserID == c.UserID && role == RoleListener {
+		c.setRole(RoleListener)
+		return nil
+	}
+	if !moderator {
+		return newProtocolError(ErrCodeForbidden, "only moderators can change who is on stage")
+	}
+	if currentRole == role {
+		return nil
+	}
+
+	moderate(req.UserID, moderation{RoomID: room.ID, Action: action, ModeratorID: c.UserID})
+	return nil
+}
+
+// setRole moves the client on or off the stage. Leaving the stage stops the
+// client's screen share and, in SFU rooms, ends the tracks it publishes.
+func (c *Client) setRole(role string) {
+	room, err := c.currentRoom()
+	if err != nil {
+		return
+	}
+
+	room.Mux.Lock()
+	p, ok := room.Participants[c.UserID]
+	if !ok || !room.StageMode || p.Role == role {
+		room.Mux.Unlock()
+		return
+	}
+
+	presenting := p.ScreenSharing
+	p.Role = role
+	p.HandRaised = false
+	if role == RoleListener {
+		p.Tracks = nil
+		p.VideoEnabled = false
+		p.ScreenSharing = false
+		p.Speaking = false
+	}
+	room.updateParticipantLocked(p)
+	roomID := room.ID
+	room.Mux.Unlock()
+
+	if role == RoleListener {
+		if presenting {
+			releaseFloor(roomID, c.UserID)
+		}
+		if peer := sfu.GetPeer(roomID, c.UserID); peer != nil {
+			if err := peer.Unpublish(); err != nil {
+				log.Printf("Failed to unpublish client %d in SFU room %d: %v", c.UserID, roomID, err)
+			}
+		}
+	}
+
+	log.Printf("Client %d is now a %s in room %d", c.UserID, role, roomID)
+}
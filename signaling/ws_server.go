@@ -7,9 +7,15 @@ import (
 	"net/http"
 	"strconv"
 	"sync"
+	"time"
 
-	"github.com/gorilla/websocket"
+	"github.com/Aloys-y/chat-go/auth"
+	"github.com/Aloys-y/chat-go/calls"
+	"github.com/Aloys-y/chat-go/membership"
 	"github.com/Aloys-y/chat-go/models"
+	"github.com/Aloys-y/chat-go/sfu"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
@@ -28,34 +34,110 @@ var (
 	roomsMux sync.RWMutex
 )
 
-// Client represents a WebSocket client
+// Client represents a WebSocket client. RoomID and lobby are only touched on
+// the session's goroutine, which handles the client's frames and the actions
+// other goroutines queue with do.
 type Client struct {
 	Conn     *websocket.Conn
 	UserID   uint
 	User     *models.User
 	RoomID   uint
 	SendChan chan []byte
+
+	// ProtocolVersion is the signaling protocol version negotiated on connect
+	ProtocolVersion int
+
+	// ResumeToken lets the client reattach to this session after a reconnect
+	ResumeToken string
+
+	// connMux guards the connection state and the replay buffer
+	connMux    sync.Mutex
+	closed     bool
+	detached   bool
+	seq        uint64
+	events     []bufferedEvent
+	graceTimer *time.Timer
+
+	// speakingMux guards the rate limiting of speaking updates
+	speakingMux     sync.Mutex
+	speakingAt      time.Time
+	speakingTimer   *time.Timer
+	speakingPending bool
+
+	// lobby is the room the client is waiting to enter
+	lobby *models.Room
+
+	// statsAt is when the last stats_report was accepted. Only the session's
+	// goroutine touches it.
+	statsAt time.Time
+
+	// negotiationMux guards the offers received from each peer
+	negotiationMux sync.Mutex
+	negotiations   map[uint]*negotiation
+
+	// e2eeMux guards the public key the client's media keys are encrypted
+	// to, advertised on join_room
+	e2eeMux       sync.Mutex
+	e2eePublicKey string
+
+	// inbox carries the frames read from the connection to the session's
+	// goroutine, which closes done when the session ends
+	inbox chan []byte
+	done  chan struct{}
+
+	// actionsMux guards the actions queued for the session's goroutine;
+	// wake signals that there are some
+	actionsMux sync.Mutex
+	actions    []func()
+	ended      bool
+	wake       chan struct{}
 }
 
-// Room represents a WebRTC room
+// Room represents a WebRTC room. Waiting holds the local clients in its lobby.
 type Room struct {
-	ID      uint
-	Clients map[uint]*Client
-	Mux     sync.RWMutex
+	ID              uint
+	OwnerID         uint
+	Clients         map[uint]*Client
+	Participants    map[uint]*ParticipantState
+	Waiting         map[uint]*Client
+	Version         uint64
+	MediaMode       string
+	VideoPolicy     models.VideoPolicy
+	CodecPolicy     models.CodecPolicy
+	E2EERequired    bool
+	StageMode       bool
+	MaxParticipants int
+	Locked          bool
+	CallID          uint
+	// SessionID is the call session the room's participants are in
+	SessionID uint
+	Mux       sync.RWMutex
+
+	// pending holds the presence writes and deliveries of the changes made
+	// under Mux, which flush carries out in order without holding it
+	pending  []roomUpdate
+	flushing bool
 }
 
-// Message represents a WebSocket message
+// Message represents a WebSocket message. RequestID is generated by the
+// client and echoed back in the matching ack or error frame. Seq numbers every
+// frame the server sends on a session so missed frames can be replayed.
 type Message struct {
 	Type      string          `json:"type"`
+	RequestID string          `json:"request_id,omitempty"`
 	UserID    uint            `json:"user_id,omitempty"`
 	RoomID    uint            `json:"room_id,omitempty"`
 	Payload   json.RawMessage `json:"payload,omitempty"`
 	TargetID  uint            `json:"target_id,omitempty"`
+	Version   uint64          `json:"version,omitempty"`
+	Seq       uint64          `json:"seq,omitempty"`
 }
 
 // StartWSServer starts the WebSocket server
 func StartWSServer(port int) {
 	http.HandleFunc("/ws", handleWebSocket)
+	http.HandleFunc("/ws/schema", handleSchema)
+	http.Handle("/metrics", promhttp.Handler())
 	addr := fmt.Sprintf(":%d", port)
 	log.Printf("WebSocket server starting on %s", addr)
 	log.Fatal(http.ListenAndServe(addr, nil))
@@ -70,78 +152,133 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract user ID from query parameter
-	userIDStr := r.URL.Query().Get("user_id")
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	// Authenticate the user with the token from the query parameter
+	claims, err := auth.ValidateToken(r.URL.Query().Get("token"))
 	if err != nil {
-		log.Printf("Invalid user ID: %v", err)
+		log.Printf("Invalid token: %v", err)
 		conn.Close()
 		return
 	}
+	userID := claims.UserID
+
+	user, err := auth.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Unknown user %d: %v", userID, err)
+		conn.Close()
+		return
+	}
+
+	// Negotiate the signaling protocol version
+	protocolVersion, err := negotiateProtocol(r.URL.Query().Get("protocol_version"))
+	if err != nil {
+		log.Printf("Rejecting client %d: %v", userID, err)
+		rejectConnection(conn, err)
+		return
+	}
+
+	// Resume an existing session if the client presents a resume token
+	var resumeErr error
+	if token := r.URL.Query().Get("resume_token"); token != "" {
+		lastSeq, _ := strconv.ParseUint(r.URL.Query().Get("last_seq"), 10, 64)
+		if resumeErr = resumeSession(token, uint(userID), conn, lastSeq); resumeErr == nil {
+			return
+		}
+		log.Printf("Client %d failed to resume session: %v", userID, resumeErr)
+	}
+
+	resumeToken, err := newResumeToken()
+	if err != nil {
+		log.Printf("Failed to generate resume token: %v", err)
+		rejectConnection(conn, err)
+		return
+	}
 
 	// Create new client
 	client := &Client{
-		Conn:     conn,
-		UserID:   uint(userID),
-		SendChan: make(chan []byte, 256),
+		Conn:            conn,
+		UserID:          uint(userID),
+		User:            user,
+		SendChan:        make(chan []byte, sendChanSize),
+		ProtocolVersion: protocolVersion,
+		ResumeToken:     resumeToken,
+		inbox:           make(chan []byte),
+		done:            make(chan struct{}),
+		wake:            make(chan struct{}, 1),
 	}
 
-	// Register client
-	clientsMux.Lock()
-	clients[client.UserID] = client
-	clientsMux.Unlock()
+	client.register()
 
 	// Start client goroutines
-	go client.readPump()
-	go client.writePump()
+	go client.run()
+	go client.readPump(conn)
+	go client.writePump(conn, client.SendChan)
+
+	client.sendWelcome()
+	client.sendICEConfig()
+	if resumeErr != nil {
+		client.sendError(Message{Type: "resume"}, resumeErr)
+	}
 
 	log.Printf("Client connected: UserID=%d", client.UserID)
 }
 
-// readPump pumps messages from the WebSocket connection to the hub
-func (c *Client) readPump() {
-	defer func() {
-		c.close()
-	}()
+// register makes the client the user's latest session and counts it in the
+// user's presence on this node
+func (c *Client) register() {
+	registerSession(c)
+	clientsMux.Lock()
+	clients[c.UserID] = c
+	clientsMux.Unlock()
+	registerUserNode(c.UserID)
+}
 
+// readPump pumps messages from the WebSocket connection to the session's
+// goroutine. When the connection drops unexpectedly the session is kept for
+// resumption.
+func (c *Client) readPump(conn *websocket.Conn) {
 	for {
-		_, message, err := c.Conn.ReadMessage()
+		_, message, err := conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket read error: %v", err)
 			}
-			break
+			// A deliberate close from the client ends the session
+			hangup := websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway)
+			c.disconnect(conn, !hangup)
+			return
 		}
 
-		c.handleMessage(message)
+		select {
+		case c.inbox <- message:
+		case <-c.done:
+			return
+		}
 	}
 }
 
 // writePump pumps messages from the hub to the WebSocket connection
-func (c *Client) writePump() {
-	defer func() {
-		c.close()
-	}()
+func (c *Client) writePump(conn *websocket.Conn, sendChan chan []byte) {
+	defer conn.Close()
 
 	for {
-		message, ok := <-c.SendChan
+		message, ok := <-sendChan
 		if !ok {
 			// Channel closed
-			c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+			conn.WriteMessage(websocket.CloseMessage, []byte{})
 			return
 		}
 
-		w, err := c.Conn.NextWriter(websocket.TextMessage)
+		w, err := conn.NextWriter(websocket.TextMessage)
 		if err != nil {
 			return
 		}
 		w.Write(message)
 
 		// Add queued chat messages to the current WebSocket message
-		n := len(c.SendChan)
+		n := len(sendChan)
 		for i := 0; i < n; i++ {
 			w.Write([]byte{'\n'})
-			w.Write(<-c.SendChan)
+			w.Write(<-sendChan)
 		}
 
 		if err := w.Close(); err != nil {
@@ -150,144 +287,335 @@ func (c *Client) writePump() {
 	}
 }
 
-// handleMessage processes incoming WebSocket messages
+// handleMessage processes incoming WebSocket messages. Requests that fail
+// are answered with an error frame; requests carrying a request_id are
+// acknowledged on success.
 func (c *Client) handleMessage(data []byte) {
 	var msg Message
 	if err := json.Unmarshal(data, &msg); err != nil {
-		log.Printf("Failed to unmarshal message: %v", err)
+		c.sendError(msg, newProtocolError(ErrCodeInvalidJSON, "failed to unmarshal message: %v", err))
 		return
 	}
 
 	msg.UserID = c.UserID
 
+	var err error
 	switch msg.Type {
 	case "join_room":
-		c.handleJoinRoom(msg)
+		err = c.handleJoinRoom(msg)
 	case "leave_room":
-		c.handleLeaveRoom(msg)
+		err = c.handleLeaveRoom(msg)
 	case "sdp_offer":
 		fallthrough
 	case "sdp_answer":
 		fallthrough
 	case "ice_candidate":
-		c.handleWebRTCMessage(msg)
+		err = c.handleWebRTCMessage(msg)
+	case "sfu_publish", "sfu_subscribe_answer", "sfu_candidate", sfu.MsgAudioMode:
+		err = c.handleSFUMessage(msg)
+	case "track_info":
+		err = c.handleTrackInfo(msg)
+	case "screen_share_start":
+		err = c.handleScreenShareStart(msg)
+	case "screen_share_stop":
+		err = c.handleScreenShareStop(msg)
+	case "mute_state":
+		err = c.handleMuteState(msg)
+	case "speaking":
+		err = c.handleSpeaking(msg)
+	case "request_mute":
+		err = c.handleRequestMute(msg)
+	case "raise_hand":
+		err = c.handleRaiseHand(msg)
+	case "invite_to_stage", "move_to_audience":
+		err = c.handleStageMove(msg)
+	case "admit", "admit_all":
+		err = c.handleAdmit(msg)
+	case "lock_room":
+		err = c.handleLockRoom(msg)
+	case "call_invite":
+		err = c.handleCallInvite(msg)
+	case "call_accept":
+		err = c.handleCallAccept(msg)
+	case "call_decline":
+		err = c.handleCallDecline(msg)
+	case "call_cancel":
+		err = c.handleCallCancel(msg)
+	case "e2ee_key":
+		err = c.handleE2EEKey(msg)
+	case "ice_restart":
+		err = c.handleICERestart(msg)
+	case "stats_report":
+		err = c.handleStatsReport(msg)
+	case "get_ice_config":
+		c.sendICEConfig()
 	default:
-		log.Printf("Unknown message type: %s", msg.Type)
+		err = newProtocolError(ErrCodeUnknownType, "unknown message type %q", msg.Type)
+	}
+
+	if err != nil {
+		log.Printf("Client %d %s failed: %v", c.UserID, msg.Type, err)
+		c.sendError(msg, err)
+		return
+	}
+	if msg.RequestID != "" {
+		c.sendAck(msg)
 	}
 }
 
 // handleJoinRoom handles room join messages
-func (c *Client) handleJoinRoom(msg Message) {
+func (c *Client) handleJoinRoom(msg Message) error {
 	var roomInfo struct {
-		RoomID uint `json:"room_id"`
+		RoomID        uint   `json:"room_id"`
+		E2EEPublicKey string `json:"e2ee_public_key"`
 	}
 
 	if err := json.Unmarshal(msg.Payload, &roomInfo); err != nil {
-		log.Printf("Failed to unmarshal room info: %v", err)
-		return
+		return newProtocolError(ErrCodeInvalidPayload, "failed to unmarshal room info: %v", err)
+	}
+	if roomInfo.RoomID == 0 {
+		return newProtocolError(ErrCodeInvalidPayload, "room_id is required")
+	}
+	if len(roomInfo.E2EEPublicKey) > maxE2EEPublicKeyLength {
+		return newProtocolError(ErrCodeInvalidPayload, "e2ee_public_key is longer than %d characters", maxE2EEPublicKeyLength)
 	}
 
-	// Leave current room if any
+	// The database is the membership authority: the room must exist and the
+	// user must be allowed in
+	room, err := membership.Admit(roomInfo.RoomID, c.UserID)
+	if err != nil {
+		return admissionError(roomInfo.RoomID, err)
+	}
+	if room.E2EERequired && roomInfo.E2EEPublicKey == "" {
+		return newProtocolError(ErrCodeE2EERequired, "room %d requires end-to-end encryption", roomInfo.RoomID)
+	}
+	c.setE2EEPublicKey(roomInfo.E2EEPublicKey)
+
+	// Leave current room or lobby if any
 	if c.RoomID != 0 {
 		c.leaveRoom(c.RoomID)
 	}
+	c.leaveLobby()
 
-	// Join new room
+	// Join new room; the joiner receives the room state and the other
+	// members are notified
 	c.RoomID = roomInfo.RoomID
-	c.joinRoom(c.RoomID)
-
-	// Notify room members
-	msg.Type = "user_joined"
-	msg.RoomID = c.RoomID
-	msg.Payload, _ = json.Marshal(map[string]interface{}{
-		"user_id":       c.UserID,
-		"user_name":     c.User.DisplayName,
-		"user_username": c.User.Username,
-	})
-
-	c.broadcastToRoom(msg)
+	c.joinRoom(room, false)
+	return nil
 }
 
 // handleLeaveRoom handles room leave messages
-func (c *Client) handleLeaveRoom(msg Message) {
+func (c *Client) handleLeaveRoom(msg Message) error {
+	if c.RoomID == 0 && c.lobby != nil {
+		c.leaveLobby()
+		return nil
+	}
 	if c.RoomID == 0 {
-		return
+		return newProtocolError(ErrCodeNotInRoom, "client is not in any room")
 	}
 
-	roomID := c.RoomID
-	c.leaveRoom(roomID)
-
-	// Notify room members
-	msg.Type = "user_left"
-	msg.RoomID = roomID
-	msg.Payload, _ = json.Marshal(map[string]interface{}{
-		"user_id": c.UserID,
-	})
-
-	c.broadcastToRoom(msg)
+	c.leaveRoom(c.RoomID)
+	return nil
 }
 
 // handleWebRTCMessage handles WebRTC signaling messages
-func (c *Client) handleWebRTCMessage(msg Message) {
+func (c *Client) handleWebRTCMessage(msg Message) error {
 	if c.RoomID == 0 {
-		log.Printf("Client %d not in any room", c.UserID)
-		return
+		return newProtocolError(ErrCodeNotInRoom, "client %d not in any room", c.UserID)
+	}
+
+	if roomMediaMode(c.RoomID) == models.MediaModeSFU {
+		return newProtocolError(ErrCodeWrongMediaMode, "room %d uses the SFU, publish with sfu_publish", c.RoomID)
+	}
+
+	// On a stage only speakers send media, so only their offers are relayed
+	if msg.Type == "sdp_offer" && !isSpeaker(c.RoomID, c.UserID) {
+		return newProtocolError(ErrCodeNotSpeaker, "only speakers can send media in room %d", c.RoomID)
 	}
 
-	// Broadcast to all clients in the room except the sender
 	msg.RoomID = c.RoomID
+	msg.RequestID = ""
+
+	// Relay to the addressed peer when there is one
+	if msg.TargetID != 0 {
+		if !isRoomParticipant(c.RoomID, msg.TargetID) {
+			return newProtocolError(ErrCodeTargetNotFound, "user %d is not in room %d", msg.TargetID, c.RoomID)
+		}
+		c.trackSent(msg)
+		sendToUser(msg.TargetID, msg)
+		return nil
+	}
+
+	// Broadcast to all clients in the room except the sender
 	c.broadcastToRoomExceptSender(msg)
+	return nil
 }
 
-// joinRoom adds a client to a room
-func (c *Client) joinRoom(roomID uint) {
+// joinRoom adds a client to a room, sends it a room_state snapshot and
+// broadcasts user_joined to the rest of the room. In SFU rooms the client
+// also gets a peer on the SFU. Clients that weren't admitted by a moderator
+// wait in the lobby while the room is full or locked. Participants are
+// recorded in the room's call session once the room is unlocked.
+func (c *Client) joinRoom(info *models.Room, admitted bool) {
+	roomID := info.ID
+	lobby := roomLobby(roomID)
+
 	roomsMux.Lock()
 	room, exists := rooms[roomID]
 	if !exists {
-		room = &Room{
-			ID:      roomID,
-			Clients: make(map[uint]*Client),
-		}
+		room = newRoom(info)
 		rooms[roomID] = room
 	}
+	room.Mux.Lock()
 	roomsMux.Unlock()
 
-	room.Mux.Lock()
-	room.Clients[c.UserID] = c
+	// First local participant: start following the room on the other nodes
+	if !exists {
+		subscribe(roomTopic(roomID))
+		room.loadRemoteLocked()
+	}
+
+	if !admitted && room.mustWaitLocked(c.UserID, lobby) {
+		room.waitLocked(c, info)
+		room.Mux.Unlock()
+		return
+	}
+
+	participant := room.addParticipantLocked(c)
+
+	// Send the snapshot before any delta so the joiner can apply later
+	// versions on top of it
+	state := room.snapshotLocked()
+	state.PeerRoles = room.peerRolesLocked(c.UserID)
+	stateMsg := Message{
+		Type:    "room_state",
+		RoomID:  roomID,
+		Version: state.Version,
+	}
+	stateMsg.Payload, _ = json.Marshal(state)
+	c.sendMessage(stateMsg)
+	if room.isModerator(c.UserID) {
+		if len(lobby) > 0 {
+			c.sendMessage(lobbyMessage(roomID, lobby))
+		}
+	}
+
+	joined := Message{
+		Type:   "user_joined",
+		UserID: c.UserID,
+		RoomID: roomID,
+	}
+	joined.Payload, _ = json.Marshal(struct {
+		*ParticipantState
+		PeerRoles map[uint]string `json:"peer_roles,omitempty"`
+	}{participant, room.joinedRolesLocked(c.UserID)})
+	room.publishVersionedLocked(delivery{Message: joined, ExceptID: c.UserID, Upsert: participant.copy()})
+	room.rotateKeysLocked(c.UserID, RotateJoined)
+
+	if room.MediaMode == models.MediaModeSFU {
+		if _, err := sfu.Join(roomID, c.UserID, sfuSignal(roomID)); err != nil {
+			log.Printf("Failed to join client %d to SFU room %d: %v", c.UserID, roomID, err)
+		}
+		// The server can't mix, record or broadcast the audio of a
+		// participant who encrypts it
+		if participant.E2EEPublicKey != "" {
+			sfu.StopMixing(roomID)
+			if _, err := sfu.StopRecording(roomID); err == nil {
+				log.Printf("Recording of room %d stopped: client %d encrypts its media", roomID, c.UserID)
+			}
+			if err := sfu.StopBroadcast(roomID); err == nil {
+				log.Printf("Broadcast of room %d stopped: client %d encrypts its media", roomID, c.UserID)
+			}
+		}
+	}
 	room.Mux.Unlock()
 
+	// The session is recorded in a transaction that waits for the other
+	// nodes' joins and leaves, which mustn't hold up the room
+	if sessionID, err := calls.JoinSession(roomID, c.UserID, nodeID); err != nil {
+		log.Printf("Failed to record client %d joining the session of room %d: %v", c.UserID, roomID, err)
+	} else {
+		room.Mux.Lock()
+		room.SessionID = sessionID
+		room.Mux.Unlock()
+	}
+
 	log.Printf("Client %d joined room %d", c.UserID, roomID)
 }
 
-// leaveRoom removes a client from a room
+// leaveRoom removes a client from a room and broadcasts user_left to the
+// remaining members
 func (c *Client) leaveRoom(roomID uint) {
 	roomsMux.RLock()
 	room, exists := rooms[roomID]
 	roomsMux.RUnlock()
 
+	c.RoomID = 0
+	c.resetNegotiations()
 	if !exists {
 		return
 	}
 
+	lobby := roomLobby(roomID)
 	room.Mux.Lock()
-	delete(room.Clients, c.UserID)
+	p, presenting := room.Participants[c.UserID]
+	presenting = presenting && p.ScreenSharing
+	removed := room.removeParticipantLocked(c.UserID)
+	if removed {
+		left := Message{
+			Type:   "user_left",
+			UserID: c.UserID,
+			RoomID: roomID,
+		}
+		left.Payload, _ = json.Marshal(map[string]interface{}{
+			"user_id": c.UserID,
+		})
+		room.publishVersionedLocked(delivery{Message: left, RemoveID: c.UserID})
+		room.rotateKeysLocked(c.UserID, RotateLeft)
+	}
+	admitIDs := room.vacanciesLocked(lobby)
+	callID := room.CallID
 	room.Mux.Unlock()
 
-	// Clean up empty room
-	room.Mux.RLock()
-	isEmpty := len(room.Clients) == 0
-	room.Mux.RUnlock()
+	if removed {
+		if presenting {
+			releaseFloor(roomID, c.UserID)
+		}
+		if err := calls.LeaveSession(roomID, c.UserID); err != nil {
+			log.Printf("Failed to record client %d leaving the session of room %d: %v", c.UserID, roomID, err)
+		}
+	}
+	sfu.Leave(roomID, c.UserID)
 
-	if isEmpty {
-		roomsMux.Lock()
-		delete(rooms, roomID)
-		roomsMux.Unlock()
+	// Leaving the room of a direct call hangs it up for both users
+	if callID != 0 {
+		hangUp(callID, roomID)
 	}
 
-	c.RoomID = 0
+	// A seat freed up for the next users in the lobby
+	if len(admitIDs) > 0 {
+		admit(roomID, admitIDs)
+	}
+	dropIfEmpty(room)
+
 	log.Printf("Client %d left room %d", c.UserID, roomID)
 }
 
+// dropIfEmpty forgets the room once no local client is in it or in its lobby
+// and its updates are carried out, re-checking under both locks in case
+// someone joined in the meantime
+func dropIfEmpty(room *Room) {
+	roomsMux.Lock()
+	defer roomsMux.Unlock()
+	room.Mux.RLock()
+	defer room.Mux.RUnlock()
+
+	if len(room.Clients) == 0 && len(room.Waiting) == 0 && !room.flushing && rooms[room.ID] == room {
+		delete(rooms, room.ID)
+		unsubscribe(roomTopic(room.ID))
+	}
+}
+
 // broadcastToRoom broadcasts a message to all clients in a room
 func (c *Client) broadcastToRoom(msg Message) {
 	roomsMux.RLock()
@@ -298,22 +626,8 @@ func (c *Client) broadcastToRoom(msg Message) {
 		return
 	}
 
-	msgBytes, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Failed to marshal message: %v", err)
-		return
-	}
-
 	room.Mux.RLock()
-	for _, client := range room.Clients {
-		select {
-		case client.SendChan <- msgBytes:
-		default:
-			// Client send buffer full, close connection
-			log.Printf("Client %d send buffer full", client.UserID)
-			client.close()
-		}
-	}
+	room.broadcastLocked(msg, 0)
 	room.Mux.RUnlock()
 }
 
@@ -327,41 +641,92 @@ func (c *Client) broadcastToRoomExceptSender(msg Message) {
 		return
 	}
 
+	room.Mux.RLock()
+	room.broadcastLocked(msg, c.UserID)
+	room.Mux.RUnlock()
+}
+
+// sendMessage numbers a frame, keeps it for replay and queues it for the
+// client without blocking. While the session is detached frames are only
+// buffered. A client whose send queue is full is disconnected.
+func (c *Client) sendMessage(msg Message) {
+	c.connMux.Lock()
+	defer c.connMux.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.seq++
+	msg.Seq = c.seq
 	msgBytes, err := json.Marshal(msg)
 	if err != nil {
 		log.Printf("Failed to marshal message: %v", err)
 		return
 	}
+	c.bufferEventLocked(bufferedEvent{Seq: msg.Seq, Data: msgBytes})
 
-	room.Mux.RLock()
-	for _, client := range room.Clients {
-		if client.UserID == c.UserID {
-			continue
-		}
-		select {
-		case client.SendChan <- msgBytes:
-		default:
-			// Client send buffer full, close connection
-			log.Printf("Client %d send buffer full", client.UserID)
-			client.close()
-		}
+	if c.detached {
+		return
+	}
+
+	select {
+	case c.SendChan <- msgBytes:
+	default:
+		// Client send buffer full, close connection. close takes room
+		// locks, so it must not run on the caller's goroutine.
+		log.Printf("Client %d send buffer full", c.UserID)
+		go c.close()
 	}
-	room.Mux.RUnlock()
 }
 
-// close closes a client connection
+// close ends the client session. It is safe to call more than once.
 func (c *Client) close() {
+	c.closeSession(false)
+}
+
+// closeSession ends the session, leaving its room and forgetting its resume
+// token. With onlyDetached set, a session that has been resumed in the
+// meantime is left alone. It reports whether the session was ended.
+func (c *Client) closeSession(onlyDetached bool) bool {
+	c.connMux.Lock()
+	if c.closed || (onlyDetached && !c.detached) {
+		c.connMux.Unlock()
+		return false
+	}
+	c.closed = true
+	if c.detached {
+		c.graceTimer.Stop()
+	} else {
+		close(c.SendChan)
+	}
+	conn := c.Conn
+	c.connMux.Unlock()
+
+	unregisterSession(c)
+
+	// Direct messages go to another session of the user, if any is left
 	clientsMux.Lock()
-	delete(clients, c.UserID)
+	if clients[c.UserID] == c {
+		if other := userSession(c.UserID); other != nil {
+			clients[c.UserID] = other
+		} else {
+			delete(clients, c.UserID)
+		}
+	}
 	clientsMux.Unlock()
+	unregisterUserNode(c.UserID)
 
-	// Leave room if any
-	if c.RoomID != 0 {
-		c.leaveRoom(c.RoomID)
-	}
+	// Leave room or lobby if any, after the actions queued so far
+	c.end(func() {
+		if c.RoomID != 0 {
+			c.leaveRoom(c.RoomID)
+		}
+		c.leaveLobby()
+	})
 
-	c.Conn.Close()
-	close(c.SendChan)
+	conn.Close()
 
 	log.Printf("Client %d disconnected", c.UserID)
-}
\ No newline at end of file
+	return true
+}
@@ -0,0 +1,150 @@
+package turnserver
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// addrIdleTimeout is how long an address-to-user binding is kept without traffic
+const addrIdleTimeout = 10 * time.Minute
+
+// bucket is a token bucket measured in bytes
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Directions of the traffic between a client and the TURN server, each with
+// its own quota
+const (
+	inbound  = iota // from the client
+	outbound        // to the client
+)
+
+// directionNames label the metrics of each direction
+var directionNames = [2]string{"inbound", "outbound"}
+
+// addrBinding maps a client transport address to the user that authenticated from it
+type addrBinding struct {
+	userID   uint
+	lastSeen time.Time
+}
+
+// quotas enforces a per-user bandwidth limit on the traffic between clients
+// and the TURN server. Users are identified by the transport address they
+// authenticated from; traffic from addresses that haven't authenticated yet
+// (e.g. STUN binding requests) is not limited.
+type quotas struct {
+	bytesPerSecond float64
+
+	mu      sync.Mutex
+	addrs   map[string]*addrBinding
+	buckets map[uint]*[2]bucket
+}
+
+// newQuotas creates a tracker limiting each user to maxBitrateKbps in each
+// direction. Zero disables the limit but keeps the accounting.
+func newQuotas(maxBitrateKbps int) *quotas {
+	return &quotas{
+		bytesPerSecond: float64(maxBitrateKbps) * 1000 / 8,
+		addrs:          make(map[string]*addrBinding),
+		buckets:        make(map[uint]*[2]bucket),
+	}
+}
+
+// bind records that userID authenticated from addr
+func (q *quotas) bind(addr net.Addr, userID uint) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.addrs[addr.String()] = &addrBinding{userID: userID, lastSeen: time.Now()}
+}
+
+// allow accounts n bytes exchanged with addr in the given direction and
+// reports whether they fit in the user's quota for it
+func (q *quotas) allow(addr net.Addr, n int, direction int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	binding, ok := q.addrs[addr.String()]
+	if !ok {
+		return true
+	}
+
+	now := time.Now()
+	binding.lastSeen = now
+	bytesRelayed.WithLabelValues(directionNames[direction]).Add(float64(n))
+
+	if q.bytesPerSecond <= 0 {
+		return true
+	}
+
+	buckets, ok := q.buckets[binding.userID]
+	if !ok {
+		full := bucket{tokens: q.bytesPerSecond, last: now}
+		buckets = &[2]bucket{full, full}
+		q.buckets[binding.userID] = buckets
+	}
+	b := &buckets[direction]
+
+	// Refill, allowing bursts of up to one second worth of traffic
+	b.tokens += now.Sub(b.last).Seconds() * q.bytesPerSecond
+	if b.tokens > q.bytesPerSecond {
+		b.tokens = q.bytesPerSecond
+	}
+	b.last = now
+
+	if b.tokens < float64(n) {
+		packetsDropped.WithLabelValues(directionNames[direction]).Inc()
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// sweep forgets bindings and buckets that have been idle for a while
+func (q *quotas) sweep() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	cutoff := time.Now().Add(-addrIdleTimeout)
+	active := make(map[uint]bool)
+	for addr, binding := range q.addrs {
+		if binding.lastSeen.Before(cutoff) {
+			delete(q.addrs, addr)
+			continue
+		}
+		active[binding.userID] = true
+	}
+	for userID := range q.buckets {
+		if !active[userID] {
+			delete(q.buckets, userID)
+		}
+	}
+}
+
+// quotaConn applies the quotas to everything read from and written to the
+// client-facing socket. Packets over quota are dropped.
+type quotaConn struct {
+	net.PacketConn
+	quotas *quotas
+}
+
+// ReadFrom implements net.PacketConn
+func (c *quotaConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil || c.quotas.allow(addr, n, inbound) {
+			return n, addr, err
+		}
+	}
+}
+
+// WriteTo implements net.PacketConn
+func (c *quotaConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if !c.quotas.allow(addr, len(p), outbound) {
+		return len(p), nil
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
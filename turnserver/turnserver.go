@@ -0,0 +1,143 @@
+package turnserver
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/Aloys-y/chat-go/config"
+	"github.com/Aloys-y/chat-go/ice"
+	"github.com/pion/turn/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Allocation metrics, published on /metrics of the WebSocket server
+var (
+	allocations = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "chatgo_turn_allocations_total",
+		Help: "Relay allocations handed out by the TURN server.",
+	})
+	authAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "chatgo_turn_auth_attempts_total",
+		Help: "TURN authentication attempts by result.",
+	}, []string{"result"})
+	bytesRelayed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "chatgo_turn_bytes_total",
+		Help: "Bytes exchanged with authenticated TURN clients.",
+	}, []string{"direction"})
+	packetsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "chatgo_turn_packets_dropped_total",
+		Help: "Packets dropped for exceeding the per-user bandwidth quota.",
+	}, []string{"direction"})
+)
+
+// Server is the embedded TURN/STUN server
+type Server struct {
+	turn   *turn.Server
+	quotas *quotas
+	done   chan struct{}
+}
+
+// Start listens on the configured UDP address and serves TURN allocations to
+// users authenticating with ephemeral credentials
+func Start() (*Server, error) {
+	cfg := config.AppConfig.TURN
+
+	publicIP := net.ParseIP(cfg.PublicIP)
+	if publicIP == nil {
+		return nil, fmt.Errorf("invalid turn.public_ip %q", cfg.PublicIP)
+	}
+
+	udpListener, err := net.ListenPacket("udp4", cfg.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", cfg.ListenAddr, err)
+	}
+
+	s := &Server{
+		quotas: newQuotas(cfg.MaxBitrateKbps),
+		done:   make(chan struct{}),
+	}
+
+	s.turn, err = turn.NewServer(turn.ServerConfig{
+		Realm:       cfg.Realm,
+		AuthHandler: s.authenticate,
+		PacketConnConfigs: []turn.PacketConnConfig{
+			{
+				PacketConn: &quotaConn{PacketConn: udpListener, quotas: s.quotas},
+				RelayAddressGenerator: &countingGenerator{
+					RelayAddressGenerator: &turn.RelayAddressGeneratorPortRange{
+						RelayAddress: publicIP,
+						Address:      "0.0.0.0",
+						MinPort:      cfg.RelayMinPort,
+						MaxPort:      cfg.RelayMaxPort,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		udpListener.Close()
+		return nil, fmt.Errorf("failed to start TURN server: %w", err)
+	}
+
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "chatgo_turn_allocations_active",
+		Help: "Relay allocations currently open on the TURN server.",
+	}, func() float64 {
+		return float64(s.turn.AllocationCount())
+	}))
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.quotas.sweep()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+
+	log.Printf("TURN server listening on %s (relay %s)", cfg.ListenAddr, publicIP)
+	return s, nil
+}
+
+// Close stops the TURN server
+func (s *Server) Close() error {
+	close(s.done)
+	return s.turn.Close()
+}
+
+// authenticate implements turn.AuthHandler. Only usernames minted by
+// ice.EphemeralCredentials are accepted: USERNAME travels in cleartext, so it
+// must never be a bearer token.
+func (s *Server) authenticate(username, realm string, srcAddr net.Addr) ([]byte, bool) {
+	userID, password, err := ice.ValidateEphemeral(config.AppConfig.WebRTC.TURNSecret, username)
+	if err != nil {
+		authAttempts.WithLabelValues("failure").Inc()
+		log.Printf("TURN authentication from %s failed: %v", srcAddr, err)
+		return nil, false
+	}
+
+	authAttempts.WithLabelValues("success").Inc()
+	s.quotas.bind(srcAddr, userID)
+	return turn.GenerateAuthKey(username, realm, password), true
+}
+
+// countingGenerator counts the relay sockets handed out for allocations
+type countingGenerator struct {
+	turn.RelayAddressGenerator
+}
+
+// AllocatePacketConn implements turn.RelayAddressGenerator
+func (g *countingGenerator) AllocatePacketConn(network string, requestedPort int) (net.PacketConn, net.Addr, error) {
+	conn, addr, err := g.RelayAddressGenerator.AllocatePacketConn(network, requestedPort)
+	if err == nil {
+		allocations.Inc()
+	}
+	return conn, addr, err
+}